@@ -0,0 +1,385 @@
+package ibsync
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// LotMethod defines how closing fills are matched against the open lots of a position.
+type LotMethod int
+
+const (
+	FIFO        LotMethod = iota // First in, first out
+	LIFO                         // Last in, first out
+	AverageCost                  // All open quantities are pooled in a single lot at the average cost
+)
+
+func (m LotMethod) String() string {
+	switch m {
+	case FIFO:
+		return "FIFO"
+	case LIFO:
+		return "LIFO"
+	case AverageCost:
+		return "AverageCost"
+	default:
+		return fmt.Sprintf("LotMethod(%d)", int(m))
+	}
+}
+
+// TaxLot is an open lot of a position.
+type TaxLot struct {
+	Account    string
+	Contract   *Contract
+	ExecID     string    // ExecID of the opening fill. Empty for average cost lots.
+	Time       time.Time // Time of the opening fill. Time of the first fill for average cost lots.
+	Quantity   float64   // Signed open quantity, negative if short
+	Price      float64   // Opening price per unit (not multiplied)
+	Commission float64   // Commissions and fees of the opening fills allocated to the open quantity
+}
+
+// ClosedLot is a lot, or part of a lot, closed by a fill.
+type ClosedLot struct {
+	Account     string
+	Contract    *Contract
+	OpenExecID  string    // ExecID of the opening fill. Empty for average cost lots.
+	CloseExecID string    // ExecID of the closing fill
+	OpenTime    time.Time // Time of the opening fill
+	CloseTime   time.Time // Time of the closing fill
+	Quantity    float64   // Signed closed quantity, negative if a short lot was closed
+	OpenPrice   float64   // Opening price per unit
+	ClosePrice  float64   // Closing price per unit
+	RealizedPNL float64   // Realized PnL, commissions excluded
+	Commission  float64   // Commissions and fees of the opening and closing fills allocated to the closed quantity
+}
+
+// NetPNL returns the realized PnL net of commissions and fees.
+func (cl ClosedLot) NetPNL() float64 {
+	return cl.RealizedPNL - cl.Commission
+}
+
+// AccountingPosition summarizes the open lots and realized PnL of an account for a contract.
+type AccountingPosition struct {
+	Account       string
+	Contract      *Contract
+	Position      float64 // Signed position
+	AvgCost       float64 // Average opening price per unit of the open lots
+	MarketPrice   float64 // Mark price, 0 if unknown
+	MarketValue   float64 // Position * MarketPrice * multiplier, 0 if the mark price is unknown
+	RealizedPNL   float64 // Realized PnL net of commissions and fees
+	UnrealizedPNL float64 // Unrealized PnL at MarketPrice, 0 if the mark price is unknown
+}
+
+// lotCost links a lot to a fill that contributed to it.
+// fraction is the part of the fill quantity that belongs to the lot.
+type lotCost struct {
+	fill     *Fill
+	fraction float64
+}
+
+// lot is an internal open lot.
+// Commissions are computed when read because commission reports are received after the executions.
+type lot struct {
+	account  string
+	execID   string
+	time     time.Time
+	quantity float64
+	price    float64
+	costs    []lotCost
+}
+
+// split removes quantity (absolute) from the lot and returns the costs allocated to the removed part.
+func (l *lot) split(quantity float64) []lotCost {
+	ratio := quantity / math.Abs(l.quantity)
+	removed := make([]lotCost, len(l.costs))
+	for i, c := range l.costs {
+		removed[i] = lotCost{fill: c.fill, fraction: c.fraction * ratio}
+		l.costs[i].fraction = c.fraction * (1 - ratio)
+	}
+	if l.quantity > 0 {
+		l.quantity -= quantity
+	} else {
+		l.quantity += quantity
+	}
+	return removed
+}
+
+// closedLot is an internal closed lot.
+type closedLot struct {
+	account    string
+	contract   *Contract
+	openExecID string
+	closeFill  *Fill
+	openTime   time.Time
+	quantity   float64
+	openPrice  float64
+	costs      []lotCost
+}
+
+// costsCommission returns the commissions and fees allocated by costs.
+func costsCommission(costs []lotCost) float64 {
+	var commission float64
+	for _, c := range costs {
+		commission += fillCommission(c.fill) * c.fraction
+	}
+	return commission
+}
+
+// Accounting is a fill driven accounting engine.
+//
+// It matches fills into tax lots with the FIFO, LIFO or average cost method and computes
+// realized PnL net of commissions, open lots and mark-to-market values, independently of IB PnL subscriptions.
+// It is safe for concurrent use.
+type Accounting struct {
+	mu       sync.Mutex
+	method   LotMethod
+	execIDs  map[string]struct{}  // processed execIDs
+	lots     map[string][]*lot    // Key(account, conID) -> open lots
+	closed   []closedLot          // closed lots
+	contract map[string]*Contract // Key(account, conID) -> contract
+}
+
+// NewAccounting creates a new accounting engine using the given lot matching method.
+func NewAccounting(method LotMethod) *Accounting {
+	return &Accounting{
+		method:   method,
+		execIDs:  make(map[string]struct{}),
+		lots:     make(map[string][]*lot),
+		contract: make(map[string]*Contract),
+	}
+}
+
+// Method returns the lot matching method of the engine.
+func (a *Accounting) Method() LotMethod {
+	return a.method
+}
+
+// AddFills processes fills in chronological order.
+//
+// Fills already processed are ignored, so the engine can be fed repeatedly with Trade.Fills().
+// Fills must be added in chronological order across calls for the lot matching to be meaningful.
+func (a *Accounting) AddFills(fills ...*Fill) {
+	fills = slices.Clone(fills)
+	sortFills(fills)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, f := range fills {
+		a.addFill(f)
+	}
+}
+
+// addFill processes a single fill. The engine must be locked by the caller.
+func (a *Accounting) addFill(f *Fill) {
+	if f == nil || f.Execution == nil || f.Contract == nil {
+		return
+	}
+	if _, ok := a.execIDs[f.Execution.ExecID]; ok {
+		return
+	}
+	a.execIDs[f.Execution.ExecID] = struct{}{}
+
+	q := f.signedShares()
+	if isZeroQuantity(q) {
+		return
+	}
+	shares := math.Abs(q)
+	key := Key(f.Execution.AcctNumber, f.Contract.ConID)
+	a.contract[key] = f.Contract
+	lots := a.lots[key]
+
+	remaining := shares
+	for remaining > quantityEpsilon && len(lots) > 0 && (lots[0].quantity > 0) != (q > 0) {
+		i := 0 // FIFO and AverageCost close the first lot
+		if a.method == LIFO {
+			i = len(lots) - 1
+		}
+		l := lots[i]
+		closed := math.Min(remaining, math.Abs(l.quantity))
+		direction := 1.0
+		if l.quantity < 0 {
+			direction = -1.0
+		}
+		costs := append(l.split(closed), lotCost{fill: f, fraction: closed / shares})
+		a.closed = append(a.closed, closedLot{
+			account:    f.Execution.AcctNumber,
+			contract:   f.Contract,
+			openExecID: l.execID,
+			closeFill:  f,
+			openTime:   l.time,
+			quantity:   closed * direction,
+			openPrice:  l.price,
+			costs:      costs,
+		})
+		if isZeroQuantity(l.quantity) {
+			lots = slices.Delete(lots, i, i+1)
+		}
+		remaining -= closed
+	}
+
+	if remaining > quantityEpsilon {
+		quantity := math.Copysign(remaining, q)
+		cost := lotCost{fill: f, fraction: remaining / shares}
+		if a.method == AverageCost && len(lots) > 0 {
+			pool := lots[0]
+			pool.price = (pool.price*math.Abs(pool.quantity) + f.Execution.Price*remaining) / (math.Abs(pool.quantity) + remaining)
+			pool.quantity += quantity
+			pool.costs = append(pool.costs, cost)
+		} else {
+			l := &lot{account: f.Execution.AcctNumber, time: f.Time, quantity: quantity, price: f.Execution.Price, costs: []lotCost{cost}}
+			if a.method != AverageCost {
+				l.execID = f.Execution.ExecID
+			}
+			lots = append(lots, l)
+		}
+	}
+	a.lots[key] = lots
+}
+
+// OpenLots returns the open lots, sorted by account, contract ID and opening time.
+//
+// If contract IDs are provided, only the lots of these contracts are returned.
+func (a *Accounting) OpenLots(conID ...int64) []TaxLot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var tls []TaxLot
+	for key, lots := range a.lots {
+		contract := a.contract[key]
+		if len(conID) > 0 && !slices.Contains(conID, contract.ConID) {
+			continue
+		}
+		for _, l := range lots {
+			tls = append(tls, TaxLot{
+				Account:    l.account,
+				Contract:   contract,
+				ExecID:     l.execID,
+				Time:       l.time,
+				Quantity:   l.quantity,
+				Price:      l.price,
+				Commission: costsCommission(l.costs),
+			})
+		}
+	}
+	slices.SortStableFunc(tls, func(x, y TaxLot) int {
+		return cmp.Or(
+			cmp.Compare(x.Account, y.Account),
+			cmp.Compare(x.Contract.ConID, y.Contract.ConID),
+			x.Time.Compare(y.Time),
+		)
+	})
+	return tls
+}
+
+// ClosedLots returns the closed lots in the order they were closed.
+//
+// If contract IDs are provided, only the lots of these contracts are returned.
+func (a *Accounting) ClosedLots(conID ...int64) []ClosedLot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var cls []ClosedLot
+	for _, cl := range a.closed {
+		if len(conID) > 0 && !slices.Contains(conID, cl.contract.ConID) {
+			continue
+		}
+		direction := 1.0
+		if cl.quantity < 0 {
+			direction = -1.0
+		}
+		closePrice := cl.closeFill.Execution.Price
+		cls = append(cls, ClosedLot{
+			Account:     cl.account,
+			Contract:    cl.contract,
+			OpenExecID:  cl.openExecID,
+			CloseExecID: cl.closeFill.Execution.ExecID,
+			OpenTime:    cl.openTime,
+			CloseTime:   cl.closeFill.Time,
+			Quantity:    cl.quantity,
+			OpenPrice:   cl.openPrice,
+			ClosePrice:  closePrice,
+			RealizedPNL: (closePrice - cl.openPrice) * math.Abs(cl.quantity) * direction * contractMultiplier(cl.contract),
+			Commission:  costsCommission(cl.costs),
+		})
+	}
+	return cls
+}
+
+// RealizedPNL returns the realized PnL of the closed lots net of commissions and fees.
+func (a *Accounting) RealizedPNL() float64 {
+	var pnl float64
+	for _, cl := range a.ClosedLots() {
+		pnl += cl.NetPNL()
+	}
+	return pnl
+}
+
+// Positions returns the positions of the engine, marked to market with the given tickers.
+//
+// Tickers are matched on the contract ID and their MarketPrice is used as mark price.
+// Positions without a matching ticker have no market value nor unrealized PnL.
+func (a *Accounting) Positions(tickers ...*Ticker) []AccountingPosition {
+	prices := make(map[int64]float64)
+	for _, t := range tickers {
+		if price := t.MarketPrice(); price > 0 && !math.IsNaN(price) {
+			prices[t.Contract().ConID] = price
+		}
+	}
+
+	positions := make(map[string]*AccountingPosition)
+	get := func(account string, contract *Contract) *AccountingPosition {
+		key := Key(account, contract.ConID)
+		p, ok := positions[key]
+		if !ok {
+			p = &AccountingPosition{Account: account, Contract: contract}
+			positions[key] = p
+		}
+		return p
+	}
+	for _, cl := range a.ClosedLots() {
+		get(cl.Account, cl.Contract).RealizedPNL += cl.NetPNL()
+	}
+	costs := make(map[*AccountingPosition]float64)
+	for _, tl := range a.OpenLots() {
+		p := get(tl.Account, tl.Contract)
+		costs[p] += tl.Price * tl.Quantity
+		p.Position += tl.Quantity
+		p.AvgCost = costs[p] / p.Position
+		if price, ok := prices[tl.Contract.ConID]; ok {
+			p.MarketPrice = price
+			p.MarketValue += tl.Quantity * price * contractMultiplier(tl.Contract)
+			p.UnrealizedPNL += (price - tl.Price) * tl.Quantity * contractMultiplier(tl.Contract)
+		}
+	}
+
+	aps := make([]AccountingPosition, 0, len(positions))
+	for _, p := range positions {
+		aps = append(aps, *p)
+	}
+	slices.SortFunc(aps, func(x, y AccountingPosition) int {
+		return cmp.Or(cmp.Compare(x.Account, y.Account), cmp.Compare(x.Contract.ConID, y.Contract.ConID))
+	})
+	return aps
+}
+
+// Accounting returns a new accounting engine fed with the fills of this session matching the optional filter.
+//
+// To include the executions of previous sessions of the day call ReqFills first.
+// The engine can be kept up to date by feeding it with the Trade.Fills() of new trades.
+// IB.Fills() returns copies, whose commissions are not updated when the commission reports arrive.
+func (ib *IB) Accounting(method LotMethod, execFilter ...*ExecutionFilter) *Accounting {
+	ib.state.mu.Lock()
+	var fills []*Fill
+	for _, f := range ib.state.fills {
+		if len(execFilter) == 0 || f.Matches(execFilter[0]) {
+			fills = append(fills, f)
+		}
+	}
+	ib.state.mu.Unlock()
+
+	acc := NewAccounting(method)
+	acc.AddFills(fills...)
+	return acc
+}
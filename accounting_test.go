@@ -0,0 +1,143 @@
+package ibsync
+
+import (
+	"testing"
+)
+
+func TestAccounting_LotMethods(t *testing.T) {
+	aapl := &Contract{ConID: 265598, Symbol: "AAPL", SecType: "STK"}
+	fills := []*Fill{
+		newTestFill("1", "", aapl, "BOT", 100, 10, 1),
+		newTestFill("2", "", aapl, "BOT", 100, 12, 2),
+		newTestFill("3", "", aapl, "SLD", 150, 13, 3),
+	}
+
+	tests := []struct {
+		method       LotMethod
+		realized     float64 // gross
+		openQuantity float64
+		openPrice    float64
+		nbClosed     int
+	}{
+		{FIFO, 300 + 50, 50, 12, 2},  // 100*(13-10) + 50*(13-12)
+		{LIFO, 100 + 150, 50, 10, 2}, // 100*(13-12) + 50*(13-10)
+		{AverageCost, 300, 50, 11, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method.String(), func(t *testing.T) {
+			acc := NewAccounting(tt.method)
+			acc.AddFills(fills...)
+
+			cls := acc.ClosedLots()
+			if len(cls) != tt.nbClosed {
+				t.Fatalf("ClosedLots() len = %v, want %v", len(cls), tt.nbClosed)
+			}
+			var realized, commission float64
+			for _, cl := range cls {
+				realized += cl.RealizedPNL
+				commission += cl.Commission
+			}
+			if !almostEqual(realized, tt.realized) {
+				t.Errorf("realized = %v, want %v", realized, tt.realized)
+			}
+			// closing fill commission (1) + 100/100 and 50/100 of the opening fills commissions
+			if !almostEqual(commission, 2.5) {
+				t.Errorf("commission = %v, want %v", commission, 2.5)
+			}
+			if !almostEqual(acc.RealizedPNL(), tt.realized-2.5) {
+				t.Errorf("RealizedPNL() = %v, want %v", acc.RealizedPNL(), tt.realized-2.5)
+			}
+
+			lots := acc.OpenLots()
+			if len(lots) != 1 {
+				t.Fatalf("OpenLots() len = %v, want %v", len(lots), 1)
+			}
+			if !almostEqual(lots[0].Quantity, tt.openQuantity) || !almostEqual(lots[0].Price, tt.openPrice) {
+				t.Errorf("OpenLots()[0] = %v@%v, want %v@%v", lots[0].Quantity, lots[0].Price, tt.openQuantity, tt.openPrice)
+			}
+			if !almostEqual(lots[0].Commission, 0.5) {
+				t.Errorf("OpenLots()[0].Commission = %v, want %v", lots[0].Commission, 0.5)
+			}
+		})
+	}
+}
+
+func TestAccounting_ShortAndReverse(t *testing.T) {
+	es := &Contract{ConID: 495512563, Symbol: "ES", SecType: "FUT", Multiplier: "50"}
+	acc := NewAccounting(FIFO)
+	acc.AddFills(
+		newTestFill("1", "", es, "SLD", 2, 5000, 1),
+		newTestFill("2", "", es, "BOT", 3, 4990, 2),
+	)
+
+	cls := acc.ClosedLots()
+	if len(cls) != 1 {
+		t.Fatalf("ClosedLots() len = %v, want %v", len(cls), 1)
+	}
+	if !almostEqual(cls[0].Quantity, -2) || !almostEqual(cls[0].RealizedPNL, 1000) {
+		t.Errorf("ClosedLots()[0] = %v, %v, want %v, %v", cls[0].Quantity, cls[0].RealizedPNL, -2, 1000)
+	}
+
+	lots := acc.OpenLots()
+	if len(lots) != 1 || !almostEqual(lots[0].Quantity, 1) || lots[0].ExecID != "2" {
+		t.Fatalf("OpenLots() = %v, want one long lot opened by exec 2", lots)
+	}
+}
+
+func TestAccounting_DuplicatesAndLateCommission(t *testing.T) {
+	aapl := &Contract{ConID: 265598, Symbol: "AAPL", SecType: "STK"}
+	buy := newTestFill("1", "", aapl, "BOT", 100, 10, 1)
+	sell := newTestFill("2", "", aapl, "SLD", 100, 11, 2)
+	sell.CommissionAndFeesReport = NewCommissionAndFeesReport()
+
+	acc := NewAccounting(FIFO)
+	acc.AddFills(buy)
+	acc.AddFills(buy, sell)
+
+	if !almostEqual(acc.RealizedPNL(), 99) {
+		t.Errorf("RealizedPNL() = %v, want %v", acc.RealizedPNL(), 99)
+	}
+
+	// commission report received after the execution
+	sell.CommissionAndFeesReport.CommissionAndFees = 2
+	if !almostEqual(acc.RealizedPNL(), 97) {
+		t.Errorf("RealizedPNL() after commission = %v, want %v", acc.RealizedPNL(), 97)
+	}
+}
+
+func TestAccounting_Positions(t *testing.T) {
+	aapl := &Contract{ConID: 265598, Symbol: "AAPL", SecType: "STK"}
+	acc := NewAccounting(FIFO)
+	acc.AddFills(
+		newTestFill("1", "", aapl, "BOT", 100, 10, 1),
+		newTestFill("2", "", aapl, "BOT", 100, 12, 2),
+	)
+
+	ticker := NewTicker(aapl)
+	ticker.SetTickPrice(TickPrice{TickType: BID, Price: 14.9})
+	ticker.SetTickPrice(TickPrice{TickType: ASK, Price: 15.1})
+
+	aps := acc.Positions(ticker)
+	if len(aps) != 1 {
+		t.Fatalf("Positions() len = %v, want %v", len(aps), 1)
+	}
+	ap := aps[0]
+	if !almostEqual(ap.Position, 200) || !almostEqual(ap.AvgCost, 11) {
+		t.Errorf("Positions()[0] = %v@%v, want %v@%v", ap.Position, ap.AvgCost, 200, 11)
+	}
+	if !almostEqual(ap.MarketValue, 3000) || !almostEqual(ap.UnrealizedPNL, 800) {
+		t.Errorf("Positions()[0] value = %v, unrealized = %v, want %v, %v", ap.MarketValue, ap.UnrealizedPNL, 3000, 800)
+	}
+}
+
+func TestAccounting_OpenLotsAccount(t *testing.T) {
+	aapl := &Contract{ConID: 265598, Symbol: "AAPL", SecType: "STK"}
+	fill := newTestFill("1", "", aapl, "BOT", 100, 10, 1)
+	fill.Execution.AcctNumber = "F1::DU123"
+	acc := NewAccounting(FIFO)
+	acc.AddFills(fill)
+	if lots := acc.OpenLots(); len(lots) != 1 || lots[0].Account != "F1::DU123" {
+		t.Errorf("OpenLots() = %v, want one lot of account F1::DU123", lots)
+	}
+}
@@ -0,0 +1,327 @@
+package ibsync
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BarAggregation is the rule closing the bars of a BarAggregator.
+type BarAggregation int
+
+const (
+	TimeBars   BarAggregation = iota // Bars of a fixed duration
+	TickBars                         // Bars of a fixed number of trades
+	VolumeBars                       // Bars of a fixed traded volume
+	DollarBars                       // Bars of a fixed traded notional (price * size)
+)
+
+func (a BarAggregation) String() string {
+	switch a {
+	case TimeBars:
+		return "TimeBars"
+	case TickBars:
+		return "TickBars"
+	case VolumeBars:
+		return "VolumeBars"
+	case DollarBars:
+		return "DollarBars"
+	}
+	return "BarAggregation(" + strconv.Itoa(int(a)) + ")"
+}
+
+// barBuilder accumulates the bar in progress.
+type barBuilder struct {
+	start    time.Time
+	end      time.Time // End of the time bucket, time bars only
+	open     float64
+	high     float64
+	low      float64
+	close    float64
+	volume   float64
+	notional float64
+	count    int64
+	started  bool
+}
+
+// add merges a price move and a traded volume into the bar.
+func (b *barBuilder) add(open, high, low, close, volume, notional float64, count int64) {
+	if !b.started {
+		b.open, b.high, b.low = open, high, low
+		b.started = true
+	}
+	b.high = max(b.high, high)
+	b.low = min(b.low, low)
+	b.close = close
+	b.volume += volume
+	b.notional += notional
+	b.count += count
+}
+
+// bar returns the bar built so far, dated at its start time (unix seconds).
+func (b *barBuilder) bar() Bar {
+	bar := NewBar()
+	bar.Date = strconv.FormatInt(b.start.Unix(), 10)
+	bar.Open, bar.High, bar.Low, bar.Close = b.open, b.high, b.low, b.close
+	bar.Volume = floatToDecimal(b.volume)
+	if b.volume > 0 {
+		bar.Wap = floatToDecimal(b.notional / b.volume)
+	}
+	bar.BarCount = b.count
+	return bar
+}
+
+// BarAggregator builds custom bars from real time bars or tick-by-tick trades.
+//
+// Completed bars are sent on Bars, which must be read: the inputs block when its buffer is full, until Close. The bar in progress is sent on Updates after each input, only the latest one is kept if it is not read.
+// Bars are dated at their start time, in unix seconds, and their Wap is the volume weighted average price.
+// A real time bar is never split: with tick, volume or dollar bars, it is added whole and may overshoot the threshold.
+type BarAggregator struct {
+	mu          sync.Mutex
+	aggregation BarAggregation
+	size        time.Duration // Time bars
+	sessionOpen time.Time     // Time bars alignment
+	threshold   float64       // Tick, volume and dollar bars
+	current     *barBuilder
+	bars        chan Bar
+	updates     chan Bar
+	closed      bool
+	done        chan struct{} // closed by Close, unblocks the inputs waiting for Bars to be read
+	doneOnce    sync.Once
+}
+
+func newBarAggregator(aggregation BarAggregation) *BarAggregator {
+	return &BarAggregator{
+		aggregation: aggregation,
+		bars:        make(chan Bar, 100),
+		updates:     make(chan Bar, 1),
+		done:        make(chan struct{}),
+	}
+}
+
+// NewTimeBarAggregator creates an aggregator of bars lasting size.
+//
+// Bars shorter than a day are aligned on the session open time of day, in the location of sessionOpen:
+// 1 hour bars with a 09:30 New York session open start at 09:30, 10:30... and the last bar of the day ends at the next session open.
+// Bars of a day or longer are aligned on sessionOpen itself.
+// A zero sessionOpen aligns the bars on midnight UTC.
+func NewTimeBarAggregator(size time.Duration, sessionOpen time.Time) *BarAggregator {
+	if size <= 0 {
+		size = time.Minute
+	}
+	if sessionOpen.IsZero() {
+		sessionOpen = time.Unix(0, 0).UTC()
+	}
+	a := newBarAggregator(TimeBars)
+	a.size = size
+	a.sessionOpen = sessionOpen
+	return a
+}
+
+// NewTickBarAggregator creates an aggregator of bars of n trades.
+func NewTickBarAggregator(n int64) *BarAggregator {
+	a := newBarAggregator(TickBars)
+	a.threshold = float64(max(n, 1))
+	return a
+}
+
+// NewVolumeBarAggregator creates an aggregator of bars of volume traded shares or contracts.
+func NewVolumeBarAggregator(volume float64) *BarAggregator {
+	a := newBarAggregator(VolumeBars)
+	a.threshold = volume
+	return a
+}
+
+// NewDollarBarAggregator creates an aggregator of bars of notional traded value, price * size.
+// The contract multiplier is not applied.
+func NewDollarBarAggregator(notional float64) *BarAggregator {
+	a := newBarAggregator(DollarBars)
+	a.threshold = notional
+	return a
+}
+
+// Aggregation returns the rule closing the bars.
+func (a *BarAggregator) Aggregation() BarAggregation {
+	return a.aggregation
+}
+
+// Bars returns the channel of the completed bars. It is closed by Close.
+func (a *BarAggregator) Bars() <-chan Bar {
+	return a.bars
+}
+
+// Updates returns the channel of the bar in progress. It is closed by Close.
+func (a *BarAggregator) Updates() <-chan Bar {
+	return a.updates
+}
+
+// Current returns the bar in progress. ok is false if there is none.
+func (a *BarAggregator) Current() (bar Bar, ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.current == nil {
+		return Bar{}, false
+	}
+	return a.current.bar(), true
+}
+
+// bucket returns the time bucket of t.
+func (a *BarAggregator) bucket(t time.Time) (start, end time.Time) {
+	anchor := a.sessionOpen
+	if a.size >= historyDay {
+		n := t.Sub(anchor) / a.size
+		if t.Before(anchor.Add(n * a.size)) {
+			n--
+		}
+		start = anchor.Add(n * a.size)
+		return start, start.Add(a.size)
+	}
+	lt := t.In(anchor.Location())
+	day := time.Date(lt.Year(), lt.Month(), lt.Day(), anchor.Hour(), anchor.Minute(), anchor.Second(), anchor.Nanosecond(), anchor.Location())
+	if lt.Before(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	next := day.AddDate(0, 0, 1)
+	start = day.Add(t.Sub(day) / a.size * a.size)
+	end = start.Add(a.size)
+	if end.After(next) {
+		end = next
+	}
+	return start, end
+}
+
+// add merges an input dated t into the bars. The aggregator must be locked by the caller.
+func (a *BarAggregator) add(t time.Time, open, high, low, close, volume, notional float64, count int64) {
+	if a.closed {
+		return
+	}
+	if a.aggregation == TimeBars {
+		if a.current != nil && !t.Before(a.current.end) {
+			a.complete()
+		}
+		if a.current == nil {
+			start, end := a.bucket(t)
+			a.current = &barBuilder{start: start, end: end}
+		}
+	} else if a.current == nil {
+		a.current = &barBuilder{start: t}
+	}
+	a.current.add(open, high, low, close, volume, notional, count)
+
+	var done bool
+	switch a.aggregation {
+	case TickBars:
+		done = float64(a.current.count) >= a.threshold
+	case VolumeBars:
+		done = a.current.volume >= a.threshold
+	case DollarBars:
+		done = a.current.notional >= a.threshold
+	}
+	if done {
+		a.complete()
+		return
+	}
+	a.update()
+}
+
+// complete sends the bar in progress on Bars. The aggregator must be locked by the caller.
+// Once Close is called, the bar is dropped if the buffer of Bars is full.
+func (a *BarAggregator) complete() {
+	if a.current == nil {
+		return
+	}
+	bar := a.current.bar()
+	a.current = nil
+	select {
+	case a.bars <- bar:
+		return
+	default:
+	}
+	select {
+	case a.bars <- bar:
+	case <-a.done:
+		log.Warn().Str("date", bar.Date).Msg("<BarAggregator> bar dropped, Bars not read")
+	}
+}
+
+// update sends the bar in progress on Updates, replacing an unread update. The aggregator must be locked by the caller.
+func (a *BarAggregator) update() {
+	bar := a.current.bar()
+	select {
+	case <-a.updates:
+	default:
+	}
+	a.updates <- bar
+}
+
+// AddTick adds a tick-by-tick trade.
+func (a *BarAggregator) AddTick(tick TickByTickAllLast) {
+	size := decimalToFloat(tick.Size)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.add(time.Unix(tick.Time, 0), tick.Price, tick.Price, tick.Price, tick.Price, size, tick.Price*size, 1)
+}
+
+// AddRealTimeBar adds a real time bar.
+func (a *BarAggregator) AddRealTimeBar(rtb RealTimeBar) {
+	volume := decimalToFloat(rtb.Volume)
+	wap := decimalToFloat(rtb.Wap)
+	if wap == 0 {
+		wap = rtb.Close
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.add(time.Unix(rtb.Time, 0), rtb.Open, rtb.High, rtb.Low, rtb.Close, volume, wap*volume, rtb.Count)
+}
+
+// Advance completes the time bar in progress if it ended before now.
+// Call it periodically to get the bars without waiting for the next input, e.g. when the market is quiet.
+func (a *BarAggregator) Advance(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.aggregation == TimeBars && a.current != nil && !now.Before(a.current.end) {
+		a.complete()
+	}
+}
+
+// Flush completes the bar in progress, if any.
+func (a *BarAggregator) Flush() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.closed {
+		a.complete()
+	}
+}
+
+// Close completes the bar in progress and closes the channels. It is safe to call it several times.
+// It does not wait for Bars to be read: the completed bars that do not fit in its buffer are dropped.
+func (a *BarAggregator) Close() {
+	a.doneOnce.Do(func() { close(a.done) })
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	a.complete()
+	a.closed = true
+	close(a.bars)
+	close(a.updates)
+}
+
+// FeedRealTimeBars adds the real time bars of ch until it is closed, then closes the aggregator.
+// Run it in a goroutine, e.g. with the channel of a RealTimeBarSubscription.
+func (a *BarAggregator) FeedRealTimeBars(ch <-chan RealTimeBar) {
+	for rtb := range ch {
+		a.AddRealTimeBar(rtb)
+	}
+	a.Close()
+}
+
+// FeedTicks adds the ticks of ch until it is closed, then closes the aggregator.
+// Run it in a goroutine, e.g. with the channel of SubscribeTickByTickAllLast.
+func (a *BarAggregator) FeedTicks(ch <-chan TickByTickAllLast) {
+	for tick := range ch {
+		a.AddTick(tick)
+	}
+	a.Close()
+}
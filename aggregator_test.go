@@ -0,0 +1,199 @@
+package ibsync
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// collectBars closes the aggregator and returns its completed bars.
+func collectBars(a *BarAggregator) []Bar {
+	a.Close()
+	var bars []Bar
+	for bar := range a.Bars() {
+		bars = append(bars, bar)
+	}
+	return bars
+}
+
+func testTick(t time.Time, price float64, size string) TickByTickAllLast {
+	return TickByTickAllLast{Time: t.Unix(), Price: price, Size: StringToDecimal(size)}
+}
+
+func TestTimeBarAggregator(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	open := time.Date(2024, 1, 2, 9, 30, 0, 0, ny)
+	at := func(h, m int) time.Time { return time.Date(2024, 1, 2, h, m, 0, 0, ny) }
+
+	a := NewTimeBarAggregator(time.Hour, open)
+	a.AddTick(testTick(at(9, 45), 100, "10"))
+	a.AddTick(testTick(at(10, 15), 102, "30"))
+	a.AddTick(testTick(at(10, 29), 99, "10"))
+	if bar, ok := a.Current(); !ok || bar.Close != 99 {
+		t.Fatalf("Current() = %v, %v", bar, ok)
+	}
+	a.AddTick(testTick(at(10, 30), 101, "5"))
+	a.Advance(at(11, 0))
+	if _, ok := a.Current(); !ok {
+		t.Fatalf("Advance() completed the bar before its end")
+	}
+	a.Advance(at(11, 30))
+	if _, ok := a.Current(); ok {
+		t.Fatalf("Advance() did not complete the bar")
+	}
+
+	bars := collectBars(a)
+	if len(bars) != 2 {
+		t.Fatalf("got %v bars, want 2", len(bars))
+	}
+	first := bars[0]
+	if first.Date != strconv.FormatInt(at(9, 30).Unix(), 10) {
+		t.Errorf("first bar date = %v, want %v", first.Date, at(9, 30))
+	}
+	if first.Open != 100 || first.High != 102 || first.Low != 99 || first.Close != 99 || first.BarCount != 3 {
+		t.Errorf("first bar = %v", first)
+	}
+	if v := first.Volume.Float(); v != 50 {
+		t.Errorf("first bar volume = %v, want 50", v)
+	}
+	if wap := first.Wap.Float(); wap != (100*10+102*30+99*10)/50.0 {
+		t.Errorf("first bar wap = %v", wap)
+	}
+	if bars[1].Date != strconv.FormatInt(at(10, 30).Unix(), 10) {
+		t.Errorf("second bar date = %v, want %v", bars[1].Date, at(10, 30))
+	}
+}
+
+func TestTimeBarBuckets(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	open := time.Date(2024, 1, 2, 9, 30, 0, 0, ny)
+	at := func(d, h, m int) time.Time { return time.Date(2024, 1, d, h, m, 0, 0, ny) }
+
+	tests := []struct {
+		name        string
+		size        time.Duration
+		sessionOpen time.Time
+		t           time.Time
+		start, end  time.Time
+	}{
+		{"7 min", 7 * time.Minute, open, at(2, 9, 40), at(2, 9, 37), at(2, 9, 44)},
+		{"7 min next day", 7 * time.Minute, open, at(3, 9, 31), at(3, 9, 30), at(3, 9, 37)},
+		{"7 min truncated", 7 * time.Minute, open, at(3, 9, 29), at(3, 9, 25), at(3, 9, 30)},
+		{"before open", time.Hour, open, at(3, 9, 0), at(3, 8, 30), at(3, 9, 30)},
+		{"utc minute", time.Minute, time.Time{}, at(2, 9, 40).Add(30 * time.Second), at(2, 9, 40), at(2, 9, 41)},
+		{"daily", historyDay, open, at(3, 9, 0), at(2, 9, 30), at(3, 9, 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewTimeBarAggregator(tt.size, tt.sessionOpen)
+			start, end := a.bucket(tt.t)
+			if !start.Equal(tt.start) || !end.Equal(tt.end) {
+				t.Errorf("bucket(%v) = [%v, %v), want [%v, %v)", tt.t, start, end, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestActivityBarAggregators(t *testing.T) {
+	now := testFillTime
+	ticks := []TickByTickAllLast{
+		testTick(now, 10, "100"),
+		testTick(now.Add(time.Second), 11, "200"),
+		testTick(now.Add(2*time.Second), 12, "100"),
+		testTick(now.Add(3*time.Second), 13, "300"),
+		testTick(now.Add(4*time.Second), 14, "100"),
+	}
+
+	tests := []struct {
+		name       string
+		aggregator *BarAggregator
+		counts     []int64 // Trades per completed bar, the last one being flushed by Close
+	}{
+		{"tick", NewTickBarAggregator(2), []int64{2, 2, 1}},
+		{"volume", NewVolumeBarAggregator(300), []int64{2, 2, 1}},
+		{"dollar", NewDollarBarAggregator(2000), []int64{2, 2, 1}},
+		{"dollar large", NewDollarBarAggregator(1e6), []int64{5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, tick := range ticks {
+				tt.aggregator.AddTick(tick)
+			}
+			bars := collectBars(tt.aggregator)
+			if len(bars) != len(tt.counts) {
+				t.Fatalf("got %v bars, want %v", len(bars), len(tt.counts))
+			}
+			for i, bar := range bars {
+				if bar.BarCount != tt.counts[i] {
+					t.Errorf("bars[%v].BarCount = %v, want %v", i, bar.BarCount, tt.counts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBarAggregatorRealTimeBars(t *testing.T) {
+	ch := make(chan RealTimeBar)
+	a := NewTimeBarAggregator(15*time.Second, time.Time{})
+	go a.FeedRealTimeBars(ch)
+
+	start := testFillTime
+	for i := range 4 {
+		rtb := NewRealTimeBar()
+		rtb.Time = start.Add(time.Duration(i) * 5 * time.Second).Unix()
+		rtb.Open, rtb.High, rtb.Low, rtb.Close = 10, 11+float64(i), 9, 10
+		rtb.Volume = StringToDecimal("100")
+		rtb.Wap = StringToDecimal("10")
+		rtb.Count = 2
+		ch <- rtb
+	}
+	close(ch)
+
+	var bars []Bar
+	for bar := range a.Bars() {
+		bars = append(bars, bar)
+	}
+	if len(bars) != 2 {
+		t.Fatalf("got %v bars, want 2", len(bars))
+	}
+	if bars[0].High != 13 || bars[0].BarCount != 6 || bars[0].Volume.Float() != 300 || bars[0].Wap.Float() != 10 {
+		t.Errorf("bars[0] = %v", bars[0])
+	}
+	if bars[1].High != 14 || bars[1].BarCount != 2 {
+		t.Errorf("bars[1] = %v", bars[1])
+	}
+}
+
+func TestBarAggregatorCloseUnread(t *testing.T) {
+	a := NewTickBarAggregator(1)
+	start := time.Unix(1700000000, 0)
+	for i := range cap(a.bars) {
+		a.AddTick(testTick(start.Add(time.Duration(i)*time.Second), 100, "1"))
+	}
+	blocked := make(chan struct{})
+	go func() {
+		defer close(blocked)
+		a.AddTick(testTick(start.Add(time.Hour), 100, "1"))
+	}()
+
+	closed := make(chan struct{})
+	go func() {
+		a.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close() blocked by an unread Bars channel")
+	}
+	<-blocked
+	if n := len(collectBars(a)); n != cap(a.bars) {
+		t.Errorf("bars = %v, want %v", n, cap(a.bars))
+	}
+}
@@ -0,0 +1,295 @@
+package ibsync
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TradingSession is a trading session of a contract, [Start, End).
+type TradingSession struct {
+	Date  string // Trading day of the session, "YYYYMMDD"
+	Start time.Time
+	End   time.Time
+}
+
+// TradingCalendar is the trading calendar of a contract, in the time zone of its exchange.
+//
+// The calendar only covers the days listed by IB, usually the current week for ContractDetails.
+type TradingCalendar struct {
+	Location *time.Location
+	Sessions []TradingSession // Trading hours, sorted
+	Liquid   []TradingSession // Liquid (regular trading) hours, sorted. Same as Sessions if unknown
+	Holidays []string         // Days closed, "YYYYMMDD"
+}
+
+// NewTradingCalendar creates the calendar of a contract from the TradingHours and LiquidHours of its details.
+func NewTradingCalendar(cd ContractDetails) (*TradingCalendar, error) {
+	loc, err := time.LoadLocation(cd.TimeZoneID)
+	if err != nil {
+		return nil, fmt.Errorf("trading calendar: time zone %q: %w", cd.TimeZoneID, err)
+	}
+	c := &TradingCalendar{Location: loc}
+	if c.Sessions, c.Holidays, err = parseTradingHours(cd.TradingHours, loc); err != nil {
+		return nil, err
+	}
+	if c.Liquid, _, err = parseTradingHours(cd.LiquidHours, loc); err != nil {
+		return nil, err
+	}
+	if len(c.Liquid) == 0 {
+		c.Liquid = c.Sessions
+	}
+	return c, nil
+}
+
+// NewTradingCalendarFromSchedule creates a calendar from the sessions of ReqHistoricalSchedule.
+// The weekdays without session are holidays. The sessions are the liquid hours if the schedule was requested with useRTH.
+func NewTradingCalendarFromSchedule(hs HistoricalSchedule) (*TradingCalendar, error) {
+	loc, err := time.LoadLocation(hs.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("trading calendar: time zone %q: %w", hs.TimeZone, err)
+	}
+	c := &TradingCalendar{Location: loc}
+	for _, s := range hs.Sessions {
+		start, err := time.ParseInLocation("20060102-15:04:05", s.StartDateTime, loc)
+		if err != nil {
+			return nil, fmt.Errorf("trading calendar: session start %q: %w", s.StartDateTime, err)
+		}
+		end, err := time.ParseInLocation("20060102-15:04:05", s.EndDateTime, loc)
+		if err != nil {
+			return nil, fmt.Errorf("trading calendar: session end %q: %w", s.EndDateTime, err)
+		}
+		c.Sessions = append(c.Sessions, TradingSession{Date: s.RefDate, Start: start, End: end})
+	}
+	sortSessions(c.Sessions)
+	c.Liquid = c.Sessions
+	if len(c.Sessions) > 0 {
+		first, _ := time.ParseInLocation("20060102", c.Sessions[0].Date, loc)
+		last, _ := time.ParseInLocation("20060102", c.Sessions[len(c.Sessions)-1].Date, loc)
+		for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+			day := d.Format("20060102")
+			if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday && !slices.ContainsFunc(c.Sessions, func(s TradingSession) bool { return s.Date == day }) {
+				c.Holidays = append(c.Holidays, day)
+			}
+		}
+	}
+	return c, nil
+}
+
+// parseTradingHours parses the TradingHours or LiquidHours of contract details, in either of the IB formats:
+// "20090507:0700-1830,1830-2330;20090508:CLOSED" or "20180323:0400-20180323:2000;20180324:CLOSED".
+func parseTradingHours(hours string, loc *time.Location) (sessions []TradingSession, closed []string, err error) {
+	for _, day := range strings.Split(hours, ";") {
+		if day == "" {
+			continue
+		}
+		date, ranges, ok := strings.Cut(day, ":")
+		if !ok {
+			return nil, nil, fmt.Errorf("trading hours: invalid day %q", day)
+		}
+		if ranges == "CLOSED" {
+			closed = append(closed, date)
+			continue
+		}
+		for _, r := range strings.Split(ranges, ",") {
+			from, to, ok := strings.Cut(r, "-")
+			if !ok {
+				return nil, nil, fmt.Errorf("trading hours: invalid range %q", r)
+			}
+			start, err := parseSessionTime(from, date, loc)
+			if err != nil {
+				return nil, nil, err
+			}
+			end, err := parseSessionTime(to, date, loc)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !end.After(start) {
+				end = end.AddDate(0, 0, 1) // Overnight session in the old format
+			}
+			sessions = append(sessions, TradingSession{Date: date, Start: start, End: end})
+		}
+	}
+	sortSessions(sessions)
+	return sessions, closed, nil
+}
+
+// parseSessionTime parses "HHMM" on date, or "YYYYMMDD:HHMM".
+func parseSessionTime(s, date string, loc *time.Location) (time.Time, error) {
+	if d, hm, ok := strings.Cut(s, ":"); ok {
+		date, s = d, hm
+	}
+	t, err := time.ParseInLocation("200601021504", date+s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("trading hours: invalid time %q: %w", s, err)
+	}
+	return t, nil
+}
+
+func sortSessions(sessions []TradingSession) {
+	slices.SortFunc(sessions, func(a, b TradingSession) int { return a.Start.Compare(b.Start) })
+}
+
+func sessionAt(sessions []TradingSession, t time.Time) (TradingSession, bool) {
+	for _, s := range sessions {
+		if !t.Before(s.Start) && t.Before(s.End) {
+			return s, true
+		}
+	}
+	return TradingSession{}, false
+}
+
+// IsOpen reports whether t is within the trading hours.
+func (c *TradingCalendar) IsOpen(t time.Time) bool {
+	_, ok := sessionAt(c.Sessions, t)
+	return ok
+}
+
+// IsLiquid reports whether t is within the liquid hours.
+func (c *TradingCalendar) IsLiquid(t time.Time) bool {
+	_, ok := sessionAt(c.Liquid, t)
+	return ok
+}
+
+// NextOpen returns the start of the next trading session after t. ok is false if the calendar has none.
+// If t is within a session, it is the start of the following one.
+func (c *TradingCalendar) NextOpen(t time.Time) (open time.Time, ok bool) {
+	for _, s := range c.Sessions {
+		if s.Start.After(t) {
+			return s.Start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// NextClose returns the end of the current trading session, or of the next one if the market is closed at t.
+// ok is false if the calendar has none.
+func (c *TradingCalendar) NextClose(t time.Time) (end time.Time, ok bool) {
+	for _, s := range c.Sessions {
+		if s.End.After(t) {
+			return s.End, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// SessionsOn returns the trading sessions of a trading day, "YYYYMMDD".
+func (c *TradingCalendar) SessionsOn(date string) []TradingSession {
+	var sessions []TradingSession
+	for _, s := range c.Sessions {
+		if s.Date == date {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions
+}
+
+// IsHoliday reports whether the trading day of t, in the calendar time zone, is listed as closed.
+func (c *TradingCalendar) IsHoliday(t time.Time) bool {
+	return slices.Contains(c.Holidays, t.In(c.Location).Format("20060102"))
+}
+
+// HalfDays returns the trading days, "YYYYMMDD", whose liquid hours are shorter than 3/4 of the usual day.
+// The usual day is the median duration of the liquid hours of the calendar.
+func (c *TradingCalendar) HalfDays() []string {
+	durations := make(map[string]time.Duration)
+	var days []string
+	for _, s := range c.Liquid {
+		if _, ok := durations[s.Date]; !ok {
+			days = append(days, s.Date)
+		}
+		durations[s.Date] += s.End.Sub(s.Start)
+	}
+	if len(days) < 2 {
+		return nil
+	}
+	sorted := make([]time.Duration, 0, len(days))
+	for _, d := range days {
+		sorted = append(sorted, durations[d])
+	}
+	slices.Sort(sorted)
+	usual := sorted[len(sorted)/2]
+	var half []string
+	for _, d := range days {
+		if durations[d] < usual*3/4 {
+			half = append(half, d)
+		}
+	}
+	return half
+}
+
+// IsHalfDay reports whether the trading day of t, in the calendar time zone, is a half day.
+func (c *TradingCalendar) IsHalfDay(t time.Time) bool {
+	return slices.Contains(c.HalfDays(), t.In(c.Location).Format("20060102"))
+}
+
+// validUntil returns the start of the last day covered by the calendar, when it should be refreshed.
+func (c *TradingCalendar) validUntil() time.Time {
+	var last time.Time
+	for _, s := range c.Sessions {
+		if d, err := time.ParseInLocation("20060102", s.Date, c.Location); err == nil && d.After(last) {
+			last = d
+		}
+	}
+	for _, h := range c.Holidays {
+		if d, err := time.ParseInLocation("20060102", h, c.Location); err == nil && d.After(last) {
+			last = d
+		}
+	}
+	return last
+}
+
+// calendarCache caches the trading calendars by ConID.
+type calendarCache struct {
+	mu        sync.Mutex
+	calendars map[int64]*TradingCalendar
+}
+
+func newCalendarCache() *calendarCache {
+	return &calendarCache{calendars: make(map[int64]*TradingCalendar)}
+}
+
+func (cc *calendarCache) get(conID int64, now time.Time) (*TradingCalendar, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	c, ok := cc.calendars[conID]
+	if !ok || !now.Before(c.validUntil()) {
+		return nil, false
+	}
+	return c, true
+}
+
+func (cc *calendarCache) set(conID int64, c *TradingCalendar) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.calendars[conID] = c
+}
+
+// TradingCalendar returns the trading calendar of a contract, from its contract details.
+// Calendars are cached by ConID until the last day they cover.
+func (ib *IB) TradingCalendar(contract *Contract) (*TradingCalendar, error) {
+	if contract.ConID != 0 {
+		if c, ok := ib.calendars.get(contract.ConID, time.Now()); ok {
+			return c, nil
+		}
+	}
+	// The trading hours change every day: bypass the contract cache, if enabled.
+	cds, err := ib.reqContractDetails(contract)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(cds) == 0:
+		return nil, ErrUnknownContract
+	case len(cds) > 1:
+		return nil, ErrAmbiguousContract
+	}
+	c, err := NewTradingCalendar(cds[0])
+	if err != nil {
+		return nil, err
+	}
+	ib.calendars.set(cds[0].Contract.ConID, c)
+	return c, nil
+}
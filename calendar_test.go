@@ -0,0 +1,101 @@
+package ibsync
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTradingCalendar(t *testing.T) {
+	cd := ContractDetails{
+		TimeZoneID: "US/Eastern",
+		// Thanksgiving week: closed on Thursday, half day on Friday.
+		TradingHours: "20241125:0400-20241125:2000;20241126:0400-20241126:2000;20241127:0400-20241127:2000;20241128:CLOSED;20241129:0400-20241129:1700",
+		LiquidHours:  "20241125:0930-20241125:1600;20241126:0930-20241126:1600;20241127:0930-20241127:1600;20241128:CLOSED;20241129:0930-20241129:1300",
+	}
+	c, err := NewTradingCalendar(cd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ny := c.Location
+	at := func(day, hour, minute int) time.Time { return time.Date(2024, 11, day, hour, minute, 0, 0, ny) }
+
+	if !c.IsOpen(at(25, 5, 0)) || c.IsLiquid(at(25, 5, 0)) || !c.IsLiquid(at(25, 9, 30)) || c.IsLiquid(at(25, 16, 0)) {
+		t.Errorf("IsOpen/IsLiquid on Monday")
+	}
+	if c.IsOpen(at(28, 12, 0)) || !c.IsHoliday(at(28, 12, 0)) || c.IsHoliday(at(27, 12, 0)) {
+		t.Errorf("Thanksgiving not closed")
+	}
+	if open, ok := c.NextOpen(at(27, 21, 0)); !ok || !open.Equal(at(29, 4, 0)) {
+		t.Errorf("NextOpen() = %v, %v, want Friday 04:00", open, ok)
+	}
+	if end, ok := c.NextClose(at(29, 12, 0)); !ok || !end.Equal(at(29, 17, 0)) {
+		t.Errorf("NextClose() = %v, %v, want Friday 17:00", end, ok)
+	}
+	if _, ok := c.NextOpen(at(29, 12, 0)); ok {
+		t.Errorf("NextOpen() after the calendar ok = true")
+	}
+	if half := c.HalfDays(); !slices.Equal(half, []string{"20241129"}) || !c.IsHalfDay(at(29, 10, 0)) {
+		t.Errorf("HalfDays() = %v", half)
+	}
+	if s := c.SessionsOn("20241126"); len(s) != 1 || !s[0].Start.Equal(at(26, 4, 0)) {
+		t.Errorf("SessionsOn() = %v", s)
+	}
+}
+
+func TestParseTradingHoursOldFormat(t *testing.T) {
+	loc := time.UTC
+	sessions, closed, err := parseTradingHours("20090507:0700-1830,1830-2330;20090508:1700-1600;20090509:CLOSED", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 3 || !slices.Equal(closed, []string{"20090509"}) {
+		t.Fatalf("sessions = %v, closed = %v", sessions, closed)
+	}
+	// Overnight session.
+	if want := time.Date(2009, 5, 9, 16, 0, 0, 0, loc); !sessions[2].End.Equal(want) {
+		t.Errorf("overnight end = %v, want %v", sessions[2].End, want)
+	}
+	if _, _, err := parseTradingHours("20090507:07-18", loc); err == nil {
+		t.Errorf("parseTradingHours() of an invalid time = nil error")
+	}
+}
+
+func TestTradingCalendarFromSchedule(t *testing.T) {
+	hs := HistoricalSchedule{
+		TimeZone: "US/Eastern",
+		Sessions: []HistoricalSession{
+			{StartDateTime: "20241127-09:30:00", EndDateTime: "20241127-16:00:00", RefDate: "20241127"},
+			{StartDateTime: "20241129-09:30:00", EndDateTime: "20241129-13:00:00", RefDate: "20241129"},
+			{StartDateTime: "20241202-09:30:00", EndDateTime: "20241202-16:00:00", RefDate: "20241202"},
+		},
+	}
+	c, err := NewTradingCalendarFromSchedule(hs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(c.Holidays, []string{"20241128"}) {
+		t.Errorf("Holidays = %v", c.Holidays)
+	}
+	if !slices.Equal(c.HalfDays(), []string{"20241129"}) {
+		t.Errorf("HalfDays() = %v", c.HalfDays())
+	}
+}
+
+func TestCalendarCache(t *testing.T) {
+	c, err := NewTradingCalendar(ContractDetails{TimeZoneID: "UTC", TradingHours: "20240102:0900-20240102:1700;20240103:0900-20240103:1700"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := newCalendarCache()
+	cc.set(1, c)
+	if _, ok := cc.get(1, time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)); !ok {
+		t.Errorf("get() within the calendar ok = false")
+	}
+	if _, ok := cc.get(1, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)); ok {
+		t.Errorf("get() on the last day ok = true, want a refresh")
+	}
+	if _, ok := cc.get(2, time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)); ok {
+		t.Errorf("get() of an unknown ConID ok = true")
+	}
+}
@@ -17,13 +17,15 @@ const (
 // Config holds the connection parameters for the client.
 // This struct centralizes all the configurable options for creating a connection.
 type Config struct {
-	Host     string        // Host address for the connection
-	Port     int           // Port number for the connection
-	ClientID int64         // Client ID, default is randomized for uniqueness
-	InSync   bool          // Stay in sync with server
-	Timeout  time.Duration // Timeout for the connection
-	ReadOnly bool          // Indicates if the client should be in read-only mode
-	Account  string        // Optional account identifier
+	Host             string           // Host address for the connection
+	Port             int              // Port number for the connection
+	ClientID         int64            // Client ID, default is randomized for uniqueness
+	InSync           bool             // Stay in sync with server
+	Timeout          time.Duration    // Timeout for the connection
+	ReadOnly         bool             // Indicates if the client should be in read-only mode
+	Account          string           // Optional account identifier
+	MarketDataPolicy MarketDataPolicy // Market data type requested on connection, zero to keep the TWS setting
+	ContractCache    *ContractCache   // Optional cache of the contract details, nil by default
 }
 
 // NewConfig creates a new Config with default values, and applies any functional options.
@@ -90,3 +92,20 @@ func WithTimeout(timeout time.Duration) func(*Config) {
 		c.Timeout = timeout
 	}
 }
+
+// WithMarketDataPolicy is a functional option to set the market data policy requested on connection,
+// e.g. MarketDataLiveOrDelayed to fall back to delayed data for the contracts without subscription.
+func WithMarketDataPolicy(policy MarketDataPolicy) func(*Config) {
+	return func(c *Config) {
+		c.MarketDataPolicy = policy
+	}
+}
+
+// WithContractCache is a functional option to enable a contract details cache,
+// e.g. a NewContractCache or a NewFileContractCache persisted across sessions, possibly shared by several clients.
+// The cached details are served for the ttl of the cache, including the fields which change daily, like TradingHours.
+func WithContractCache(cache *ContractCache) func(*Config) {
+	return func(c *Config) {
+		c.ContractCache = cache
+	}
+}
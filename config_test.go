@@ -77,3 +77,11 @@ func TestWithTimeout(t *testing.T) {
 		t.Errorf("expected Timeout to be %v, got %v", customTimeout, config.Timeout)
 	}
 }
+
+func TestWithMarketDataPolicy(t *testing.T) {
+	config := NewConfig(WithMarketDataPolicy(MarketDataLiveOrDelayed))
+
+	if config.MarketDataPolicy != MarketDataLiveOrDelayed {
+		t.Errorf("expected MarketDataPolicy to be %v, got %v", MarketDataLiveOrDelayed, config.MarketDataPolicy)
+	}
+}
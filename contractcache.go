@@ -0,0 +1,189 @@
+package ibsync
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+type contractCacheEntry struct {
+	Details []ContractDetails
+	Time    time.Time
+}
+
+// contractCacheData is the persisted content of a ContractCache.
+type contractCacheData struct {
+	ByKey   map[string]contractCacheEntry
+	ByConID map[string]contractCacheEntry
+}
+
+// ContractCache caches the results of ReqContractDetails, and therefore of QualifyContract.
+//
+// It is disabled by default, see WithContractCache.
+// Details are keyed by the fields of the request and by the ConID, alone and with the exchange, of each returned contract,
+// so that a contract qualified by symbol is also found by ConID. Unknown contracts and errors are not cached.
+// Entries expire after the ttl, zero for never. If the cache has a file, it is loaded on creation and saved on every change.
+type ContractCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	path    string
+	byKey   map[string]contractCacheEntry
+	byConID map[string]contractCacheEntry
+	now     func() time.Time
+}
+
+// NewContractCache creates an in-memory cache.
+func NewContractCache(ttl time.Duration) *ContractCache {
+	return &ContractCache{
+		ttl:     ttl,
+		byKey:   make(map[string]contractCacheEntry),
+		byConID: make(map[string]contractCacheEntry),
+		now:     time.Now,
+	}
+}
+
+// NewFileContractCache creates a cache persisted in the file at path. The expired entries of the file are dropped.
+func NewFileContractCache(path string, ttl time.Duration) (*ContractCache, error) {
+	c := NewContractCache(ttl)
+	c.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var d contractCacheData
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&d); err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("<ContractCache> corrupted file, starting empty")
+		return c, nil
+	}
+	for k, e := range d.ByKey {
+		if !c.expired(e) {
+			c.byKey[k] = e
+		}
+	}
+	for k, e := range d.ByConID {
+		if !c.expired(e) {
+			c.byConID[k] = e
+		}
+	}
+	return c, nil
+}
+
+// contractRequestKey returns the key of the request fields of a contract.
+func contractRequestKey(c *Contract) string {
+	return Key(c.ConID, c.Symbol, c.SecType, c.LastTradeDateOrContractMonth, c.Strike, c.Right, c.Multiplier,
+		c.Exchange, c.PrimaryExchange, c.Currency, c.LocalSymbol, c.TradingClass, c.IncludeExpired, c.SecIDType, c.SecID)
+}
+
+// contractConIDKey returns the key of a contract by ConID and exchange, or by ConID alone with an empty exchange.
+func contractConIDKey(conID int64, exchange string) string {
+	return Key(conID, exchange)
+}
+
+func (c *ContractCache) expired(e contractCacheEntry) bool {
+	return c.ttl > 0 && c.now().Sub(e.Time) >= c.ttl
+}
+
+// Get returns the cached details of a contract request.
+// A request with a ConID, and optionally an exchange, also matches the details of any request which returned that contract.
+func (c *ContractCache) Get(contract *Contract) ([]ContractDetails, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.byKey[contractRequestKey(contract)]
+	if !ok && contract.ConID != 0 {
+		e, ok = c.byConID[contractConIDKey(contract.ConID, contract.Exchange)]
+	}
+	if !ok || c.expired(e) {
+		return nil, false
+	}
+	return slices.Clone(e.Details), true
+}
+
+// Set caches the details returned for a contract request. Empty details are ignored.
+func (c *ContractCache) Set(contract *Contract, cds []ContractDetails) {
+	if len(cds) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.byKey[contractRequestKey(contract)] = contractCacheEntry{Details: slices.Clone(cds), Time: now}
+	for _, cd := range cds {
+		entry := contractCacheEntry{Details: []ContractDetails{cd}, Time: now}
+		c.byConID[contractConIDKey(cd.Contract.ConID, cd.Contract.Exchange)] = entry
+		c.byConID[contractConIDKey(cd.Contract.ConID, "")] = entry
+	}
+	c.save()
+}
+
+// Invalidate removes a contract request from the cache, with every entry holding the contracts it returned or its ConID.
+func (c *ContractCache) Invalidate(contract *Contract) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conIDs := make(map[int64]bool)
+	if contract.ConID != 0 {
+		conIDs[contract.ConID] = true
+	}
+	key := contractRequestKey(contract)
+	for _, cd := range c.byKey[key].Details {
+		conIDs[cd.Contract.ConID] = true
+	}
+	delete(c.byKey, key)
+	holds := func(e contractCacheEntry) bool {
+		return slices.ContainsFunc(e.Details, func(cd ContractDetails) bool { return conIDs[cd.Contract.ConID] })
+	}
+	for k, e := range c.byKey {
+		if holds(e) {
+			delete(c.byKey, k)
+		}
+	}
+	for k, e := range c.byConID {
+		if holds(e) {
+			delete(c.byConID, k)
+		}
+	}
+	c.save()
+}
+
+// Clear empties the cache.
+func (c *ContractCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.byKey)
+	clear(c.byConID)
+	c.save()
+}
+
+// Len returns the number of cached requests, expired or not.
+func (c *ContractCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.byKey)
+}
+
+// save writes the cache to its file, if any. It is written to a temporary file renamed on success.
+// Errors are logged: the in-memory cache stays usable.
+func (c *ContractCache) save() {
+	if c.path == "" {
+		return
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(contractCacheData{ByKey: c.byKey, ByConID: c.byConID}); err != nil {
+		log.Warn().Err(err).Msg("<ContractCache> encode")
+		return
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		log.Warn().Err(err).Str("path", c.path).Msg("<ContractCache> save")
+		return
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		log.Warn().Err(err).Str("path", c.path).Msg("<ContractCache> save")
+	}
+}
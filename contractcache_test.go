@@ -0,0 +1,81 @@
+package ibsync
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func contractDetails(conID int64, symbol, exchange string) ContractDetails {
+	cd := ContractDetails{LongName: symbol}
+	cd.Contract = *NewStock(symbol, exchange, "USD")
+	cd.Contract.ConID = conID
+	cd.MinTick = 0.01
+	return cd
+}
+
+func TestContractCache(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	c := NewContractCache(time.Hour)
+	c.now = func() time.Time { return now }
+
+	request := NewStock("AAPL", "SMART", "USD")
+	c.Set(request, []ContractDetails{contractDetails(265598, "AAPL", "SMART")})
+	c.Set(NewStock("XXX", "SMART", "USD"), nil)
+	if c.Len() != 1 {
+		t.Errorf("Len() = %v, want 1", c.Len())
+	}
+	if cds, ok := c.Get(NewStock("AAPL", "SMART", "USD")); !ok || len(cds) != 1 || cds[0].Contract.ConID != 265598 {
+		t.Errorf("Get() by request = %v, %v", cds, ok)
+	}
+	if cds, ok := c.Get(&Contract{ConID: 265598, Exchange: "SMART"}); !ok || cds[0].LongName != "AAPL" {
+		t.Errorf("Get() by ConID = %v, %v", cds, ok)
+	}
+	if cds, ok := c.Get(&Contract{ConID: 265598}); !ok || cds[0].LongName != "AAPL" {
+		t.Errorf("Get() by ConID alone = %v, %v", cds, ok)
+	}
+	if _, ok := c.Get(&Contract{ConID: 265598, Exchange: "NASDAQ"}); ok {
+		t.Errorf("Get() by ConID on another exchange ok = true")
+	}
+	if _, ok := c.Get(NewStock("AAPL", "SMART", "EUR")); ok {
+		t.Errorf("Get() of another request ok = true")
+	}
+
+	now = now.Add(time.Hour)
+	if _, ok := c.Get(request); ok {
+		t.Errorf("Get() of an expired entry ok = true")
+	}
+
+	c.Set(request, []ContractDetails{contractDetails(265598, "AAPL", "SMART")})
+	c.Invalidate(&Contract{ConID: 265598})
+	if _, ok := c.Get(request); ok {
+		t.Errorf("Get() after Invalidate() by ConID ok = true")
+	}
+	if _, ok := c.Get(&Contract{ConID: 265598}); ok {
+		t.Errorf("Get() by ConID after Invalidate() ok = true")
+	}
+}
+
+func TestFileContractCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contracts.gob")
+	c, err := NewFileContractCache(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := NewStock("MSFT", "SMART", "USD")
+	c.Set(request, []ContractDetails{contractDetails(272093, "MSFT", "SMART")})
+
+	reloaded, err := NewFileContractCache(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cds, ok := reloaded.Get(request)
+	if !ok || len(cds) != 1 || cds[0].Contract.ConID != 272093 || cds[0].MinTick != 0.01 {
+		t.Fatalf("Get() after reload = %v, %v", cds, ok)
+	}
+
+	reloaded.Clear()
+	if reloaded, err = NewFileContractCache(path, 0); err != nil || reloaded.Len() != 0 {
+		t.Errorf("Len() after Clear() and reload = %v, %v", reloaded.Len(), err)
+	}
+}
@@ -0,0 +1,264 @@
+package ibsync
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// contractField is a positional field of the text description of a contract.
+type contractField struct {
+	name   string
+	parse  func(c *Contract, s string) error
+	format func(c *Contract) string
+}
+
+var (
+	exchangeField = contractField{
+		name:   "exchange",
+		parse:  func(c *Contract, s string) error { c.Exchange = s; return nil },
+		format: func(c *Contract) string { return c.Exchange },
+	}
+	currencyField = contractField{
+		name:   "currency",
+		parse:  func(c *Contract, s string) error { c.Currency = s; return nil },
+		format: func(c *Contract) string { return c.Currency },
+	}
+	multiplierField = contractField{
+		name: "multiplier",
+		parse: func(c *Contract, s string) error {
+			if _, err := strconv.ParseFloat(s, 64); err != nil {
+				return fmt.Errorf("invalid multiplier %q", s)
+			}
+			c.Multiplier = s
+			return nil
+		},
+		format: func(c *Contract) string { return c.Multiplier },
+	}
+	expiryField = contractField{
+		name: "expiry",
+		parse: func(c *Contract, s string) error {
+			if !validExpiry(s) {
+				return fmt.Errorf("invalid expiry %q, want YYYYMM or YYYYMMDD", s)
+			}
+			c.LastTradeDateOrContractMonth = s
+			return nil
+		},
+		format: func(c *Contract) string { return c.LastTradeDateOrContractMonth },
+	}
+	strikeField = contractField{
+		name: "strike",
+		parse: func(c *Contract, s string) error {
+			strike, err := strconv.ParseFloat(s, 64)
+			if err != nil || strike <= 0 || math.IsInf(strike, 0) {
+				return fmt.Errorf("invalid strike %q", s)
+			}
+			c.Strike = strike
+			return nil
+		},
+		format: func(c *Contract) string {
+			if c.Strike == UNSET_FLOAT || c.Strike == 0 {
+				return ""
+			}
+			return strconv.FormatFloat(c.Strike, 'f', -1, 64)
+		},
+	}
+	rightField = contractField{
+		name: "right",
+		parse: func(c *Contract, s string) error {
+			right, ok := normalizeRight(s)
+			if !ok {
+				return fmt.Errorf("invalid right %q, want C, P, CALL or PUT", s)
+			}
+			c.Right = right
+			return nil
+		},
+		format: func(c *Contract) string {
+			right, _ := normalizeRight(c.Right)
+			return right
+		},
+	}
+)
+
+// contractLayouts are the positional fields following the symbol and the security type, and the number of required ones.
+var contractLayouts = map[string]struct {
+	fields   []contractField
+	required int
+}{
+	"STK":     {[]contractField{exchangeField, currencyField}, 0},
+	"IND":     {[]contractField{exchangeField, currencyField}, 0},
+	"CFD":     {[]contractField{exchangeField, currencyField}, 0},
+	"CASH":    {[]contractField{exchangeField, currencyField}, 0},
+	"CMDTY":   {[]contractField{exchangeField, currencyField}, 0},
+	"BOND":    {[]contractField{exchangeField, currencyField}, 0},
+	"CRYPTO":  {[]contractField{exchangeField, currencyField}, 0},
+	"FUND":    {[]contractField{exchangeField, currencyField}, 0},
+	"WAR":     {[]contractField{exchangeField, currencyField}, 0},
+	"CONTFUT": {[]contractField{exchangeField, currencyField, multiplierField}, 0},
+	"FUT":     {[]contractField{expiryField, exchangeField, currencyField, multiplierField}, 1},
+	"OPT":     {[]contractField{expiryField, strikeField, rightField, exchangeField, currencyField, multiplierField}, 3},
+	"FOP":     {[]contractField{expiryField, strikeField, rightField, exchangeField, currencyField, multiplierField}, 3},
+}
+
+// emptyContractField is the placeholder of an empty field in the text description of a contract.
+const emptyContractField = "-"
+
+func validExpiry(s string) bool {
+	switch len(s) {
+	case 6:
+		_, err := time.Parse("200601", s)
+		return err == nil
+	case 8:
+		_, err := time.Parse("20060102", s)
+		return err == nil
+	}
+	return false
+}
+
+func normalizeRight(s string) (string, bool) {
+	switch strings.ToUpper(s) {
+	case "C", "CALL":
+		return "C", true
+	case "P", "PUT":
+		return "P", true
+	}
+	return "", false
+}
+
+// ParseContract parses the text description of a contract: the symbol, the security type and its positional fields,
+// separated by spaces. Trailing fields can be omitted, and "-" leaves a field empty.
+//
+//	STK, IND, CFD, CASH, CMDTY, BOND, CRYPTO, FUND, WAR:  SYMBOL TYPE [EXCHANGE [CURRENCY]]
+//	CONTFUT:  SYMBOL CONTFUT [EXCHANGE [CURRENCY [MULTIPLIER]]]
+//	FUT:      SYMBOL FUT EXPIRY [EXCHANGE [CURRENCY [MULTIPLIER]]]
+//	OPT, FOP: SYMBOL OPT EXPIRY STRIKE RIGHT [EXCHANGE [CURRENCY [MULTIPLIER]]]
+//
+// For example "AAPL STK SMART USD", "ES FUT 202612 CME" or "SPY OPT 20261218 500 C SMART USD 100".
+// The contracts are the ones of the NewStock, NewFuture, NewOption... constructors. Errors wrap ErrInvalidContract.
+func ParseContract(s string) (*Contract, error) {
+	tokens := strings.Fields(s)
+	if len(tokens) < 2 {
+		return nil, fmt.Errorf("%w %q: want at least a symbol and a security type", ErrInvalidContract, s)
+	}
+	secType := strings.ToUpper(tokens[1])
+	layout, ok := contractLayouts[secType]
+	if !ok {
+		return nil, fmt.Errorf("%w %q: unknown security type %q", ErrInvalidContract, s, tokens[1])
+	}
+	values := tokens[2:]
+	if len(values) < layout.required {
+		return nil, fmt.Errorf("%w %q: %v requires %v", ErrInvalidContract, s, secType, fieldNames(layout.fields[:layout.required]))
+	}
+	if len(values) > len(layout.fields) {
+		return nil, fmt.Errorf("%w %q: too many fields, %v takes %v", ErrInvalidContract, s, secType, fieldNames(layout.fields))
+	}
+
+	contract := NewContract()
+	contract.Symbol = tokens[0]
+	contract.SecType = secType
+	for i, v := range values {
+		if v == emptyContractField {
+			if i < layout.required {
+				return nil, fmt.Errorf("%w %q: missing %v", ErrInvalidContract, s, layout.fields[i].name)
+			}
+			continue
+		}
+		if err := layout.fields[i].parse(contract, v); err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidContract, s, err)
+		}
+	}
+	return contract, nil
+}
+
+func fieldNames(fields []contractField) string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// FormatContract returns the text description of a contract parsed by ParseContract.
+// Empty fields are written "-", and the trailing ones are omitted.
+// The fields not part of the description, e.g. the ConID, are lost.
+func FormatContract(c *Contract) string {
+	tokens := []string{c.Symbol, c.SecType}
+	if layout, ok := contractLayouts[c.SecType]; ok {
+		for _, f := range layout.fields {
+			v := f.format(c)
+			if v == "" {
+				v = emptyContractField
+			}
+			tokens = append(tokens, v)
+		}
+	}
+	for len(tokens) > 2 && tokens[len(tokens)-1] == emptyContractField {
+		tokens = tokens[:len(tokens)-1]
+	}
+	return strings.Join(tokens, " ")
+}
+
+// ParseOCC parses an OCC option symbol, e.g. "SPY   261218C00500000": the root padded to 6 characters,
+// the expiration date YYMMDD, the right and the strike times 1000 on 8 digits. The padding is optional.
+//
+// The option is a US equity option on SMART with a multiplier of 100, and the OCC symbol as LocalSymbol.
+// The root is both the Symbol and the TradingClass. For roots other than the underlying symbol, e.g. SPXW,
+// set the Symbol to the underlying before qualifying the contract. Errors wrap ErrInvalidContract.
+func ParseOCC(s string) (*Contract, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 16 {
+		return nil, fmt.Errorf("%w: OCC symbol %q too short", ErrInvalidContract, s)
+	}
+	tail := s[len(s)-15:]
+	root := strings.TrimSpace(s[:len(s)-15])
+	if root == "" || len(root) > 6 || strings.ContainsAny(root, " \t") {
+		return nil, fmt.Errorf("%w: OCC symbol %q: invalid root %q", ErrInvalidContract, s, root)
+	}
+	expiry, err := time.Parse("060102", tail[:6])
+	if err != nil {
+		return nil, fmt.Errorf("%w: OCC symbol %q: invalid expiration date %q", ErrInvalidContract, s, tail[:6])
+	}
+	right := tail[6:7]
+	if right != "C" && right != "P" {
+		return nil, fmt.Errorf("%w: OCC symbol %q: invalid right %q", ErrInvalidContract, s, right)
+	}
+	strike, err := strconv.ParseUint(tail[7:], 10, 64)
+	if err != nil || strike == 0 {
+		return nil, fmt.Errorf("%w: OCC symbol %q: invalid strike %q", ErrInvalidContract, s, tail[7:])
+	}
+
+	contract := NewOption(root, expiry.Format("20060102"), float64(strike)/1000, right, "SMART", "100", "USD")
+	contract.TradingClass = root
+	contract.LocalSymbol = fmt.Sprintf("%-6s%s", root, tail)
+	return contract, nil
+}
+
+// FormatOCC returns the OCC symbol of an option, with the root padded to 6 characters.
+// The root is the TradingClass, or the Symbol if unset. The expiry must be a date, YYYYMMDD.
+func FormatOCC(c *Contract) (string, error) {
+	root := c.TradingClass
+	if root == "" {
+		root = c.Symbol
+	}
+	if root == "" || len(root) > 6 {
+		return "", fmt.Errorf("%w: OCC symbol: invalid root %q", ErrInvalidContract, root)
+	}
+	if c.SecType != "OPT" {
+		return "", fmt.Errorf("%w: OCC symbol: security type %q, want OPT", ErrInvalidContract, c.SecType)
+	}
+	expiry, err := time.Parse("20060102", c.LastTradeDateOrContractMonth)
+	if err != nil {
+		return "", fmt.Errorf("%w: OCC symbol: invalid expiry %q, want YYYYMMDD", ErrInvalidContract, c.LastTradeDateOrContractMonth)
+	}
+	right, ok := normalizeRight(c.Right)
+	if !ok {
+		return "", fmt.Errorf("%w: OCC symbol: invalid right %q", ErrInvalidContract, c.Right)
+	}
+	strike := math.Round(c.Strike * 1000)
+	if c.Strike == UNSET_FLOAT || strike <= 0 || strike > 99999999 {
+		return "", fmt.Errorf("%w: OCC symbol: invalid strike %v", ErrInvalidContract, c.Strike)
+	}
+	return fmt.Sprintf("%-6s%s%s%08d", root, expiry.Format("060102"), right, int64(strike)), nil
+}
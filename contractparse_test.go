@@ -0,0 +1,77 @@
+package ibsync
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseContract(t *testing.T) {
+	tests := []struct {
+		s    string
+		want *Contract
+	}{
+		{"AAPL STK SMART USD", NewStock("AAPL", "SMART", "USD")},
+		{"aapl stk", NewStock("aapl", "", "")},
+		{"ES FUT 202612 CME", NewFuture("ES", "202612", "CME", "", "")},
+		{"ES FUT 202612 CME - 50", NewFuture("ES", "202612", "CME", "50", "")},
+		{"SPY OPT 20261218 500.5 call SMART USD 100", NewOption("SPY", "20261218", 500.5, "C", "SMART", "100", "USD")},
+		{"EUR CASH IDEALPRO USD", NewForex("EUR", "IDEALPRO", "USD")},
+	}
+	for _, tt := range tests {
+		got, err := ParseContract(tt.s)
+		if err != nil {
+			t.Errorf("ParseContract(%q) error: %v", tt.s, err)
+			continue
+		}
+		if !got.Equal(tt.want) || got.Strike != tt.want.Strike || got.Multiplier != tt.want.Multiplier {
+			t.Errorf("ParseContract(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+
+	for _, s := range []string{"", "AAPL", "AAPL XYZ", "AAPL STK SMART USD EXTRA", "ES FUT", "ES FUT 2026", "ES FUT 20261332",
+		"SPY OPT 20261218 500", "SPY OPT 20261218 abc C", "SPY OPT 20261218 500 X", "ES FUT 202612 CME USD fifty", "ES FUT -"} {
+		if _, err := ParseContract(s); !errors.Is(err, ErrInvalidContract) {
+			t.Errorf("ParseContract(%q) error = %v, want ErrInvalidContract", s, err)
+		}
+	}
+}
+
+func TestFormatContract(t *testing.T) {
+	for _, s := range []string{"AAPL STK SMART USD", "ES FUT 202612 CME - 50", "SPY OPT 20261218 500.5 C SMART USD 100", "SPX IND"} {
+		c, err := ParseContract(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := FormatContract(c); got != s {
+			t.Errorf("FormatContract(ParseContract(%q)) = %q", s, got)
+		}
+	}
+}
+
+func TestOCC(t *testing.T) {
+	c, err := ParseOCC("SPY   261218C00500000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := NewOption("SPY", "20261218", 500, "C", "SMART", "100", "USD"); !c.Equal(want) || c.Strike != 500 || c.TradingClass != "SPY" {
+		t.Errorf("ParseOCC() = %+v", c)
+	}
+	if c.LocalSymbol != "SPY   261218C00500000" {
+		t.Errorf("LocalSymbol = %q", c.LocalSymbol)
+	}
+	if c, err = ParseOCC("SPXW261218P05012500"); err != nil || c.Strike != 5012.5 || c.Right != "P" {
+		t.Errorf("ParseOCC() without padding = %+v, %v", c, err)
+	}
+	if s, err := FormatOCC(c); err != nil || s != "SPXW  261218P05012500" {
+		t.Errorf("FormatOCC() = %q, %v", s, err)
+	}
+
+	for _, s := range []string{"", "261218C00500000", "TOOLONG261218C00500000", "SPY   261318C00500000", "SPY   261218X00500000", "SPY   261218C0050000A", "SPY   261218C00000000"} {
+		if _, err := ParseOCC(s); !errors.Is(err, ErrInvalidContract) {
+			t.Errorf("ParseOCC(%q) error = %v, want ErrInvalidContract", s, err)
+		}
+	}
+	if _, err := FormatOCC(NewFuture("ES", "202612", "CME", "50", "USD")); !errors.Is(err, ErrInvalidContract) {
+		t.Errorf("FormatOCC() of a future error = %v", err)
+	}
+}
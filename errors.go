@@ -3,6 +3,7 @@ package ibsync
 import (
 	"errors"
 	"slices"
+	"strings"
 
 	"github.com/scmhub/ibapi"
 )
@@ -12,7 +13,12 @@ var (
 	ErrNotPaperTrading     = errors.New("this account is not a paper trading account")
 	ErrNotFinancialAdvisor = errors.New("this account is not a financial advisor account")
 	ErrAmbiguousContract   = errors.New("ambiguous contract")
+	ErrUnknownContract     = errors.New("unknown contract")
 	ErrNoDataSubscription  = errors.New("no data subscription")
+	ErrInvalidContract     = errors.New("invalid contract")
+
+	ErrOptionPriceOutOfBounds = errors.New("option price out of no-arbitrage bounds")
+	ErrImpliedVolNotFound     = errors.New("implied volatility not found")
 )
 
 // Internal Errors
@@ -81,3 +87,21 @@ func normaliseCodeMsgPair(cmp ibapi.CodeMsgPair) ibapi.CodeMsgPair {
 		return cmp
 	}
 }
+
+// IsPacingViolation checks if err is an historical data pacing violation (error 162).
+func IsPacingViolation(err error) bool {
+	var cmp ibapi.CodeMsgPair
+	if !errors.As(err, &cmp) {
+		return false
+	}
+	return cmp.Code == 162 && strings.Contains(strings.ToLower(cmp.Msg), "pacing violation")
+}
+
+// isNoHistoricalData checks if err is the error 162 sent when an historical data query returned no data.
+func isNoHistoricalData(err error) bool {
+	var cmp ibapi.CodeMsgPair
+	if !errors.As(err, &cmp) {
+		return false
+	}
+	return cmp.Code == 162 && strings.Contains(strings.ToLower(cmp.Msg), "returned no data")
+}
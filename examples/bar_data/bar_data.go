@@ -54,9 +54,9 @@ func main() {
 
 	// Historical Data
 	endDateTime := lastWednesday12ESTString // format "yyyymmdd HH:mm:ss ttt", where "ttt" is an optional time zone
-	duration := "1 D"                       // "60 S", "30 D", "13 W", "6 M", "10 Y". The unit must be specified (S for seconds, D for days, W for weeks, etc.).
-	barSize := "15 mins"                    // "1 secs", "5 secs", "10 secs", "15 secs", "30 secs", "1 min", "2 mins", "5 mins", etc.
-	whatToShow := "MIDPOINT"                // "TRADES", "MIDPOINT", "BID", "ASK", "BID_ASK", "HISTORICAL_VOLATILITY", etc.
+	duration := ibsync.DurationDays(1)      // "60 S", "30 D", "13 W", "6 M", "10 Y". The unit must be specified (S for seconds, D for days, W for weeks, etc.).
+	barSize := ibsync.BarSize15Mins         // "1 secs", "5 secs", "10 secs", "15 secs", "30 secs", "1 min", "2 mins", "5 mins", etc.
+	whatToShow := ibsync.ShowMidpoint       // "TRADES", "MIDPOINT", "BID", "ASK", "BID_ASK", "HISTORICAL_VOLATILITY", etc.
 	useRTH := true                          // `true` limits data to regular trading hours (RTH), `false` includes all data.
 	formatDate := 1                         // `1` for the "yyyymmdd HH:mm:ss ttt" format, or `2` for Unix timestamps.
 	barChan, _ := ib.ReqHistoricalData(eurusd, endDateTime, duration, barSize, whatToShow, useRTH, formatDate)
@@ -72,8 +72,8 @@ func main() {
 	fmt.Println("Last Bar", bars[len(bars)-1])
 
 	// Historical Data with realtime Updates
-	duration = "60 S"
-	barSize = "1 secs"
+	duration = ibsync.DurationSeconds(60)
+	barSize = ibsync.BarSize1Sec
 	barChan, cancel := ib.ReqHistoricalDataUpToDate(eurusd, duration, barSize, whatToShow, useRTH, formatDate)
 
 	go func() {
@@ -100,7 +100,7 @@ func main() {
 	}
 
 	// Real time bars
-	whatToShow = "MIDPOINT" // "TRADES", "MIDPOINT", "BID" or "ASK"
+	whatToShow = ibsync.ShowMidpoint // "TRADES", "MIDPOINT", "BID" or "ASK"
 	rtBarChan, cancel := ib.ReqRealTimeBars(eurusd, 5, whatToShow, useRTH)
 
 	var rtBars []ibsync.RealTimeBar
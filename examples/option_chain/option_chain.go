@@ -103,5 +103,21 @@ func main() {
 	}
 	fmt.Printf("Implied Volatility: %.2f%%, was expecting: %.2f%%\n", impliedVol.ImpliedVol*100, greeks.ImpliedVol*100+1)
 
+	// Option chain matrix: the 2 nearest expirations within 30 days, 3 strikes around the money.
+	matrix, err := ib.OptionChain(spx,
+		ibsync.OptionChainDTE(1, 30),
+		ibsync.OptionChainExpirations(2),
+		ibsync.OptionChainStrikesAround(3),
+	)
+	if err != nil {
+		panic(fmt.Errorf("option chain: %v", err))
+	}
+	fmt.Printf("Option chain %v %v, underlying price: %.2f\n", matrix.TradingClass, matrix.Exchange, matrix.UnderlyingPrice)
+	for _, q := range matrix.Quotes() {
+		g := q.Greeks()
+		fmt.Printf("%v %8.2f %v bid: %8.2f ask: %8.2f iv: %6.2f%% delta: %5.2f\n",
+			q.Contract.LastTradeDateOrContractMonth, q.Contract.Strike, q.Contract.Right, q.Bid(), q.Ask(), g.ImpliedVol*100, g.Delta)
+	}
+
 	log.Info().Msg("Good Bye!!!")
 }
@@ -1,10 +1,8 @@
 package main
 
 import (
-	"encoding/xml"
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/rs/zerolog"
 	"github.com/scmhub/ibsync"
@@ -33,43 +31,6 @@ func save2File(xmlData string, filePath string) {
 	}
 }
 
-func parseXML(xmlData string) ([]string, error) {
-	// Define types for XML structure
-	type AbstractField struct {
-		Code string `xml:"code"`
-	}
-	type RangeFilter struct {
-		AbstractFields []AbstractField `xml:"AbstractField"`
-	}
-	type FilterList struct {
-		RangeFilters []RangeFilter `xml:"RangeFilter"`
-	}
-
-	type ScanParameterResponse struct {
-		XMLName     xml.Name     `xml:"ScanParameterResponse"`
-		FilterLists []FilterList `xml:"FilterList"`
-	}
-
-	// Parse the XML
-	var response ScanParameterResponse
-	err := xml.Unmarshal([]byte(xmlData), &response)
-	if err != nil {
-		return nil, err
-	}
-
-	// Extract all <code> values
-	var codes []string
-	for _, fls := range response.FilterLists {
-		for _, rfs := range fls.RangeFilters {
-			for _, af := range rfs.AbstractFields {
-				codes = append(codes, af.Code)
-			}
-		}
-	}
-
-	return codes, nil
-}
-
 func main() {
 	// We get ibsync logger
 	log := ibsync.Logger()
@@ -135,22 +96,24 @@ func main() {
 		fmt.Printf("%v\n", scan)
 	}
 
-	// Filter scanner data the new way
-	// View all tags
-	tags, err := parseXML(xml)
+	// Filter scanner data the new way, validated against the scanner parameters
+	catalogue, err := ibsync.ParseScannerParameters(xml)
 	if err != nil {
 		log.Error().Err(err).Msg("parsing xml string")
 		return
 	}
-	fmt.Printf("nb tags: %v, first 10 tags:%v...\n", len(tags), strings.Join(tags[:10], ", "))
+	fmt.Printf("nb instruments: %v, nb locations: %v, nb scan codes: %v, nb filters: %v\n",
+		len(catalogue.Instruments), len(catalogue.Locations), len(catalogue.ScanCodes), len(catalogue.Filters))
 
 	// AbovPrice is now priceAbove
-	opts := ibsync.ScannerSubscriptionOptions{
-		FilterOptions: []ibsync.TagValue{
-			{Tag: "changePercAbove", Value: "20"},
-			{Tag: "priceAbove", Value: "5"},
-			{Tag: "priceBelow", Value: "50"},
-		},
+	scanSubscription, opts, err := catalogue.NewScannerSubscription("STK", "STK.US.MAJOR", "TOP_PERC_GAIN",
+		ibsync.ScannerFilterValue("changePercAbove", 20),
+		ibsync.ScannerFilterValue("priceAbove", 5),
+		ibsync.ScannerFilterValue("priceBelow", 50),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid scanner subscription")
+		return
 	}
 
 	newFilterScanDatas, err := ib.ReqScannerSubscription(scanSubscription, opts)
@@ -0,0 +1,353 @@
+package ibsync
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FaAllocationMethod is the method used by a Financial Advisor group to allocate an order among its accounts.
+type FaAllocationMethod string
+
+const (
+	FaAvailableEquity   FaAllocationMethod = "AvailableEquity"   // Allocation proportional to the available equity of the accounts
+	FaEqual             FaAllocationMethod = "Equal"             // Same quantity for each account
+	FaNetLiq            FaAllocationMethod = "NetLiq"            // Allocation proportional to the net liquidation value of the accounts
+	FaContractsOrShares FaAllocationMethod = "ContractsOrShares" // Amount is the quantity allocated to the account
+	FaPercent           FaAllocationMethod = "Percent"           // Amount is the percentage of the order allocated to the account
+	FaRatio             FaAllocationMethod = "Ratio"             // Amount is the ratio of the order allocated to the account
+	FaMonetaryAmount    FaAllocationMethod = "MonetaryAmount"    // Amount is the monetary amount allocated to the account
+)
+
+// IsValid returns true if the method is a known allocation method.
+func (m FaAllocationMethod) IsValid() bool {
+	switch m {
+	case FaAvailableEquity, FaEqual, FaNetLiq, FaContractsOrShares, FaPercent, FaRatio, FaMonetaryAmount:
+		return true
+	}
+	return false
+}
+
+// NeedsAmount returns true if the method uses the amount of each account of the group.
+func (m FaAllocationMethod) NeedsAmount() bool {
+	switch m {
+	case FaContractsOrShares, FaPercent, FaRatio, FaMonetaryAmount:
+		return true
+	}
+	return false
+}
+
+// FaGroupAccount is an account of a Financial Advisor group.
+type FaGroupAccount struct {
+	Account string
+	Amount  float64 // Allocation amount. Only used by the methods that need an amount.
+}
+
+// FaGroup is a Financial Advisor allocation group.
+type FaGroup struct {
+	Name     string
+	Method   FaAllocationMethod
+	Accounts []FaGroupAccount
+}
+
+// Equal checks if two groups have the same name, method and accounts. The order of the accounts does not matter.
+func (g FaGroup) Equal(other FaGroup) bool {
+	if g.Name != other.Name || g.Method != other.Method || len(g.Accounts) != len(other.Accounts) {
+		return false
+	}
+	amounts := make(map[string]float64, len(g.Accounts))
+	for _, a := range g.Accounts {
+		amounts[a.Account] = a.Amount
+	}
+	for _, a := range other.Accounts {
+		amount, ok := amounts[a.Account]
+		if !ok || amount != a.Amount {
+			return false
+		}
+	}
+	return true
+}
+
+// FaAccountAlias is the alias of a managed account.
+type FaAccountAlias struct {
+	Account string
+	Alias   string
+}
+
+// XML representation of the FA groups, as returned by RequestFA(GROUPS).
+type xmlFaGroups struct {
+	XMLName xml.Name     `xml:"ListOfGroups"`
+	Groups  []xmlFaGroup `xml:"Group"`
+}
+
+type xmlFaGroup struct {
+	Name          string         `xml:"name"`
+	DefaultMethod string         `xml:"defaultMethod"`
+	ListOfAccts   xmlFaListAccts `xml:"ListOfAccts"`
+}
+
+type xmlFaListAccts struct {
+	VarName  string         `xml:"varName,attr"`
+	Accounts []xmlFaAccount `xml:"Account"`
+}
+
+type xmlFaAccount struct {
+	Acct   string `xml:"acct"`
+	Amount string `xml:"amount"`
+}
+
+// XML representation of the FA aliases, as returned by RequestFA(ALIASES).
+type xmlFaAliases struct {
+	XMLName xml.Name        `xml:"ListOfAccountAliases"`
+	Aliases []xmlFaAccAlias `xml:"AccountAlias"`
+}
+
+type xmlFaAccAlias struct {
+	Account string `xml:"account"`
+	Alias   string `xml:"alias"`
+}
+
+// ParseFaGroups parses the FA groups XML returned by RequestFA(GROUPS).
+func ParseFaGroups(cxml string) ([]FaGroup, error) {
+	var x xmlFaGroups
+	if err := xml.Unmarshal([]byte(cxml), &x); err != nil {
+		return nil, fmt.Errorf("parse FA groups: %w", err)
+	}
+	groups := make([]FaGroup, 0, len(x.Groups))
+	for _, xg := range x.Groups {
+		g := FaGroup{
+			Name:   strings.TrimSpace(xg.Name),
+			Method: FaAllocationMethod(strings.TrimSpace(xg.DefaultMethod)),
+		}
+		for _, xa := range xg.ListOfAccts.Accounts {
+			a := FaGroupAccount{Account: strings.TrimSpace(xa.Acct)}
+			if amount := strings.TrimSpace(xa.Amount); amount != "" {
+				f, err := strconv.ParseFloat(amount, 64)
+				if err != nil {
+					return nil, fmt.Errorf("parse FA groups: group %q account %q amount: %w", g.Name, a.Account, err)
+				}
+				a.Amount = f
+			}
+			g.Accounts = append(g.Accounts, a)
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// formatFaAmount formats an amount the way TWS does, always with a decimal point.
+func formatFaAmount(f float64) string {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// FaGroupsXML serialises the FA groups to the XML expected by ReplaceFA(GROUPS, cxml).
+func FaGroupsXML(groups []FaGroup) (string, error) {
+	x := xmlFaGroups{Groups: make([]xmlFaGroup, 0, len(groups))}
+	for _, g := range groups {
+		xg := xmlFaGroup{
+			Name:          g.Name,
+			DefaultMethod: string(g.Method),
+			ListOfAccts:   xmlFaListAccts{VarName: "list"},
+		}
+		for _, a := range g.Accounts {
+			xg.ListOfAccts.Accounts = append(xg.ListOfAccts.Accounts, xmlFaAccount{Acct: a.Account, Amount: formatFaAmount(a.Amount)})
+		}
+		x.Groups = append(x.Groups, xg)
+	}
+	b, err := xml.MarshalIndent(x, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(b), nil
+}
+
+// ParseFaAliases parses the FA aliases XML returned by RequestFA(ALIASES).
+func ParseFaAliases(cxml string) ([]FaAccountAlias, error) {
+	var x xmlFaAliases
+	if err := xml.Unmarshal([]byte(cxml), &x); err != nil {
+		return nil, fmt.Errorf("parse FA aliases: %w", err)
+	}
+	aliases := make([]FaAccountAlias, 0, len(x.Aliases))
+	for _, xa := range x.Aliases {
+		aliases = append(aliases, FaAccountAlias{Account: strings.TrimSpace(xa.Account), Alias: strings.TrimSpace(xa.Alias)})
+	}
+	return aliases, nil
+}
+
+// FaAliasesXML serialises the account aliases to the XML expected by ReplaceFA(ALIASES, cxml).
+func FaAliasesXML(aliases []FaAccountAlias) (string, error) {
+	x := xmlFaAliases{Aliases: make([]xmlFaAccAlias, 0, len(aliases))}
+	for _, a := range aliases {
+		x.Aliases = append(x.Aliases, xmlFaAccAlias{Account: a.Account, Alias: a.Alias})
+	}
+	b, err := xml.MarshalIndent(x, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(b), nil
+}
+
+// ValidateFaGroups checks the FA groups before they are sent to TWS.
+//
+// Group names must be non empty and unique, methods must be known, accounts must be unique within a group
+// and, if managedAccounts is not empty, belong to the managed accounts.
+// Methods using amounts need a non zero amount for each account.
+// All the problems found are returned joined in a single error.
+func ValidateFaGroups(groups []FaGroup, managedAccounts []string) error {
+	var errs []error
+	names := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		if g.Name == "" {
+			errs = append(errs, errors.New("FA group with empty name"))
+		} else if names[g.Name] {
+			errs = append(errs, fmt.Errorf("FA group %q: duplicate name", g.Name))
+		}
+		names[g.Name] = true
+		if !g.Method.IsValid() {
+			errs = append(errs, fmt.Errorf("FA group %q: unknown allocation method %q", g.Name, g.Method))
+		}
+		if len(g.Accounts) == 0 {
+			errs = append(errs, fmt.Errorf("FA group %q: no account", g.Name))
+		}
+		accounts := make(map[string]bool, len(g.Accounts))
+		for _, a := range g.Accounts {
+			if accounts[a.Account] {
+				errs = append(errs, fmt.Errorf("FA group %q: duplicate account %q", g.Name, a.Account))
+			}
+			accounts[a.Account] = true
+			if len(managedAccounts) > 0 && !slices.Contains(managedAccounts, a.Account) {
+				errs = append(errs, fmt.Errorf("FA group %q: account %q is not a managed account", g.Name, a.Account))
+			}
+			if g.Method.NeedsAmount() && a.Amount == 0 {
+				errs = append(errs, fmt.Errorf("FA group %q: account %q needs an amount for method %v", g.Name, a.Account, g.Method))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FaGroupsDiff lists the changes between two FA group configurations.
+type FaGroupsDiff struct {
+	Added    []FaGroup // Groups to create
+	Modified []FaGroup // Groups to update, with their new definition
+	Removed  []FaGroup // Groups to delete, with their current definition
+}
+
+// IsEmpty returns true if there is no change.
+func (d FaGroupsDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Modified) == 0 && len(d.Removed) == 0
+}
+
+// DiffFaGroups returns the changes to apply to the current groups to get the desired groups.
+// Groups are matched by name.
+func DiffFaGroups(current, desired []FaGroup) FaGroupsDiff {
+	var d FaGroupsDiff
+	currentByName := make(map[string]FaGroup, len(current))
+	for _, g := range current {
+		currentByName[g.Name] = g
+	}
+	desiredNames := make(map[string]bool, len(desired))
+	for _, g := range desired {
+		desiredNames[g.Name] = true
+		c, ok := currentByName[g.Name]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, g)
+		case !c.Equal(g):
+			d.Modified = append(d.Modified, g)
+		}
+	}
+	for _, g := range current {
+		if !desiredNames[g.Name] {
+			d.Removed = append(d.Removed, g)
+		}
+	}
+	return d
+}
+
+// mergeFaGroups returns the current groups updated with the upserted groups and without the removed ones.
+// The order of the current groups is kept and new groups are appended.
+func mergeFaGroups(current []FaGroup, upsert []FaGroup, remove []string) []FaGroup {
+	updates := make(map[string]FaGroup, len(upsert))
+	for _, g := range upsert {
+		updates[g.Name] = g
+	}
+	merged := make([]FaGroup, 0, len(current)+len(upsert))
+	for _, g := range current {
+		if slices.Contains(remove, g.Name) {
+			continue
+		}
+		if u, ok := updates[g.Name]; ok {
+			g = u
+			delete(updates, g.Name)
+		}
+		merged = append(merged, g)
+	}
+	for _, g := range upsert {
+		if _, ok := updates[g.Name]; ok && !slices.Contains(remove, g.Name) {
+			merged = append(merged, g)
+		}
+	}
+	return merged
+}
+
+// RequestFaGroups requests and parses the FA groups.
+func (ib *IB) RequestFaGroups() ([]FaGroup, error) {
+	cxml, err := ib.RequestFA(GROUPS)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFaGroups(cxml)
+}
+
+// RequestFaAliases requests and parses the account aliases.
+func (ib *IB) RequestFaAliases() ([]FaAccountAlias, error) {
+	cxml, err := ib.RequestFA(ALIASES)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFaAliases(cxml)
+}
+
+// ReplaceFaGroups validates the groups against the managed accounts and replaces the whole FA groups configuration.
+// It returns the text sent back by TWS.
+func (ib *IB) ReplaceFaGroups(groups []FaGroup) (string, error) {
+	if err := ValidateFaGroups(groups, ib.ManagedAccounts()); err != nil {
+		return "", err
+	}
+	cxml, err := FaGroupsXML(groups)
+	if err != nil {
+		return "", err
+	}
+	return ib.ReplaceFA(GROUPS, cxml)
+}
+
+// ApplyFaGroups creates or updates the upsert groups and deletes the remove groups, leaving the other groups untouched.
+//
+// The current configuration is requested from TWS and the merged configuration is validated against the managed accounts
+// before being sent with ReplaceFA. Nothing is sent if there is no change.
+// It returns the applied changes.
+func (ib *IB) ApplyFaGroups(upsert []FaGroup, remove ...string) (FaGroupsDiff, error) {
+	current, err := ib.RequestFaGroups()
+	if err != nil {
+		return FaGroupsDiff{}, err
+	}
+	desired := mergeFaGroups(current, upsert, remove)
+	diff := DiffFaGroups(current, desired)
+	if diff.IsEmpty() {
+		return diff, nil
+	}
+	text, err := ib.ReplaceFaGroups(desired)
+	if err != nil {
+		return FaGroupsDiff{}, err
+	}
+	log.Debug().Str("text", text).Msg("<ApplyFaGroups>")
+	return diff, nil
+}
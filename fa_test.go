@@ -0,0 +1,151 @@
+package ibsync
+
+import (
+	"strings"
+	"testing"
+)
+
+const testFaGroupsXML = `<?xml version="1.0" encoding="UTF-8"?>
+<ListOfGroups>
+  <Group>
+    <name>Equal_Group</name>
+    <defaultMethod>Equal</defaultMethod>
+    <ListOfAccts varName="list">
+      <Account>
+        <acct>DU111</acct>
+        <amount>0.0</amount>
+      </Account>
+      <Account>
+        <acct>DU222</acct>
+        <amount>0.0</amount>
+      </Account>
+    </ListOfAccts>
+  </Group>
+  <Group>
+    <name>Pct_Group</name>
+    <defaultMethod>Percent</defaultMethod>
+    <ListOfAccts varName="list">
+      <Account>
+        <acct>DU111</acct>
+        <amount>60.0</amount>
+      </Account>
+      <Account>
+        <acct>DU222</acct>
+        <amount>40.0</amount>
+      </Account>
+    </ListOfAccts>
+  </Group>
+</ListOfGroups>`
+
+func TestParseFaGroups(t *testing.T) {
+	groups, err := ParseFaGroups(testFaGroupsXML)
+	if err != nil {
+		t.Fatalf("ParseFaGroups() error = %v", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("ParseFaGroups() len = %v, want %v", len(groups), 2)
+	}
+	want := FaGroup{Name: "Pct_Group", Method: FaPercent, Accounts: []FaGroupAccount{{"DU111", 60}, {"DU222", 40}}}
+	if !groups[1].Equal(want) {
+		t.Errorf("ParseFaGroups()[1] = %v, want %v", groups[1], want)
+	}
+
+	// Round trip
+	cxml, err := FaGroupsXML(groups)
+	if err != nil {
+		t.Fatalf("FaGroupsXML() error = %v", err)
+	}
+	if !strings.Contains(cxml, `<ListOfAccts varName="list">`) || !strings.Contains(cxml, "<amount>60.0</amount>") {
+		t.Errorf("FaGroupsXML() = %v", cxml)
+	}
+	again, err := ParseFaGroups(cxml)
+	if err != nil {
+		t.Fatalf("ParseFaGroups(FaGroupsXML()) error = %v", err)
+	}
+	if diff := DiffFaGroups(groups, again); !diff.IsEmpty() {
+		t.Errorf("round trip diff = %+v, want empty", diff)
+	}
+
+	if _, err := ParseFaGroups("<ListOfGroups><Group><amount>"); err == nil {
+		t.Errorf("ParseFaGroups() with invalid xml: expected error")
+	}
+}
+
+func TestParseFaAliases(t *testing.T) {
+	aliases := []FaAccountAlias{{"DU111", "Alice"}, {"DU222", "Bob"}}
+	cxml, err := FaAliasesXML(aliases)
+	if err != nil {
+		t.Fatalf("FaAliasesXML() error = %v", err)
+	}
+	got, err := ParseFaAliases(cxml)
+	if err != nil {
+		t.Fatalf("ParseFaAliases() error = %v", err)
+	}
+	if len(got) != 2 || got[0] != aliases[0] || got[1] != aliases[1] {
+		t.Errorf("ParseFaAliases() = %v, want %v", got, aliases)
+	}
+}
+
+func TestValidateFaGroups(t *testing.T) {
+	managed := []string{"DU111", "DU222"}
+	tests := []struct {
+		name    string
+		groups  []FaGroup
+		wantErr string
+	}{
+		{"valid", []FaGroup{{"G", FaEqual, []FaGroupAccount{{"DU111", 0}}}}, ""},
+		{"empty name", []FaGroup{{"", FaEqual, []FaGroupAccount{{"DU111", 0}}}}, "empty name"},
+		{"duplicate name", []FaGroup{{"G", FaEqual, []FaGroupAccount{{"DU111", 0}}}, {"G", FaNetLiq, []FaGroupAccount{{"DU111", 0}}}}, "duplicate name"},
+		{"unknown method", []FaGroup{{"G", "PctChange", []FaGroupAccount{{"DU111", 0}}}}, "unknown allocation method"},
+		{"no account", []FaGroup{{"G", FaEqual, nil}}, "no account"},
+		{"unmanaged account", []FaGroup{{"G", FaEqual, []FaGroupAccount{{"DU999", 0}}}}, "not a managed account"},
+		{"duplicate account", []FaGroup{{"G", FaEqual, []FaGroupAccount{{"DU111", 0}, {"DU111", 0}}}}, "duplicate account"},
+		{"missing amount", []FaGroup{{"G", FaRatio, []FaGroupAccount{{"DU111", 1}, {"DU222", 0}}}}, "needs an amount"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFaGroups(tt.groups, managed)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateFaGroups() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateFaGroups() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiffFaGroups(t *testing.T) {
+	current, err := ParseFaGroups(testFaGroupsXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upsert := []FaGroup{
+		{"Pct_Group", FaPercent, []FaGroupAccount{{"DU222", 50}, {"DU111", 50}}},
+		{"New_Group", FaNetLiq, []FaGroupAccount{{"DU111", 0}}},
+	}
+	desired := mergeFaGroups(current, upsert, []string{"Equal_Group"})
+	if len(desired) != 2 || desired[0].Name != "Pct_Group" || desired[1].Name != "New_Group" {
+		t.Fatalf("mergeFaGroups() = %v", desired)
+	}
+
+	diff := DiffFaGroups(current, desired)
+	if len(diff.Added) != 1 || diff.Added[0].Name != "New_Group" {
+		t.Errorf("Added = %v", diff.Added)
+	}
+	if len(diff.Modified) != 1 || diff.Modified[0].Name != "Pct_Group" {
+		t.Errorf("Modified = %v", diff.Modified)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Name != "Equal_Group" {
+		t.Errorf("Removed = %v", diff.Removed)
+	}
+
+	// Same accounts in a different order is not a modification.
+	reordered := mergeFaGroups(current, []FaGroup{{"Pct_Group", FaPercent, []FaGroupAccount{{"DU222", 40}, {"DU111", 60}}}}, nil)
+	if diff := DiffFaGroups(current, reordered); !diff.IsEmpty() {
+		t.Errorf("DiffFaGroups() = %+v, want empty", diff)
+	}
+}
@@ -0,0 +1,446 @@
+package ibsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RollMethod is the rule selecting the front month of a futures chain.
+type RollMethod int
+
+const (
+	RollByCalendar     RollMethod = iota // Roll a number of days before the last trade date
+	RollByVolume                         // Roll when the next contract trades more than the front one
+	RollByOpenInterest                   // Roll when the next contract has more open interest than the front one, live only
+)
+
+func (m RollMethod) String() string {
+	switch m {
+	case RollByCalendar:
+		return "Calendar"
+	case RollByVolume:
+		return "Volume"
+	case RollByOpenInterest:
+		return "OpenInterest"
+	}
+	return "RollMethod(" + strconv.Itoa(int(m)) + ")"
+}
+
+// AdjustMethod is the adjustment of the prices of a continuous futures series at the rolls.
+type AdjustMethod int
+
+const (
+	AdjustNone       AdjustMethod = iota // Raw prices, with gaps at the rolls
+	AdjustDifference                     // Back-adjusted by adding the price difference at each roll
+	AdjustRatio                          // Back-adjusted by multiplying by the price ratio at each roll
+)
+
+func (m AdjustMethod) String() string {
+	switch m {
+	case AdjustNone:
+		return "None"
+	case AdjustDifference:
+		return "Difference"
+	case AdjustRatio:
+		return "Ratio"
+	}
+	return "AdjustMethod(" + strconv.Itoa(int(m)) + ")"
+}
+
+var errNoFuture = errors.New("no futures contract")
+
+// FuturesExpiry returns the last trade date of a futures contract, at midnight UTC.
+// It is zero if the contract details have no full date.
+func FuturesExpiry(cd ContractDetails) time.Time {
+	for _, date := range []string{cd.Contract.LastTradeDateOrContractMonth, cd.RealExpirationDate} {
+		if len(date) >= 8 {
+			if t, err := time.Parse("20060102", date[:8]); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// FuturesChain returns the futures contracts of a template, e.g. NewFuture("ES", "", "CME", "", "USD"), sorted by expiration.
+// With includeExpired, the expired contracts are listed too. They are needed to download the history of a continuous contract.
+func (ib *IB) FuturesChain(contract *Contract, includeExpired bool) ([]ContractDetails, error) {
+	template := *contract
+	template.SecType = "FUT"
+	template.LastTradeDateOrContractMonth = ""
+	template.IncludeExpired = includeExpired
+	cds, err := ib.ReqContractDetails(&template)
+	if err != nil {
+		return nil, err
+	}
+	cds = slices.DeleteFunc(cds, func(cd ContractDetails) bool { return FuturesExpiry(cd).IsZero() })
+	slices.SortFunc(cds, func(a, b ContractDetails) int { return FuturesExpiry(a).Compare(FuturesExpiry(b)) })
+	for i := range cds {
+		cds[i].Contract.IncludeExpired = includeExpired
+	}
+	return cds, nil
+}
+
+// frontByCalendar returns the index of the first contract of the chain more than daysBefore days before its expiration.
+func frontByCalendar(chain []ContractDetails, now time.Time, daysBefore int) int {
+	for i, cd := range chain {
+		if FuturesExpiry(cd).AddDate(0, 0, -daysBefore).After(now) {
+			return i
+		}
+	}
+	return -1
+}
+
+// FrontFuture returns the front month of a futures template.
+//
+// With RollByCalendar, it is the first contract more than daysBefore days before its last trade date.
+// With RollByVolume and RollByOpenInterest, it is the most active of the two nearest contracts, from live market data.
+func (ib *IB) FrontFuture(contract *Contract, method RollMethod, daysBefore int) (*Contract, error) {
+	chain, err := ib.FuturesChain(contract, false)
+	if err != nil {
+		return nil, err
+	}
+	if method == RollByCalendar {
+		i := frontByCalendar(chain, time.Now(), daysBefore)
+		if i < 0 {
+			return nil, errNoFuture
+		}
+		front := chain[i].Contract
+		return &front, nil
+	}
+	i := frontByCalendar(chain, time.Now(), 0)
+	if i < 0 {
+		return nil, errNoFuture
+	}
+	best, bestActivity := i, -1.0
+	for j := i; j < min(i+2, len(chain)); j++ {
+		c := chain[j].Contract
+		activity, err := ib.futuresActivity(&c, method)
+		if err != nil {
+			return nil, err
+		}
+		if activity > bestActivity {
+			best, bestActivity = j, activity
+		}
+	}
+	front := chain[best].Contract
+	return &front, nil
+}
+
+// futuresActivity returns the volume or the open interest of a futures contract.
+func (ib *IB) futuresActivity(contract *Contract, method RollMethod) (float64, error) {
+	if method == RollByVolume {
+		ticker, err := ib.Snapshot(contract)
+		if err != nil && !errors.Is(err, WarnDelayedMarketData) && !errors.Is(err, ErrPartlyNotSubsribed) && !errors.Is(err, WarnCompetingLiveSession) {
+			return 0, err
+		}
+		return decimalToFloat(ticker.Volume()), nil
+	}
+	// The open interest is a generic tick, not sent with snapshots.
+	ctx, cancel := context.WithTimeout(ib.eClient.Ctx(), ib.config.Timeout)
+	defer cancel()
+	ticker := ib.ReqMktData(contract, "588")
+	defer ib.CancelMktData(contract)
+	poll := time.NewTicker(100 * time.Millisecond)
+	defer poll.Stop()
+	for {
+		if oi := decimalToFloat(ticker.FuturesOpenInterest()); oi > 0 {
+			return oi, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("open interest of %v: %w", contract.LocalSymbol, ctx.Err())
+		case <-poll.C:
+		}
+	}
+}
+
+// RollPeriod is the period a contract is the front month of a continuous futures series, [Start, End).
+type RollPeriod struct {
+	Contract *Contract
+	Start    time.Time
+	End      time.Time
+}
+
+// CalendarRollSchedule returns the front months between start and end, rolling daysBefore days before each last trade date.
+// The chain must be sorted by expiration, as returned by FuturesChain.
+func CalendarRollSchedule(chain []ContractDetails, daysBefore int, start, end time.Time) []RollPeriod {
+	var periods []RollPeriod
+	from := start
+	for i := range chain {
+		roll := FuturesExpiry(chain[i]).AddDate(0, 0, -daysBefore)
+		if !roll.After(from) {
+			continue
+		}
+		c := chain[i].Contract
+		periods = append(periods, RollPeriod{Contract: &c, Start: from, End: minTime(roll, end)})
+		if !roll.Before(end) {
+			break
+		}
+		from = roll
+	}
+	return periods
+}
+
+// VolumeRollSchedule returns the front months between start and end, rolling the day after the next contract
+// trades more than the front one, and at the latest at the last trade date. The roll is never undone.
+// daily holds the daily bars of the contracts by ConID. The chain must be sorted by expiration, as returned by FuturesChain.
+func VolumeRollSchedule(chain []ContractDetails, daily map[int64][]Bar, start, end time.Time) []RollPeriod {
+	volumes := make([]map[int64]float64, len(chain))
+	var days []int64
+	for i, cd := range chain {
+		volumes[i] = make(map[int64]float64)
+		for _, bar := range daily[cd.Contract.ConID] {
+			t, err := barTime(bar)
+			if err != nil {
+				continue
+			}
+			day := t.Truncate(24 * time.Hour).Unix()
+			volumes[i][day] = decimalToFloat(bar.Volume)
+			if !slices.Contains(days, day) {
+				days = append(days, day)
+			}
+		}
+	}
+	slices.Sort(days)
+
+	current := slices.IndexFunc(chain, func(cd ContractDetails) bool { return FuturesExpiry(cd).After(start) })
+	if current < 0 {
+		return nil
+	}
+	var periods []RollPeriod
+	from := start
+	roll := func(at time.Time) bool {
+		if at.After(from) {
+			c := chain[current].Contract
+			periods = append(periods, RollPeriod{Contract: &c, Start: from, End: minTime(at, end)})
+			from = at
+		}
+		current++
+		return current < len(chain) && at.Before(end)
+	}
+	for _, day := range days {
+		d := time.Unix(day, 0).UTC()
+		if d.Before(from) {
+			continue
+		}
+		for current < len(chain) && !FuturesExpiry(chain[current]).After(d) {
+			if !roll(maxTime(FuturesExpiry(chain[current]), from)) {
+				return periods
+			}
+		}
+		if current+1 < len(chain) && volumes[current+1][day] > volumes[current][day] {
+			if !roll(d.AddDate(0, 0, 1)) {
+				return periods
+			}
+		}
+	}
+	for current < len(chain) && from.Before(end) {
+		if !roll(maxTime(FuturesExpiry(chain[current]), from)) {
+			break
+		}
+	}
+	return periods
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// FuturesSegment is the history of a contract of a continuous futures series.
+// Bars may start before the period, the overlap is used to measure the price gap at the roll.
+type FuturesSegment struct {
+	RollPeriod
+	Bars []Bar // Chronological bars
+}
+
+// StitchFutures stitches the segments of a continuous futures series, in chronological order, into one bar series.
+//
+// The gap at each roll is measured at the last bar of the old contract: its close against the close of the new contract
+// at the same time, or the latest one before. With back-adjustment, the bars before each roll are shifted by the gap,
+// so that the last prices are the real prices of the current contract.
+func StitchFutures(segments []FuturesSegment, adjust AdjustMethod) []Bar {
+	kept := make([][]Bar, len(segments))
+	for i, s := range segments {
+		for _, bar := range s.Bars {
+			t, err := barTime(bar)
+			if err != nil || t.Before(s.Start) || !t.Before(s.End) {
+				continue
+			}
+			kept[i] = append(kept[i], bar)
+		}
+	}
+
+	diff, ratio := 0.0, 1.0
+	for i := len(segments) - 2; i >= 0 && adjust != AdjustNone; i-- {
+		if oldClose, newClose, ok := rollPrices(kept[i], segments[i+1].Bars); ok {
+			diff += newClose - oldClose
+			ratio *= newClose / oldClose
+		}
+		for j := range kept[i] {
+			kept[i][j] = adjustBar(kept[i][j], adjust, diff, ratio)
+		}
+	}
+	var bars []Bar
+	for _, k := range kept {
+		bars = append(bars, k...)
+	}
+	return bars
+}
+
+// rollPrices returns the last close of the old contract and the close of the new contract at the same time, or the latest before.
+func rollPrices(old, next []Bar) (oldClose, newClose float64, ok bool) {
+	if len(old) == 0 || len(next) == 0 {
+		return 0, 0, false
+	}
+	last := old[len(old)-1]
+	t, err := barTime(last)
+	if err != nil {
+		return 0, 0, false
+	}
+	found := false
+	for _, bar := range next {
+		bt, err := barTime(bar)
+		if err != nil || bt.After(t) {
+			break
+		}
+		newClose, found = bar.Close, true
+	}
+	if !found || last.Close <= 0 || newClose <= 0 {
+		return 0, 0, false
+	}
+	return last.Close, newClose, true
+}
+
+func adjustBar(bar Bar, adjust AdjustMethod, diff, ratio float64) Bar {
+	f := func(p float64) float64 {
+		if adjust == AdjustRatio {
+			return p * ratio
+		}
+		return p + diff
+	}
+	bar.Open, bar.High, bar.Low, bar.Close = f(bar.Open), f(bar.High), f(bar.Low), f(bar.Close)
+	if wap := decimalToFloat(bar.Wap); wap > 0 {
+		bar.Wap = floatToDecimal(f(wap))
+	}
+	return bar
+}
+
+// ContinuousFutureOptions holds the options of ContinuousFuture.
+type ContinuousFutureOptions struct {
+	Roll       RollMethod   // RollByCalendar or RollByVolume, default RollByCalendar
+	DaysBefore int          // Days before the last trade date to roll with RollByCalendar, default 5
+	Adjust     AdjustMethod // Default AdjustDifference
+	UseRTH     bool
+}
+
+// ContinuousRollByCalendar is an option of ContinuousFuture to roll daysBefore days before the last trade dates.
+func ContinuousRollByCalendar(daysBefore int) func(*ContinuousFutureOptions) {
+	return func(o *ContinuousFutureOptions) {
+		o.Roll = RollByCalendar
+		o.DaysBefore = daysBefore
+	}
+}
+
+// ContinuousRollByVolume is an option of ContinuousFuture to roll when the next contract trades more than the front one.
+func ContinuousRollByVolume() func(*ContinuousFutureOptions) {
+	return func(o *ContinuousFutureOptions) {
+		o.Roll = RollByVolume
+	}
+}
+
+// ContinuousAdjust is an option of ContinuousFuture to set the price adjustment at the rolls.
+func ContinuousAdjust(adjust AdjustMethod) func(*ContinuousFutureOptions) {
+	return func(o *ContinuousFutureOptions) {
+		o.Adjust = adjust
+	}
+}
+
+// ContinuousUseRTH is an option of ContinuousFuture to only return data within regular trading hours.
+func ContinuousUseRTH() func(*ContinuousFutureOptions) {
+	return func(o *ContinuousFutureOptions) {
+		o.UseRTH = true
+	}
+}
+
+// rollOverlap is how long the history of a contract is downloaded before its roll period, to measure the gap.
+const rollOverlap = 7 * 24 * time.Hour
+
+// ContinuousFuture builds a continuous bar series of a futures template between start and end.
+//
+// The chain, expired contracts included, is rolled with the roll method and the history of each front month
+// downloaded with DownloadHistory, then stitched and adjusted with StitchFutures.
+// It returns the bars and the roll schedule.
+func (ib *IB) ContinuousFuture(contract *Contract, start, end time.Time, barSize BarSize, whatToShow WhatToShow, options ...func(*ContinuousFutureOptions)) ([]Bar, []RollPeriod, error) {
+	opts := ContinuousFutureOptions{Roll: RollByCalendar, DaysBefore: 5, Adjust: AdjustDifference}
+	for _, option := range options {
+		option(&opts)
+	}
+	if end.IsZero() {
+		end = time.Now()
+	}
+	var historyOptions []func(*HistoryOptions)
+	if opts.UseRTH {
+		historyOptions = append(historyOptions, HistoryUseRTH())
+	}
+
+	chain, err := ib.FuturesChain(contract, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	var schedule []RollPeriod
+	switch opts.Roll {
+	case RollByCalendar:
+		schedule = CalendarRollSchedule(chain, opts.DaysBefore, start, end)
+	case RollByVolume:
+		// The volume of a contract is needed from the last month of the previous one.
+		daily := make(map[int64][]Bar)
+		from := start
+		for _, cd := range chain {
+			expiry := FuturesExpiry(cd)
+			if !expiry.After(start) {
+				continue
+			}
+			c := cd.Contract
+			bars, err := ib.DownloadHistory(&c, from, minTime(expiry.AddDate(0, 0, 1), end), BarSize1Day, ShowTrades, historyOptions...)
+			if err != nil {
+				return nil, nil, fmt.Errorf("daily volume of %v: %w", c.LocalSymbol, err)
+			}
+			daily[c.ConID] = bars
+			if !expiry.Before(end) {
+				break
+			}
+			from = maxTime(start, expiry.AddDate(0, -1, 0))
+		}
+		schedule = VolumeRollSchedule(chain, daily, start, end)
+	default:
+		return nil, nil, fmt.Errorf("continuous future: unsupported roll method %v", opts.Roll)
+	}
+	if len(schedule) == 0 {
+		return nil, nil, errNoFuture
+	}
+
+	segments := make([]FuturesSegment, len(schedule))
+	for i, period := range schedule {
+		bars, err := ib.DownloadHistory(period.Contract, period.Start.Add(-rollOverlap), period.End, barSize, whatToShow, historyOptions...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("history of %v: %w", period.Contract.LocalSymbol, err)
+		}
+		segments[i] = FuturesSegment{RollPeriod: period, Bars: bars}
+	}
+	return StitchFutures(segments, opts.Adjust), schedule, nil
+}
@@ -0,0 +1,123 @@
+package ibsync
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func futuresChain() []ContractDetails {
+	var chain []ContractDetails
+	for i, expiry := range []string{"20240315", "20240621", "20240920"} {
+		cd := ContractDetails{RealExpirationDate: expiry}
+		cd.Contract = *NewFuture("ES", expiry, "CME", "50", "USD")
+		cd.Contract.ConID = int64(i + 1)
+		chain = append(chain, cd)
+	}
+	return chain
+}
+
+func date(s string) time.Time {
+	t, _ := time.Parse("20060102", s)
+	return t
+}
+
+func TestCalendarRollSchedule(t *testing.T) {
+	chain := futuresChain()
+	if i := frontByCalendar(chain, date("20240312"), 5); i != 1 {
+		t.Errorf("frontByCalendar() = %v, want 1", i)
+	}
+
+	periods := CalendarRollSchedule(chain, 5, date("20240101"), date("20240701"))
+	want := []struct {
+		conID      int64
+		start, end string
+	}{
+		{1, "20240101", "20240310"},
+		{2, "20240310", "20240616"},
+		{3, "20240616", "20240701"},
+	}
+	if len(periods) != len(want) {
+		t.Fatalf("periods = %+v", periods)
+	}
+	for i, w := range want {
+		p := periods[i]
+		if p.Contract.ConID != w.conID || !p.Start.Equal(date(w.start)) || !p.End.Equal(date(w.end)) {
+			t.Errorf("periods[%v] = %v %v-%v, want %+v", i, p.Contract.ConID, p.Start.Format("20060102"), p.End.Format("20060102"), w)
+		}
+	}
+}
+
+func dailyBar(day string, price float64, volume string) Bar {
+	bar := NewBar()
+	bar.Date = day
+	bar.Open, bar.High, bar.Low, bar.Close = price, price, price, price
+	bar.Volume = StringToDecimal(volume)
+	return bar
+}
+
+func TestVolumeRollSchedule(t *testing.T) {
+	chain := futuresChain()
+	daily := map[int64][]Bar{
+		1: {dailyBar("20240304", 0, "1000"), dailyBar("20240305", 0, "800"), dailyBar("20240306", 0, "300"), dailyBar("20240307", 0, "900")},
+		2: {dailyBar("20240304", 0, "100"), dailyBar("20240305", 0, "500"), dailyBar("20240306", 0, "700"), dailyBar("20240307", 0, "800")},
+	}
+	periods := VolumeRollSchedule(chain, daily, date("20240301"), date("20240401"))
+	if len(periods) != 2 {
+		t.Fatalf("periods = %+v", periods)
+	}
+	// The next contract trades more on the 6th: roll on the 7th, and never back.
+	if periods[0].Contract.ConID != 1 || !periods[0].End.Equal(date("20240307")) {
+		t.Errorf("periods[0] = %+v", periods[0])
+	}
+	if periods[1].Contract.ConID != 2 || !periods[1].Start.Equal(date("20240307")) || !periods[1].End.Equal(date("20240401")) {
+		t.Errorf("periods[1] = %+v", periods[1])
+	}
+
+	// Without volume, the roll happens at the expiration.
+	periods = VolumeRollSchedule(chain, nil, date("20240301"), date("20240401"))
+	if len(periods) != 2 || !periods[0].End.Equal(date("20240315")) {
+		t.Errorf("periods without volume = %+v", periods)
+	}
+}
+
+func TestStitchFutures(t *testing.T) {
+	old := FuturesSegment{
+		RollPeriod: RollPeriod{Start: date("20240301"), End: date("20240304")},
+		Bars:       []Bar{dailyBar("20240301", 100, "1"), dailyBar("20240302", 101, "1"), dailyBar("20240303", 102, "1"), dailyBar("20240304", 103, "1")},
+	}
+	next := FuturesSegment{
+		RollPeriod: RollPeriod{Start: date("20240304"), End: date("20240306")},
+		Bars:       []Bar{dailyBar("20240302", 110, "1"), dailyBar("20240303", 112.2, "1"), dailyBar("20240304", 113, "1"), dailyBar("20240305", 114, "1")},
+	}
+	segments := []FuturesSegment{old, next}
+
+	raw := StitchFutures(segments, AdjustNone)
+	closes := func(bars []Bar) []float64 {
+		var c []float64
+		for _, b := range bars {
+			c = append(c, b.Close)
+		}
+		return c
+	}
+	if got := closes(raw); len(got) != 5 || got[2] != 102 || got[3] != 113 {
+		t.Fatalf("raw closes = %v", got)
+	}
+
+	// The gap is measured on the 3rd, the last bar of the old contract: 112.2 - 102.
+	diff := closes(StitchFutures(segments, AdjustDifference))
+	for i, want := range []float64{110.2, 111.2, 112.2, 113, 114} {
+		if math.Abs(diff[i]-want) > 1e-9 {
+			t.Errorf("difference adjusted closes = %v", diff)
+			break
+		}
+	}
+	ratio := closes(StitchFutures(segments, AdjustRatio))
+	if math.Abs(ratio[0]-100*1.1) > 1e-9 || ratio[3] != 113 {
+		t.Errorf("ratio adjusted closes = %v", ratio)
+	}
+	// The segments are not modified.
+	if segments[0].Bars[0].Close != 100 {
+		t.Errorf("segment modified: %v", segments[0].Bars[0].Close)
+	}
+}
@@ -0,0 +1,359 @@
+package ibsync
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// FxRates holds exchange rates relative to a base currency.
+//
+// The rate of a currency is the value of one unit of that currency in the base currency,
+// e.g. with a USD base, the EUR rate is the EUR.USD price.
+type FxRates struct {
+	Base  string
+	rates map[string]float64
+}
+
+// NewFxRates creates an empty set of exchange rates for the base currency.
+func NewFxRates(base string) FxRates {
+	return FxRates{Base: base, rates: map[string]float64{base: 1}}
+}
+
+// Set sets the rate of a currency, i.e. the value of one unit of currency in the base currency.
+// Non positive and NaN rates are ignored.
+func (r FxRates) Set(currency string, rate float64) {
+	if currency == r.Base || rate <= 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return
+	}
+	r.rates[currency] = rate
+}
+
+// SetPair sets a rate from a currency pair quote: one unit of base is worth price units of quote.
+//
+// If neither currency of the pair is the base currency, the rate is derived from the already known rate of the other currency.
+// It returns false if the rate cannot be set.
+func (r FxRates) SetPair(base, quote string, price float64) bool {
+	if price <= 0 || math.IsNaN(price) || math.IsInf(price, 0) {
+		return false
+	}
+	if rate, ok := r.rates[quote]; ok {
+		r.Set(base, price*rate)
+		return true
+	}
+	if rate, ok := r.rates[base]; ok {
+		r.Set(quote, rate/price)
+		return true
+	}
+	return false
+}
+
+// Rate returns the value of one unit of currency in the base currency.
+func (r FxRates) Rate(currency string) (float64, bool) {
+	rate, ok := r.rates[currency]
+	return rate, ok
+}
+
+// Convert converts an amount from one currency to another.
+func (r FxRates) Convert(amount float64, from, to string) (float64, bool) {
+	if from == to {
+		return amount, true
+	}
+	fromRate, ok := r.rates[from]
+	if !ok {
+		return 0, false
+	}
+	toRate, ok := r.rates[to]
+	if !ok {
+		return 0, false
+	}
+	return amount * fromRate / toRate, true
+}
+
+// Currencies returns the sorted list of the currencies with a known rate, base included.
+func (r FxRates) Currencies() []string {
+	currencies := make([]string, 0, len(r.rates))
+	for c := range r.rates {
+		currencies = append(currencies, c)
+	}
+	slices.Sort(currencies)
+	return currencies
+}
+
+// accountBaseCurrency returns the base currency of an account from its account values.
+// It is the value of the "Currency" tag of the BASE currency or, if not received, the currency with an exchange rate of 1.
+func accountBaseCurrency(avs AccountValues, account string) string {
+	var candidate string
+	for _, av := range avs {
+		if av.Account != account {
+			continue
+		}
+		if av.Tag == "Currency" && av.Currency == "BASE" && av.Value != "" {
+			return av.Value
+		}
+		if av.Tag == "ExchangeRate" && av.Currency != "BASE" {
+			if rate, err := strconv.ParseFloat(av.Value, 64); err == nil && rate == 1 {
+				candidate = av.Currency
+			}
+		}
+	}
+	return candidate
+}
+
+// FxRatesFromAccountValues builds the exchange rates relative to base from the "ExchangeRate" account values.
+//
+// IB sends the exchange rates relative to the base currency of each account.
+// The rates of the first account with a usable base currency are used.
+func FxRatesFromAccountValues(base string, avs AccountValues) FxRates {
+	r := NewFxRates(base)
+	accounts := make([]string, 0)
+	for _, av := range avs {
+		if !slices.Contains(accounts, av.Account) {
+			accounts = append(accounts, av.Account)
+		}
+	}
+	slices.Sort(accounts)
+	for _, account := range accounts {
+		accountBase := accountBaseCurrency(avs, account)
+		if accountBase == "" {
+			continue
+		}
+		rates := NewFxRates(accountBase)
+		for _, av := range avs {
+			if av.Account != account || av.Tag != "ExchangeRate" || av.Currency == "BASE" {
+				continue
+			}
+			if rate, err := strconv.ParseFloat(av.Value, 64); err == nil {
+				rates.Set(av.Currency, rate)
+			}
+		}
+		baseRate, ok := rates.Rate(base)
+		if !ok {
+			continue
+		}
+		for c, rate := range rates.rates {
+			r.Set(c, rate/baseRate)
+		}
+		return r
+	}
+	return r
+}
+
+// SetTicker sets a rate from a forex ticker (contract SecType "CASH", e.g. NewForex("EUR", "IDEALPRO", "USD")).
+// The market price of the ticker is used. It returns false if the ticker is not a forex ticker or has no price.
+func (r FxRates) SetTicker(ticker *Ticker) bool {
+	contract := ticker.Contract()
+	if contract == nil || contract.SecType != "CASH" {
+		return false
+	}
+	return r.SetPair(contract.Symbol, contract.Currency, ticker.MarketPrice())
+}
+
+// BasePortfolioItem is a portfolio item with its values converted in the base currency.
+type BasePortfolioItem struct {
+	PortfolioItem
+	Currency      string  // Currency of the contract
+	FxRate        float64 // Value of one unit of Currency in the base currency, NaN if unknown
+	MarketValue   float64 // Market value in the base currency
+	UnrealizedPNL float64 // Unrealized PnL in the base currency
+	RealizedPNL   float64 // Realized PnL in the base currency
+}
+
+// CurrencyExposure is the exposure of a portfolio to a currency.
+type CurrencyExposure struct {
+	Currency     string
+	Positions    float64 // Market value of the positions in Currency
+	Cash         float64 // Cash balance in Currency
+	Exposure     float64 // Positions + Cash in Currency
+	FxRate       float64 // Value of one unit of Currency in the base currency, NaN if unknown
+	ExposureBase float64 // Exposure in the base currency, 0 if the rate is unknown
+	Weight       float64 // ExposureBase / NetValue of the portfolio
+}
+
+// FxHedge is a suggested forex trade to hedge a currency exposure.
+type FxHedge struct {
+	Currency string    // Hedged currency
+	Contract *Contract // Forex pair, e.g. EUR.USD on IDEALPRO
+	Action   string    // "BUY" or "SELL" the pair
+	Quantity float64   // Quantity of the pair, in units of its Symbol currency
+	Notional float64   // Hedged amount in the base currency, positive when reducing a long exposure
+}
+
+// BasePortfolio is a portfolio aggregated in a base currency.
+type BasePortfolio struct {
+	Base          string
+	Items         []BasePortfolioItem
+	Exposures     []CurrencyExposure
+	MarketValue   float64  // Market value of the positions in the base currency
+	Cash          float64  // Cash balances in the base currency
+	NetValue      float64  // MarketValue + Cash
+	UnrealizedPNL float64  // Unrealized PnL in the base currency
+	RealizedPNL   float64  // Realized PnL in the base currency
+	Missing       []string // Currencies without exchange rate, excluded from the totals
+}
+
+// NewBasePortfolio aggregates portfolio items and cash balances in the base currency.
+//
+// cash maps currencies to cash balances. Forex (CASH) portfolio items are skipped
+// as IB already reflects them in the cash balances.
+// The exposure of a position to its currency is its market value.
+func NewBasePortfolio(base string, items []PortfolioItem, cash map[string]float64, rates FxRates) BasePortfolio {
+	bp := BasePortfolio{Base: base}
+	exposures := make(map[string]*CurrencyExposure)
+	exposure := func(currency string) *CurrencyExposure {
+		e, ok := exposures[currency]
+		if !ok {
+			e = &CurrencyExposure{Currency: currency, FxRate: math.NaN()}
+			if rate, ok := rates.Rate(currency); ok {
+				e.FxRate = rate
+			}
+			exposures[currency] = e
+		}
+		return e
+	}
+
+	for _, pi := range items {
+		if pi.Contract == nil || pi.Contract.SecType == "CASH" {
+			continue
+		}
+		currency := pi.Contract.Currency
+		e := exposure(currency)
+		e.Positions += pi.MarketValue
+		item := BasePortfolioItem{PortfolioItem: pi, Currency: currency, FxRate: e.FxRate}
+		if !math.IsNaN(e.FxRate) {
+			item.MarketValue = pi.MarketValue * e.FxRate
+			item.UnrealizedPNL = pi.UnrealizedPNL * e.FxRate
+			item.RealizedPNL = pi.RealizedPNL * e.FxRate
+			bp.MarketValue += item.MarketValue
+			bp.UnrealizedPNL += item.UnrealizedPNL
+			bp.RealizedPNL += item.RealizedPNL
+		}
+		bp.Items = append(bp.Items, item)
+	}
+	for currency, amount := range cash {
+		e := exposure(currency)
+		e.Cash += amount
+		if !math.IsNaN(e.FxRate) {
+			bp.Cash += amount * e.FxRate
+		}
+	}
+	bp.NetValue = bp.MarketValue + bp.Cash
+
+	for _, e := range exposures {
+		e.Exposure = e.Positions + e.Cash
+		if math.IsNaN(e.FxRate) {
+			bp.Missing = append(bp.Missing, e.Currency)
+		} else {
+			e.ExposureBase = e.Exposure * e.FxRate
+			if bp.NetValue != 0 {
+				e.Weight = e.ExposureBase / bp.NetValue
+			}
+		}
+		bp.Exposures = append(bp.Exposures, *e)
+	}
+	slices.SortFunc(bp.Exposures, func(a, b CurrencyExposure) int { return cmp.Compare(a.Currency, b.Currency) })
+	slices.SortFunc(bp.Items, func(a, b BasePortfolioItem) int {
+		return cmp.Or(cmp.Compare(a.Account, b.Account), cmp.Compare(a.Contract.ConID, b.Contract.ConID))
+	})
+	slices.Sort(bp.Missing)
+	return bp
+}
+
+// fxPriority is the market convention order of the currencies in forex pairs: the first currency of a pair has the lower index.
+var fxPriority = []string{"EUR", "GBP", "AUD", "NZD", "USD", "CAD", "CHF", "NOK", "SEK", "DKK", "CNH", "HKD", "SGD", "MXN", "ZAR", "JPY"}
+
+// fxPair returns the symbol and currency of the forex pair of two currencies, following market conventions.
+func fxPair(c1, c2 string) (symbol, currency string) {
+	i1, i2 := slices.Index(fxPriority, c1), slices.Index(fxPriority, c2)
+	switch {
+	case i1 == -1 && i2 == -1:
+		if c1 < c2 {
+			return c1, c2
+		}
+		return c2, c1
+	case i1 == -1:
+		return c2, c1
+	case i2 == -1:
+		return c1, c2
+	case i1 < i2:
+		return c1, c2
+	default:
+		return c2, c1
+	}
+}
+
+// HedgeSuggestions returns the forex trades that hedge ratio (e.g. 1 for a full hedge) of the foreign currency exposures.
+//
+// Exposures in the base currency, without exchange rate or below minNotional in the base currency are not hedged.
+// Pairs are quoted on IDEALPRO following market conventions (EUR.USD, USD.JPY...).
+func (bp BasePortfolio) HedgeSuggestions(ratio float64, minNotional float64) []FxHedge {
+	var hedges []FxHedge
+	for _, e := range bp.Exposures {
+		if e.Currency == bp.Base || math.IsNaN(e.FxRate) || e.Exposure == 0 {
+			continue
+		}
+		notional := e.ExposureBase * ratio
+		if math.Abs(notional) < minNotional || notional == 0 {
+			continue
+		}
+		symbol, currency := fxPair(e.Currency, bp.Base)
+		h := FxHedge{
+			Currency: e.Currency,
+			Contract: NewForex(symbol, "IDEALPRO", currency),
+			Notional: notional,
+		}
+		// Selling the exposed currency reduces a long exposure.
+		sellExposed := notional > 0
+		if symbol == e.Currency {
+			h.Quantity = math.Abs(e.Exposure * ratio)
+		} else {
+			h.Quantity = math.Abs(notional)
+			sellExposed = !sellExposed
+		}
+		if sellExposed {
+			h.Action = "SELL"
+		} else {
+			h.Action = "BUY"
+		}
+		hedges = append(hedges, h)
+	}
+	return hedges
+}
+
+// FxRates returns the exchange rates relative to base.
+//
+// Rates come from the "ExchangeRate" account values and are overridden by the live forex tickers (SecType "CASH") with a price.
+// Account values need to be subscribed by ReqAccountUpdates. This is done at start up unless WithoutSync option is used.
+func (ib *IB) FxRates(base string) FxRates {
+	r := FxRatesFromAccountValues(base, ib.AccountValues())
+	ib.state.mu.Lock()
+	tickers := make([]*Ticker, 0)
+	for contract, ticker := range ib.state.tickers {
+		if contract.SecType == "CASH" {
+			tickers = append(tickers, ticker)
+		}
+	}
+	ib.state.mu.Unlock()
+	for _, t := range tickers {
+		r.SetTicker(t)
+	}
+	return r
+}
+
+// BasePortfolio returns the portfolio of the given accounts aggregated in the base currency.
+//
+// If no account is provided it will aggregate all accounts.
+// Cash balances are the "CashBalance" account values.
+func (ib *IB) BasePortfolio(base string, account ...string) BasePortfolio {
+	cash := make(map[string]float64)
+	for _, av := range ib.AccountValues(account...) {
+		if av.Tag != "CashBalance" || av.Currency == "BASE" || av.Currency == "" {
+			continue
+		}
+		if v, err := strconv.ParseFloat(av.Value, 64); err == nil {
+			cash[av.Currency] += v
+		}
+	}
+	return NewBasePortfolio(base, ib.Portfolio(account...), cash, ib.FxRates(base))
+}
@@ -0,0 +1,126 @@
+package ibsync
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFxRates(t *testing.T) {
+	avs := AccountValues{
+		{Account: "DU123", Tag: "Currency", Value: "USD", Currency: "BASE"},
+		{Account: "DU123", Tag: "ExchangeRate", Value: "1.00", Currency: "BASE"},
+		{Account: "DU123", Tag: "ExchangeRate", Value: "1.00", Currency: "USD"},
+		{Account: "DU123", Tag: "ExchangeRate", Value: "1.10", Currency: "EUR"},
+		{Account: "DU123", Tag: "ExchangeRate", Value: "0.0067", Currency: "JPY"},
+	}
+
+	usd := FxRatesFromAccountValues("USD", avs)
+	if rate, ok := usd.Rate("EUR"); !ok || !almostEqual(rate, 1.1) {
+		t.Errorf("USD Rate(EUR) = %v, %v, want %v", rate, ok, 1.1)
+	}
+
+	eur := FxRatesFromAccountValues("EUR", avs)
+	if rate, ok := eur.Rate("USD"); !ok || !almostEqual(rate, 1/1.1) {
+		t.Errorf("EUR Rate(USD) = %v, %v, want %v", rate, ok, 1/1.1)
+	}
+	if v, ok := eur.Convert(1000, "JPY", "USD"); !ok || !almostEqual(v, 6.7) {
+		t.Errorf("EUR Convert(1000 JPY, USD) = %v, %v, want %v", v, ok, 6.7)
+	}
+
+	// Base currency from its exchange rate of 1, however formatted
+	noTag := AccountValues{
+		{Account: "DU456", Tag: "ExchangeRate", Value: "1", Currency: "CHF"},
+		{Account: "DU456", Tag: "ExchangeRate", Value: "1.12", Currency: "USD"},
+	}
+	if base := accountBaseCurrency(noTag, "DU456"); base != "CHF" {
+		t.Errorf("accountBaseCurrency() = %v, want CHF", base)
+	}
+
+	// Live pair quotes
+	r := NewFxRates("USD")
+	if !r.SetPair("USD", "JPY", 150) {
+		t.Fatalf("SetPair(USD, JPY) = false")
+	}
+	if !r.SetPair("EUR", "JPY", 165) {
+		t.Fatalf("SetPair(EUR, JPY) = false")
+	}
+	if rate, _ := r.Rate("EUR"); !almostEqual(rate, 1.1) {
+		t.Errorf("Rate(EUR) from EUR.JPY cross = %v, want %v", rate, 1.1)
+	}
+	if r.SetPair("GBP", "CHF", 1.1) {
+		t.Errorf("SetPair(GBP, CHF) without known leg = true, want false")
+	}
+
+	ticker := NewTicker(NewForex("GBP", "IDEALPRO", "USD"))
+	ticker.SetTickPrice(TickPrice{TickType: BID, Price: 1.25})
+	ticker.SetTickPrice(TickPrice{TickType: ASK, Price: 1.27})
+	if !r.SetTicker(ticker) {
+		t.Fatalf("SetTicker(GBP.USD) = false")
+	}
+	if rate, _ := r.Rate("GBP"); !almostEqual(rate, 1.26) {
+		t.Errorf("Rate(GBP) = %v, want %v", rate, 1.26)
+	}
+}
+
+func TestBasePortfolio(t *testing.T) {
+	rates := NewFxRates("USD")
+	rates.Set("EUR", 1.1)
+	rates.Set("JPY", 0.0067)
+
+	items := []PortfolioItem{
+		{Account: "DU123", Contract: &Contract{ConID: 1, Symbol: "AAPL", SecType: "STK", Currency: "USD"}, MarketValue: 10000, UnrealizedPNL: 100},
+		{Account: "DU123", Contract: &Contract{ConID: 2, Symbol: "SAP", SecType: "STK", Currency: "EUR"}, MarketValue: 5000, UnrealizedPNL: -100, RealizedPNL: 50},
+		{Account: "DU123", Contract: &Contract{ConID: 3, Symbol: "7203", SecType: "STK", Currency: "JPY"}, MarketValue: 1000000},
+		{Account: "DU123", Contract: &Contract{ConID: 4, Symbol: "EUR", SecType: "CASH", Currency: "USD"}, MarketValue: 1100},
+		{Account: "DU123", Contract: &Contract{ConID: 5, Symbol: "BHP", SecType: "STK", Currency: "AUD"}, MarketValue: 3000},
+	}
+	cash := map[string]float64{"USD": -2000, "EUR": 1000}
+
+	bp := NewBasePortfolio("USD", items, cash, rates)
+
+	if len(bp.Items) != 4 {
+		t.Fatalf("Items len = %v, want %v", len(bp.Items), 4)
+	}
+	if !almostEqual(bp.MarketValue, 10000+5500+6700) || !almostEqual(bp.Cash, -2000+1100) {
+		t.Errorf("MarketValue, Cash = %v, %v, want %v, %v", bp.MarketValue, bp.Cash, 22200, -900)
+	}
+	if !almostEqual(bp.UnrealizedPNL, 100-110) || !almostEqual(bp.RealizedPNL, 55) {
+		t.Errorf("UnrealizedPNL, RealizedPNL = %v, %v, want %v, %v", bp.UnrealizedPNL, bp.RealizedPNL, -10, 55)
+	}
+	if len(bp.Missing) != 1 || bp.Missing[0] != "AUD" {
+		t.Errorf("Missing = %v, want [AUD]", bp.Missing)
+	}
+
+	exposures := make(map[string]CurrencyExposure)
+	for _, e := range bp.Exposures {
+		exposures[e.Currency] = e
+	}
+	if e := exposures["EUR"]; !almostEqual(e.Exposure, 6000) || !almostEqual(e.ExposureBase, 6600) || !almostEqual(e.Weight, 6600/bp.NetValue) {
+		t.Errorf("EUR exposure = %+v", e)
+	}
+	if e := exposures["AUD"]; !math.IsNaN(e.FxRate) || e.ExposureBase != 0 {
+		t.Errorf("AUD exposure = %+v", e)
+	}
+
+	hedges := bp.HedgeSuggestions(1, 100)
+	if len(hedges) != 2 {
+		t.Fatalf("HedgeSuggestions() len = %v, want %v", len(hedges), 2)
+	}
+	tests := []struct {
+		hedge    FxHedge
+		pair     string
+		action   string
+		quantity float64
+	}{
+		{hedges[0], "EUR.USD", "SELL", 6000},
+		{hedges[1], "USD.JPY", "BUY", 6700},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pair, func(t *testing.T) {
+			pair := tt.hedge.Contract.Symbol + "." + tt.hedge.Contract.Currency
+			if pair != tt.pair || tt.hedge.Action != tt.action || !almostEqual(tt.hedge.Quantity, tt.quantity) {
+				t.Errorf("hedge = %v %v %v, want %v %v %v", tt.hedge.Action, tt.hedge.Quantity, pair, tt.action, tt.quantity, tt.pair)
+			}
+		})
+	}
+}
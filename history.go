@@ -0,0 +1,349 @@
+package ibsync
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Historical data pacing limits.
+// https://www.interactivebrokers.com/campus/ibkr-api-page/twsapi-doc/#historical-pacing-limitations
+const (
+	historicalPacingWindow     = 10 * time.Minute // No more than historicalPacingRequests requests within this window
+	historicalPacingRequests   = 60
+	historicalIdenticalWindow  = 15 * time.Second // No identical requests within this window
+	historicalContractWindow   = 2 * time.Second  // No more than historicalContractRequests requests for the same contract, exchange and tick type within this window
+	historicalContractRequests = 5
+)
+
+// pacedRequest is an historical request recorded by the pacer.
+type pacedRequest struct {
+	time        time.Time
+	key         string // Identifies identical requests
+	contractKey string // Identifies the contract, exchange and tick type
+}
+
+// historicalPacer paces the historical data requests to avoid IB pacing violations.
+type historicalPacer struct {
+	mu       sync.Mutex
+	requests []pacedRequest
+	now      func() time.Time
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+// newHistoricalPacer creates a pacer using the wall clock.
+func newHistoricalPacer() *historicalPacer {
+	return &historicalPacer{
+		now: time.Now,
+		sleep: func(ctx context.Context, d time.Duration) error {
+			timer := time.NewTimer(d)
+			defer timer.Stop()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+				return nil
+			}
+		},
+	}
+}
+
+// delay returns how long to wait before sending a request, pruning the expired requests.
+// The pacer must be locked by the caller.
+func (p *historicalPacer) delay(now time.Time, key, contractKey string) time.Duration {
+	p.requests = slices.DeleteFunc(p.requests, func(r pacedRequest) bool {
+		return now.Sub(r.time) >= historicalPacingWindow
+	})
+	var wait time.Duration
+	if len(p.requests) >= historicalPacingRequests {
+		wait = max(wait, p.requests[len(p.requests)-historicalPacingRequests].time.Add(historicalPacingWindow).Sub(now))
+	}
+	var sameContract []time.Time
+	for _, r := range p.requests {
+		if r.key == key {
+			wait = max(wait, r.time.Add(historicalIdenticalWindow).Sub(now))
+		}
+		if r.contractKey == contractKey && now.Sub(r.time) < historicalContractWindow {
+			sameContract = append(sameContract, r.time)
+		}
+	}
+	if len(sameContract) >= historicalContractRequests {
+		wait = max(wait, sameContract[len(sameContract)-historicalContractRequests].Add(historicalContractWindow).Sub(now))
+	}
+	return wait
+}
+
+// wait blocks until the request can be sent without breaking the pacing rules, then records it.
+func (p *historicalPacer) wait(ctx context.Context, key, contractKey string) error {
+	for {
+		p.mu.Lock()
+		now := p.now()
+		d := p.delay(now, key, contractKey)
+		if d <= 0 {
+			p.requests = append(p.requests, pacedRequest{time: now, key: key, contractKey: contractKey})
+			p.mu.Unlock()
+			return nil
+		}
+		p.mu.Unlock()
+		log.Debug().Str("key", key).Dur("delay", d).Msg("<historicalPacer> waiting")
+		if err := p.sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// historicalContractKey identifies the contract, exchange and tick type of an historical request for pacing purposes.
+func historicalContractKey(contract *Contract, whatToShow WhatToShow) string {
+	return Key(contract.ConID, contract.Symbol, contract.SecType, contract.Exchange, contract.LastTradeDateOrContractMonth, whatToShow)
+}
+
+// historyChunk is the longest request allowed by IB for a bar size.
+type historyChunk struct {
+	duration HistoryDuration // Duration of the request
+	span     time.Duration   // Time covered by duration
+}
+
+const historyDay = 24 * time.Hour
+
+// historyChunks maps the bar sizes to the longest duration IB accepts for them.
+// https://www.interactivebrokers.com/campus/ibkr-api-page/twsapi-doc/#hist-duration
+var historyChunks = map[string]historyChunk{
+	"1 secs":  {"1800 S", 1800 * time.Second},
+	"5 secs":  {"3600 S", 3600 * time.Second},
+	"10 secs": {"14400 S", 14400 * time.Second},
+	"15 secs": {"14400 S", 14400 * time.Second},
+	"30 secs": {"28800 S", 28800 * time.Second},
+	"1 min":   {"1 D", historyDay},
+	"2 mins":  {"2 D", 2 * historyDay},
+	"3 mins":  {"1 W", 7 * historyDay},
+	"5 mins":  {"1 W", 7 * historyDay},
+	"10 mins": {"1 W", 7 * historyDay},
+	"15 mins": {"1 W", 7 * historyDay},
+	"20 mins": {"1 W", 7 * historyDay},
+	"30 mins": {"30 D", 30 * historyDay},
+	"1 hour":  {"30 D", 30 * historyDay},
+	"2 hours": {"30 D", 30 * historyDay},
+	"3 hours": {"30 D", 30 * historyDay},
+	"4 hours": {"30 D", 30 * historyDay},
+	"8 hours": {"30 D", 30 * historyDay},
+	"1 day":   {"1 Y", 365 * historyDay},
+	"1 week":  {"1 Y", 365 * historyDay},
+	"1 month": {"1 Y", 365 * historyDay},
+}
+
+// normalizeBarSize normalizes the bar size spellings accepted by IB, e.g. "1 sec" or "1 mins".
+func normalizeBarSize(barSize string) string {
+	fields := strings.Fields(barSize)
+	if len(fields) != 2 {
+		return barSize
+	}
+	n, unit := fields[0], strings.TrimSuffix(fields[1], "s")
+	switch unit {
+	case "sec":
+		return n + " secs"
+	case "min":
+		if n == "1" {
+			return "1 min"
+		}
+		return n + " mins"
+	case "hour":
+		if n == "1" {
+			return "1 hour"
+		}
+		return n + " hours"
+	}
+	return n + " " + unit
+}
+
+// historyChunkFor returns the longest legal request for a bar size.
+func historyChunkFor(barSize BarSize) (historyChunk, error) {
+	chunk, ok := historyChunks[normalizeBarSize(string(barSize))]
+	if !ok {
+		return historyChunk{}, fmt.Errorf("unsupported bar size %q", barSize)
+	}
+	return chunk, nil
+}
+
+// barSizeDuration returns the duration of a bar size. Months are counted as 31 days.
+func barSizeDuration(barSize string) (time.Duration, error) {
+	fields := strings.Fields(normalizeBarSize(barSize))
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("unsupported bar size %q", barSize)
+	}
+	n, err := strconv.Atoi(fields[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("unsupported bar size %q", barSize)
+	}
+	var unit time.Duration
+	switch strings.TrimSuffix(fields[1], "s") {
+	case "sec":
+		unit = time.Second
+	case "min":
+		unit = time.Minute
+	case "hour":
+		unit = time.Hour
+	case "day":
+		unit = historyDay
+	case "week":
+		unit = 7 * historyDay
+	case "month":
+		unit = 31 * historyDay
+	default:
+		return 0, fmt.Errorf("unsupported bar size %q", barSize)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// barTime parses the date of a bar.
+func barTime(bar Bar) (time.Time, error) {
+	return ParseIBTime(bar.Date)
+}
+
+// HistoryOptions holds the options of DownloadHistory.
+type HistoryOptions struct {
+	UseRTH     bool          // Only return data within regular trading hours
+	MaxRetries int           // Maximum number of retries of a chunk after a pacing violation
+	RetryDelay time.Duration // Delay before the first retry, doubled after each retry
+}
+
+// HistoryUseRTH is an option of DownloadHistory to only return data within regular trading hours.
+func HistoryUseRTH() func(*HistoryOptions) {
+	return func(o *HistoryOptions) {
+		o.UseRTH = true
+	}
+}
+
+// HistoryRetries is an option of DownloadHistory to set the number of retries and the initial delay after a pacing violation.
+func HistoryRetries(maxRetries int, delay time.Duration) func(*HistoryOptions) {
+	return func(o *HistoryOptions) {
+		o.MaxRetries = maxRetries
+		o.RetryDelay = delay
+	}
+}
+
+// reqHistoricalBars requests one chunk of historical bars and waits for all of them.
+// IB errors are returned as CodeMsgPair.
+func (ib *IB) reqHistoricalBars(ctx context.Context, contract *Contract, endDateTime string, duration HistoryDuration, barSize BarSize, whatToShow WhatToShow, useRTH bool) ([]Bar, error) {
+	ctx, cancel := context.WithTimeout(ctx, ib.config.Timeout)
+	defer cancel()
+
+	sub := ib.SubscribeHistoricalData(contract, endDateTime, duration, barSize, whatToShow, useRTH, 2)
+
+	var bars []Bar
+	for {
+		select {
+		case <-ctx.Done():
+			sub.Cancel()
+			return nil, ctx.Err()
+		case bar, ok := <-sub.Chan():
+			if !ok {
+				if err := sub.Err(); err != nil {
+					return nil, err
+				}
+				return bars, nil
+			}
+			bars = append(bars, bar)
+		}
+	}
+}
+
+// DownloadHistory downloads the historical bars of a contract between start and end.
+//
+// The range is split into the longest chunks IB accepts for the bar size, requested from end backwards.
+// If start is zero, the download starts at the head timestamp of the contract (full history).
+// If end is zero, the download ends now.
+// Requests are paced to respect the IB limits: 60 requests within 10 minutes,
+// no identical requests within 15 seconds and no more than 5 requests for the same contract within 2 seconds.
+// Chunks failing with a pacing violation (error 162) are retried.
+//
+// Bars are returned in chronological order, without duplicates, within [start, end].
+// Daily and longer bars are dated at midnight UTC of their date.
+func (ib *IB) DownloadHistory(contract *Contract, start, end time.Time, barSize BarSize, whatToShow WhatToShow, options ...func(*HistoryOptions)) ([]Bar, error) {
+	opts := HistoryOptions{MaxRetries: 5, RetryDelay: 10 * time.Second}
+	for _, option := range options {
+		option(&opts)
+	}
+	chunk, err := historyChunkFor(barSize)
+	if err != nil {
+		return nil, err
+	}
+	ctx := ib.eClient.Ctx()
+
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if start.IsZero() {
+		head, err := ib.ReqHeadTimeStamp(contract, whatToShow, opts.UseRTH, 2)
+		if err != nil {
+			return nil, fmt.Errorf("head timestamp: %w", err)
+		}
+		start = head
+	}
+	if !start.Before(end) {
+		return nil, nil
+	}
+
+	bars := make(map[int64]Bar)
+	contractKey := historicalContractKey(contract, whatToShow)
+	for cursor := end; cursor.After(start); {
+		endDateTime := FormatIBTimeUTC(cursor)
+		key := Key(contractKey, endDateTime, chunk.duration, barSize, strconv.FormatBool(opts.UseRTH))
+
+		var chunkBars []Bar
+		delay := opts.RetryDelay
+		for retry := 0; ; retry++ {
+			if err := ib.historyPacer.wait(ctx, key, contractKey); err != nil {
+				return nil, err
+			}
+			chunkBars, err = ib.reqHistoricalBars(ctx, contract, endDateTime, chunk.duration, barSize, whatToShow, opts.UseRTH)
+			if err == nil || isNoHistoricalData(err) {
+				err = nil
+				break
+			}
+			if !IsPacingViolation(err) || retry >= opts.MaxRetries {
+				return nil, fmt.Errorf("historical data ending %v: %w", endDateTime, err)
+			}
+			log.Warn().Err(err).Str("endDateTime", endDateTime).Dur("delay", delay).Msg("<DownloadHistory> pacing violation, retrying")
+			if err := ib.historyPacer.sleep(ctx, delay); err != nil {
+				return nil, err
+			}
+			delay *= 2
+		}
+
+		next := cursor.Add(-chunk.span)
+		for _, bar := range chunkBars {
+			t, err := barTime(bar)
+			if err != nil {
+				return nil, err
+			}
+			if t.Before(next) {
+				next = t
+			}
+			if t.Before(start) || t.After(end) {
+				continue
+			}
+			bars[t.Unix()] = bar
+		}
+		cursor = next
+	}
+
+	return sortedBars(bars), nil
+}
+
+// sortedBars returns the bars of a time (unix) -> bar map in chronological order.
+func sortedBars(m map[int64]Bar) []Bar {
+	times := make([]int64, 0, len(m))
+	for t := range m {
+		times = append(times, t)
+	}
+	slices.Sort(times)
+	bars := make([]Bar, 0, len(times))
+	for _, t := range times {
+		bars = append(bars, m[t])
+	}
+	return bars
+}
@@ -0,0 +1,127 @@
+package ibsync
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/scmhub/ibapi"
+)
+
+// newTestPacer returns a pacer with a fake clock advanced by sleep.
+func newTestPacer() (*historicalPacer, *time.Time) {
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	p := newHistoricalPacer()
+	p.now = func() time.Time { return now }
+	p.sleep = func(ctx context.Context, d time.Duration) error {
+		now = now.Add(d)
+		return nil
+	}
+	return p, &now
+}
+
+func TestHistoricalPacer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("identical requests", func(t *testing.T) {
+		p, now := newTestPacer()
+		start := *now
+		for range 2 {
+			if err := p.wait(ctx, "same", "contract"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := now.Sub(start); got != historicalIdenticalWindow {
+			t.Errorf("waited %v, want %v", got, historicalIdenticalWindow)
+		}
+	})
+
+	t.Run("same contract", func(t *testing.T) {
+		p, now := newTestPacer()
+		start := *now
+		for i := range historicalContractRequests + 1 {
+			if err := p.wait(ctx, fmt.Sprint(i), "contract"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := now.Sub(start); got != historicalContractWindow {
+			t.Errorf("waited %v, want %v", got, historicalContractWindow)
+		}
+	})
+
+	t.Run("requests per window", func(t *testing.T) {
+		p, now := newTestPacer()
+		start := *now
+		for i := range historicalPacingRequests + 1 {
+			if err := p.wait(ctx, fmt.Sprint(i), fmt.Sprint(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := now.Sub(start); got != historicalPacingWindow {
+			t.Errorf("waited %v, want %v", got, historicalPacingWindow)
+		}
+		if len(p.requests) != 1 {
+			t.Errorf("expired requests not pruned: %v requests", len(p.requests))
+		}
+	})
+}
+
+func TestHistoryChunkFor(t *testing.T) {
+	tests := []struct {
+		barSize  BarSize
+		duration HistoryDuration
+		wantErr  bool
+	}{
+		{"1 secs", "1800 S", false},
+		{"1 sec", "1800 S", false},
+		{"1 min", "1 D", false},
+		{"1 mins", "1 D", false},
+		{"5 mins", "1 W", false},
+		{"1 hour", "30 D", false},
+		{"4 hours", "30 D", false},
+		{"1 day", "1 Y", false},
+		{"7 mins", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.barSize), func(t *testing.T) {
+			chunk, err := historyChunkFor(tt.barSize)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("historyChunkFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if chunk.duration != tt.duration {
+				t.Errorf("historyChunkFor() = %v, want %v", chunk.duration, tt.duration)
+			}
+		})
+	}
+}
+
+func TestHistoricalErrors(t *testing.T) {
+	pacing := ibapi.CodeMsgPair{Code: 162, Msg: "Historical Market Data Service error message:Historical data request pacing violation"}
+	noData := ibapi.CodeMsgPair{Code: 162, Msg: "Historical Market Data Service error message:HMDS query returned no data: AAPL@SMART Trades"}
+	other := ibapi.CodeMsgPair{Code: 200, Msg: "No security definition has been found for the request"}
+
+	if !IsPacingViolation(pacing) || IsPacingViolation(noData) || IsPacingViolation(other) {
+		t.Errorf("IsPacingViolation() mismatch")
+	}
+	if !IsPacingViolation(fmt.Errorf("chunk: %w", pacing)) {
+		t.Errorf("IsPacingViolation() on wrapped error = false")
+	}
+	if !isNoHistoricalData(noData) || isNoHistoricalData(pacing) {
+		t.Errorf("isNoHistoricalData() mismatch")
+	}
+}
+
+func TestSortedBars(t *testing.T) {
+	m := map[int64]Bar{
+		3: {Date: "3"},
+		1: {Date: "1"},
+		2: {Date: "2"},
+	}
+	bars := sortedBars(m)
+	for i, bar := range bars {
+		if bar.Date != fmt.Sprint(i+1) {
+			t.Errorf("sortedBars()[%v] = %v, want %v", i, bar.Date, i+1)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package ibsync
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeRange is the half-open time range [Start, End).
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// mergeRanges sorts and merges overlapping or contiguous ranges. Empty ranges are dropped.
+func mergeRanges(ranges []TimeRange) []TimeRange {
+	rs := slices.DeleteFunc(slices.Clone(ranges), func(r TimeRange) bool { return !r.Start.Before(r.End) })
+	slices.SortFunc(rs, func(a, b TimeRange) int { return a.Start.Compare(b.Start) })
+	var merged []TimeRange
+	for _, r := range rs {
+		if n := len(merged); n > 0 && !r.Start.After(merged[n-1].End) {
+			if r.End.After(merged[n-1].End) {
+				merged[n-1].End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// missingRanges returns the parts of [start, end) not covered by the covered ranges.
+func missingRanges(covered []TimeRange, start, end time.Time) []TimeRange {
+	var missing []TimeRange
+	cursor := start
+	for _, r := range mergeRanges(covered) {
+		if !r.End.After(cursor) {
+			continue
+		}
+		if !r.Start.Before(end) {
+			break
+		}
+		if r.Start.After(cursor) {
+			missing = append(missing, TimeRange{Start: cursor, End: r.Start})
+		}
+		cursor = r.End
+	}
+	if cursor.Before(end) {
+		missing = append(missing, TimeRange{Start: cursor, End: end})
+	}
+	return missing
+}
+
+// HistoryStore persists the historical data cached by HistoryCache.
+//
+// Load returns nil data and no error if the key is unknown.
+type HistoryStore interface {
+	Load(key string) ([]byte, error)
+	Save(key string, data []byte) error
+	Delete(key string) error
+}
+
+// DirHistoryStore is a HistoryStore saving each series in a file of a directory.
+type DirHistoryStore struct {
+	dir string
+}
+
+// NewDirHistoryStore creates a store in dir. The directory is created if needed.
+func NewDirHistoryStore(dir string) (*DirHistoryStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DirHistoryStore{dir: dir}, nil
+}
+
+func (s *DirHistoryStore) path(key string) string {
+	return filepath.Join(s.dir, key+".gob")
+}
+
+func (s *DirHistoryStore) Load(key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Save writes the data to a temporary file renamed on success, so that a crash does not corrupt the cache.
+func (s *DirHistoryStore) Save(key string, data []byte) error {
+	tmp := s.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(key))
+}
+
+func (s *DirHistoryStore) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// MemoryHistoryStore is an in-memory HistoryStore.
+type MemoryHistoryStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+// NewMemoryHistoryStore creates an empty in-memory store.
+func NewMemoryHistoryStore() *MemoryHistoryStore {
+	return &MemoryHistoryStore{data: make(map[string][]byte)}
+}
+
+func (s *MemoryHistoryStore) Load(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[key], nil
+}
+
+func (s *MemoryHistoryStore) Save(key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = data
+	return nil
+}
+
+func (s *MemoryHistoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+// cachedSeries is the cached content of a series: its items sorted by time and the time ranges already requested.
+type cachedSeries[T any] struct {
+	Covered []TimeRange
+	Items   []T
+}
+
+// historyCacheKey returns the store key of a series. It is safe to use as a file name.
+func historyCacheKey(conID int64, parts ...string) string {
+	key := fmt.Sprintf("%d_%s", conID, strings.Join(parts, "_"))
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
+
+// HistoryCache caches historical bars and ticks in a HistoryStore.
+//
+// Series are keyed by ConID, bar size (or tick type), whatToShow and useRTH, and encoded with gob.
+// Only the time ranges missing from the cache are requested from IB, then merged in the cached series.
+// Each series is locked while it is fetched, so that downloads of different series run concurrently.
+type HistoryCache struct {
+	ib    *IB
+	store HistoryStore
+	mu    sync.Mutex             // guards locks
+	locks map[string]*sync.Mutex // key -> lock of the series
+	now   func() time.Time
+}
+
+// NewHistoryCache creates a cache requesting the missing data with ib.
+func NewHistoryCache(ib *IB, store HistoryStore) *HistoryCache {
+	return &HistoryCache{ib: ib, store: store, now: time.Now}
+}
+
+// lockKey locks the series stored at key and returns its unlock function.
+func (c *HistoryCache) lockKey(key string) func() {
+	c.mu.Lock()
+	if c.locks == nil {
+		c.locks = make(map[string]*sync.Mutex)
+	}
+	l, ok := c.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		c.locks[key] = l
+	}
+	c.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// cachedFetch returns the items of [start, end) of the series stored at key.
+//
+// The missing ranges are fetched and merged into the stored series. Fetched items replace the cached items of their range.
+// Ranges ending less than lag before now are only covered up to now - lag, so that incomplete data are requested again.
+func cachedFetch[T any](c *HistoryCache, key string, start, end time.Time, lag time.Duration, timeOf func(T) (time.Time, error), fetch func(TimeRange) ([]T, error)) ([]T, error) {
+	defer c.lockKey(key)()
+
+	var series cachedSeries[T]
+	data, err := c.store.Load(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&series); err != nil {
+			log.Warn().Err(err).Str("key", key).Msg("<HistoryCache> corrupted series, dropping it")
+			series = cachedSeries[T]{}
+		}
+	}
+
+	inRange := func(item T, r TimeRange) (bool, error) {
+		t, err := timeOf(item)
+		if err != nil {
+			return false, err
+		}
+		return !t.Before(r.Start) && t.Before(r.End), nil
+	}
+
+	missing := missingRanges(series.Covered, start, end)
+	var fetchErr error
+	for _, r := range missing {
+		items, err := fetch(r)
+		if err != nil {
+			fetchErr = err
+			break
+		}
+		var fetched []T
+		for _, item := range items {
+			ok, err := inRange(item, r)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				fetched = append(fetched, item)
+			}
+		}
+		var kept []T
+		for _, item := range series.Items {
+			ok, err := inRange(item, r)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				kept = append(kept, item)
+			}
+		}
+		series.Items = append(kept, fetched...)
+
+		coveredEnd := r.End
+		if limit := c.now().Add(-lag); coveredEnd.After(limit) {
+			coveredEnd = limit
+		}
+		series.Covered = mergeRanges(append(series.Covered, TimeRange{Start: r.Start, End: coveredEnd}))
+	}
+
+	if len(missing) > 0 {
+		var sortErr error
+		slices.SortStableFunc(series.Items, func(a, b T) int {
+			ta, err := timeOf(a)
+			if err != nil {
+				sortErr = err
+			}
+			tb, err := timeOf(b)
+			if err != nil {
+				sortErr = err
+			}
+			return ta.Compare(tb)
+		})
+		if sortErr != nil {
+			return nil, sortErr
+		}
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(series); err != nil {
+			return nil, err
+		}
+		if err := c.store.Save(key, buf.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+
+	var items []T
+	for _, item := range series.Items {
+		ok, err := inRange(item, TimeRange{Start: start, End: end})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// Bars returns the historical bars of [start, end), requesting only the ranges missing from the cache with DownloadHistory.
+// The contract must be qualified (ConID set). If end is zero, it is now.
+func (c *HistoryCache) Bars(contract *Contract, start, end time.Time, barSize BarSize, whatToShow WhatToShow, options ...func(*HistoryOptions)) ([]Bar, error) {
+	if contract.ConID == 0 {
+		return nil, errors.New("history cache: the contract must be qualified")
+	}
+	barDuration, err := barSize.Duration()
+	if err != nil {
+		return nil, err
+	}
+	var opts HistoryOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	if end.IsZero() {
+		end = c.now()
+	}
+	key := historyCacheKey(contract.ConID, normalizeBarSize(string(barSize)), string(whatToShow), fmt.Sprint(opts.UseRTH))
+	fetch := func(r TimeRange) ([]Bar, error) {
+		return c.ib.DownloadHistory(contract, r.Start, r.End, barSize, whatToShow, options...)
+	}
+	return cachedFetch(c, key, start, end, barDuration, barTime, fetch)
+}
+
+// fetchTicks returns the ticks of a range, paging forward through it.
+func fetchTicks[T any](c *HistoryCache, contract *Contract, whatToShow WhatToShow, r TimeRange, timeOf func(T) time.Time, req func(start time.Time) ([]T, error)) ([]T, error) {
+	var all []T
+	for tick, err := range pageTicks(c.ib.eClient.Ctx(), c.ib.historyPacer, historicalContractKey(contract, whatToShow), r.Start, r.End, timeOf, req) {
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, tick)
+	}
+	return all, nil
+}
+
+// Ticks returns the historical midpoint ticks of [start, end), requesting only the ranges missing from the cache.
+// The contract must be qualified (ConID set). If end is zero, it is now.
+func (c *HistoryCache) Ticks(contract *Contract, start, end time.Time, useRTH bool) ([]HistoricalTick, error) {
+	timeOf := func(t HistoricalTick) time.Time { return time.Unix(t.Time, 0) }
+	req := func(start time.Time) ([]HistoricalTick, error) {
+		ticks, err, _ := c.ib.ReqHistoricalTicks(contract, start, time.Time{}, historicalTicksPage, useRTH, true)
+		return ticks, err
+	}
+	return cachedTicks(c, contract, start, end, useRTH, ShowMidpoint, timeOf, req)
+}
+
+// TickLasts returns the historical last ticks of [start, end), requesting only the ranges missing from the cache.
+// The contract must be qualified (ConID set). If end is zero, it is now.
+func (c *HistoryCache) TickLasts(contract *Contract, start, end time.Time, useRTH bool) ([]HistoricalTickLast, error) {
+	timeOf := func(t HistoricalTickLast) time.Time { return time.Unix(t.Time, 0) }
+	req := func(start time.Time) ([]HistoricalTickLast, error) {
+		ticks, err, _ := c.ib.ReqHistoricalTickLast(contract, start, time.Time{}, historicalTicksPage, useRTH, true)
+		return ticks, err
+	}
+	return cachedTicks(c, contract, start, end, useRTH, ShowTrades, timeOf, req)
+}
+
+// TickBidAsks returns the historical bid ask ticks of [start, end), requesting only the ranges missing from the cache.
+// The contract must be qualified (ConID set). If end is zero, it is now.
+func (c *HistoryCache) TickBidAsks(contract *Contract, start, end time.Time, useRTH bool) ([]HistoricalTickBidAsk, error) {
+	timeOf := func(t HistoricalTickBidAsk) time.Time { return time.Unix(t.Time, 0) }
+	req := func(start time.Time) ([]HistoricalTickBidAsk, error) {
+		ticks, err, _ := c.ib.ReqHistoricalTickBidAsk(contract, start, time.Time{}, historicalTicksPage, useRTH, true)
+		return ticks, err
+	}
+	return cachedTicks(c, contract, start, end, useRTH, ShowBidAsk, timeOf, req)
+}
+
+// cachedTicks returns the cached ticks of a whatToShow, fetching the missing ranges with req.
+func cachedTicks[T any](c *HistoryCache, contract *Contract, start, end time.Time, useRTH bool, whatToShow WhatToShow, timeOf func(T) time.Time, req func(start time.Time) ([]T, error)) ([]T, error) {
+	if contract.ConID == 0 {
+		return nil, errors.New("history cache: the contract must be qualified")
+	}
+	if end.IsZero() {
+		end = c.now()
+	}
+	key := historyCacheKey(contract.ConID, "ticks", string(whatToShow), fmt.Sprint(useRTH))
+	fetch := func(r TimeRange) ([]T, error) {
+		return fetchTicks(c, contract, whatToShow, r, timeOf, req)
+	}
+	return cachedFetch(c, key, start.Truncate(time.Second), end.Truncate(time.Second), time.Second, func(t T) (time.Time, error) { return timeOf(t), nil }, fetch)
+}
+
+// Invalidate removes the cached bars of a contract for a bar size and whatToShow, for both useRTH values.
+func (c *HistoryCache) Invalidate(contract *Contract, barSize BarSize, whatToShow WhatToShow) error {
+	for _, useRTH := range []bool{false, true} {
+		key := historyCacheKey(contract.ConID, normalizeBarSize(string(barSize)), string(whatToShow), fmt.Sprint(useRTH))
+		unlock := c.lockKey(key)
+		err := c.store.Delete(key)
+		unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package ibsync
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMissingRanges(t *testing.T) {
+	h := func(hour int) time.Time { return testFillTime.Add(time.Duration(hour) * time.Hour) }
+	covered := []TimeRange{{h(4), h(6)}, {h(1), h(2)}, {h(2), h(3)}}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       []TimeRange
+	}{
+		{"fully covered", h(1), h(3), nil},
+		{"gap and tail", h(0), h(8), []TimeRange{{h(0), h(1)}, {h(3), h(4)}, {h(6), h(8)}}},
+		{"inside gap", h(3), h(4), []TimeRange{{h(3), h(4)}}},
+		{"overlapping start", h(5), h(7), []TimeRange{{h(6), h(7)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := missingRanges(covered, tt.start, tt.end)
+			if len(got) != len(tt.want) {
+				t.Fatalf("missingRanges() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Start.Equal(tt.want[i].Start) || !got[i].End.Equal(tt.want[i].End) {
+					t.Errorf("missingRanges()[%v] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	if merged := mergeRanges(covered); len(merged) != 2 {
+		t.Errorf("mergeRanges() = %v, want 2 ranges", merged)
+	}
+}
+
+func TestCachedFetch(t *testing.T) {
+	now := testFillTime.Add(10 * time.Minute)
+	stores := map[string]HistoryStore{"memory": NewMemoryHistoryStore()}
+	dir, err := NewDirHistoryStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores["dir"] = dir
+
+	minute := func(m int) time.Time { return testFillTime.Add(time.Duration(m) * time.Minute) }
+	timeOf := func(b Bar) (time.Time, error) { return barTime(b) }
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			c := &HistoryCache{store: store, now: func() time.Time { return now }}
+			var requested []TimeRange
+			fetch := func(r TimeRange) ([]Bar, error) {
+				requested = append(requested, r)
+				var bars []Bar
+				for bt := r.Start; bt.Before(r.End) && bt.Before(now); bt = bt.Add(time.Minute) {
+					bars = append(bars, Bar{Date: strconv.FormatInt(bt.Unix(), 10), Close: float64(bt.Minute())})
+				}
+				return bars, nil
+			}
+
+			bars, err := cachedFetch(c, "key", minute(0), minute(5), time.Minute, timeOf, fetch)
+			if err != nil || len(bars) != 5 || len(requested) != 1 {
+				t.Fatalf("first fetch = %v bars, %v requests, err %v", len(bars), len(requested), err)
+			}
+
+			// Cached range only
+			bars, err = cachedFetch(c, "key", minute(1), minute(4), time.Minute, timeOf, fetch)
+			if err != nil || len(bars) != 3 || len(requested) != 1 {
+				t.Fatalf("cached fetch = %v bars, %v requests, err %v", len(bars), len(requested), err)
+			}
+
+			// Extension up to now: only [5, 10) is requested, and the last bar is incomplete.
+			bars, err = cachedFetch(c, "key", minute(0), minute(10), time.Minute, timeOf, fetch)
+			if err != nil || len(bars) != 10 || len(requested) != 2 {
+				t.Fatalf("extended fetch = %v bars, %v requests, err %v", len(bars), len(requested), err)
+			}
+			if !requested[1].Start.Equal(minute(5)) {
+				t.Errorf("requested %v, want start at %v", requested[1], minute(5))
+			}
+
+			// The incomplete last bar is requested again and replaced, without duplicates.
+			bars, err = cachedFetch(c, "key", minute(0), minute(10), time.Minute, timeOf, fetch)
+			if err != nil || len(bars) != 10 || len(requested) != 3 || !requested[2].Start.Equal(minute(9)) {
+				t.Fatalf("refresh fetch = %v bars, %v requests (%v), err %v", len(bars), len(requested), requested, err)
+			}
+			for i, bar := range bars {
+				if bar.Close != float64(i) {
+					t.Errorf("bars[%v].Close = %v, want %v", i, bar.Close, i)
+				}
+			}
+		})
+	}
+}
+
+func TestCachedFetchConcurrentSeries(t *testing.T) {
+	c := &HistoryCache{store: NewMemoryHistoryStore(), now: func() time.Time { return testFillTime.Add(time.Hour) }}
+	timeOf := func(b Bar) (time.Time, error) { return barTime(b) }
+	start, end := testFillTime, testFillTime.Add(time.Minute)
+
+	blocked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := cachedFetch(c, "slow", start, end, 0, timeOf, func(TimeRange) ([]Bar, error) {
+			close(blocked)
+			<-release
+			return nil, nil
+		})
+		done <- err
+	}()
+	<-blocked
+
+	// Another series is fetched while the slow one is downloading.
+	if _, err := cachedFetch(c, "fast", start, end, 0, timeOf, func(TimeRange) ([]Bar, error) { return nil, nil }); err != nil {
+		t.Errorf("fast fetch err = %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("slow fetch err = %v", err)
+	}
+}
+
+func TestHistoryCacheKey(t *testing.T) {
+	if got := historyCacheKey(265598, "1 min", "TRADES", "true"); got != "265598_1_min_TRADES_true" {
+		t.Errorf("historyCacheKey() = %v", got)
+	}
+}
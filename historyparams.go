@@ -0,0 +1,233 @@
+package ibsync
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryDuration is the time span of an historical data request, e.g. "30 D".
+// It is made of an integer and a unit: S (seconds), D (days), W (weeks), M (months) or Y (years). Seconds are used if no unit is given.
+type HistoryDuration string
+
+// DurationSeconds returns a duration of n seconds.
+func DurationSeconds(n int) HistoryDuration { return HistoryDuration(strconv.Itoa(n) + " S") }
+
+// DurationDays returns a duration of n days.
+func DurationDays(n int) HistoryDuration { return HistoryDuration(strconv.Itoa(n) + " D") }
+
+// DurationWeeks returns a duration of n weeks.
+func DurationWeeks(n int) HistoryDuration { return HistoryDuration(strconv.Itoa(n) + " W") }
+
+// DurationMonths returns a duration of n months.
+func DurationMonths(n int) HistoryDuration { return HistoryDuration(strconv.Itoa(n) + " M") }
+
+// DurationYears returns a duration of n years.
+func DurationYears(n int) HistoryDuration { return HistoryDuration(strconv.Itoa(n) + " Y") }
+
+// HistoryDurationFor returns the shortest duration covering d.
+// Seconds are used up to a day, days up to a year and years beyond.
+func HistoryDurationFor(d time.Duration) HistoryDuration {
+	ceil := func(unit time.Duration) int {
+		return int((d + unit - 1) / unit)
+	}
+	switch {
+	case d <= 0:
+		return DurationSeconds(0)
+	case d <= historyDay:
+		return DurationSeconds(ceil(time.Second))
+	case d <= 365*historyDay:
+		return DurationDays(ceil(historyDay))
+	}
+	return DurationYears(ceil(365 * historyDay))
+}
+
+// parse returns the count and the unit of the duration.
+func (d HistoryDuration) parse() (int, string, error) {
+	fields := strings.Fields(string(d))
+	if len(fields) == 1 {
+		fields = append(fields, "S") // Seconds by default
+	}
+	if len(fields) != 2 {
+		return 0, "", fmt.Errorf("invalid duration %q", d)
+	}
+	n, err := strconv.Atoi(fields[0])
+	if err != nil || n <= 0 {
+		return 0, "", fmt.Errorf("invalid duration %q", d)
+	}
+	switch fields[1] {
+	case "S", "D", "W", "M", "Y":
+		return n, fields[1], nil
+	}
+	return 0, "", fmt.Errorf("invalid duration %q: unknown unit %q", d, fields[1])
+}
+
+// Duration returns the time span of the duration. Months are counted as 31 days and years as 365 days.
+func (d HistoryDuration) Duration() (time.Duration, error) {
+	n, unit, err := d.parse()
+	if err != nil {
+		return 0, err
+	}
+	units := map[string]time.Duration{
+		"S": time.Second,
+		"D": historyDay,
+		"W": 7 * historyDay,
+		"M": 31 * historyDay,
+		"Y": 365 * historyDay,
+	}
+	return time.Duration(n) * units[unit], nil
+}
+
+// Validate checks the duration syntax.
+func (d HistoryDuration) Validate() error {
+	n, unit, err := d.parse()
+	if err != nil {
+		return err
+	}
+	if unit == "S" && n > 86400 {
+		return fmt.Errorf("invalid duration %q: more than 86400 seconds, use days", d)
+	}
+	return nil
+}
+
+// BarSize is the size of historical bars, e.g. "1 min".
+type BarSize string
+
+// Bar sizes accepted by IB.
+const (
+	BarSize1Sec   BarSize = "1 secs"
+	BarSize5Secs  BarSize = "5 secs"
+	BarSize10Secs BarSize = "10 secs"
+	BarSize15Secs BarSize = "15 secs"
+	BarSize30Secs BarSize = "30 secs"
+	BarSize1Min   BarSize = "1 min"
+	BarSize2Mins  BarSize = "2 mins"
+	BarSize3Mins  BarSize = "3 mins"
+	BarSize5Mins  BarSize = "5 mins"
+	BarSize10Mins BarSize = "10 mins"
+	BarSize15Mins BarSize = "15 mins"
+	BarSize20Mins BarSize = "20 mins"
+	BarSize30Mins BarSize = "30 mins"
+	BarSize1Hour  BarSize = "1 hour"
+	BarSize2Hours BarSize = "2 hours"
+	BarSize3Hours BarSize = "3 hours"
+	BarSize4Hours BarSize = "4 hours"
+	BarSize8Hours BarSize = "8 hours"
+	BarSize1Day   BarSize = "1 day"
+	BarSize1Week  BarSize = "1 week"
+	BarSize1Month BarSize = "1 month"
+)
+
+// BarSizeFor returns the bar size lasting d. It fails if IB has no such bar size.
+func BarSizeFor(d time.Duration) (BarSize, error) {
+	for size := range historyChunks {
+		if sd, err := barSizeDuration(size); err == nil && sd == d {
+			return BarSize(size), nil
+		}
+	}
+	return "", fmt.Errorf("no bar size of %v", d)
+}
+
+// Duration returns the time span of a bar. Months are counted as 31 days.
+func (b BarSize) Duration() (time.Duration, error) {
+	if err := b.Validate(); err != nil {
+		return 0, err
+	}
+	return barSizeDuration(string(b))
+}
+
+// Validate checks that IB accepts the bar size. Singular and plural spellings are both accepted, e.g. "1 sec" and "1 secs".
+func (b BarSize) Validate() error {
+	if _, ok := historyChunks[normalizeBarSize(string(b))]; !ok {
+		return fmt.Errorf("unsupported bar size %q", b)
+	}
+	return nil
+}
+
+// WhatToShow is the type of historical data requested, e.g. "TRADES".
+type WhatToShow string
+
+// Historical data types.
+const (
+	ShowTrades                  WhatToShow = "TRADES"
+	ShowMidpoint                WhatToShow = "MIDPOINT"
+	ShowBid                     WhatToShow = "BID"
+	ShowAsk                     WhatToShow = "ASK"
+	ShowBidAsk                  WhatToShow = "BID_ASK"
+	ShowAdjustedLast            WhatToShow = "ADJUSTED_LAST"
+	ShowHistoricalVolatility    WhatToShow = "HISTORICAL_VOLATILITY"
+	ShowOptionImpliedVolatility WhatToShow = "OPTION_IMPLIED_VOLATILITY"
+	ShowRebateRate              WhatToShow = "REBATE_RATE"
+	ShowFeeRate                 WhatToShow = "FEE_RATE"
+	ShowYieldBid                WhatToShow = "YIELD_BID"
+	ShowYieldAsk                WhatToShow = "YIELD_ASK"
+	ShowYieldBidAsk             WhatToShow = "YIELD_BID_ASK"
+	ShowYieldLast               WhatToShow = "YIELD_LAST"
+	ShowSchedule                WhatToShow = "SCHEDULE"
+	ShowAggTrades               WhatToShow = "AGGTRADES"
+)
+
+var whatToShows = map[WhatToShow]bool{
+	ShowTrades: true, ShowMidpoint: true, ShowBid: true, ShowAsk: true, ShowBidAsk: true, ShowAdjustedLast: true,
+	ShowHistoricalVolatility: true, ShowOptionImpliedVolatility: true, ShowRebateRate: true, ShowFeeRate: true,
+	ShowYieldBid: true, ShowYieldAsk: true, ShowYieldBidAsk: true, ShowYieldLast: true, ShowSchedule: true, ShowAggTrades: true,
+}
+
+// Validate checks that w is a known historical data type.
+func (w WhatToShow) Validate() error {
+	if !whatToShows[w] {
+		return fmt.Errorf("unsupported whatToShow %q", w)
+	}
+	return nil
+}
+
+// historyBarLimit is a row of the IB table of the bar sizes allowed for a duration.
+type historyBarLimit struct {
+	span   time.Duration // Longest duration of the row
+	minBar time.Duration
+	maxBar time.Duration
+}
+
+// historyBarLimits is the IB table of the bar sizes allowed for a duration, by increasing span.
+// https://www.interactivebrokers.com/campus/ibkr-api-page/twsapi-doc/#hist-step-size
+var historyBarLimits = []historyBarLimit{
+	{60 * time.Second, time.Second, time.Minute},
+	{120 * time.Second, time.Second, 2 * time.Minute},
+	{1800 * time.Second, time.Second, 30 * time.Minute},
+	{3600 * time.Second, 5 * time.Second, time.Hour},
+	{14400 * time.Second, 10 * time.Second, 3 * time.Hour},
+	{28800 * time.Second, 30 * time.Second, 8 * time.Hour},
+	{historyDay, time.Minute, historyDay},
+	{2 * historyDay, 2 * time.Minute, historyDay},
+	{7 * historyDay, 3 * time.Minute, 7 * historyDay},
+	{31 * historyDay, 30 * time.Minute, 31 * historyDay},
+	{365 * historyDay, historyDay, 31 * historyDay},
+}
+
+// ValidateHistoricalRequest checks the parameters of an historical data request,
+// including the bar sizes IB allows for the duration.
+func ValidateHistoricalRequest(duration HistoryDuration, barSize BarSize, whatToShow WhatToShow) error {
+	if err := duration.Validate(); err != nil {
+		return err
+	}
+	if err := whatToShow.Validate(); err != nil {
+		return err
+	}
+	bar, err := barSize.Duration()
+	if err != nil {
+		return err
+	}
+	span, _ := duration.Duration()
+	limit := historyBarLimit{minBar: historyDay, maxBar: 31 * historyDay}
+	for _, l := range historyBarLimits {
+		if span <= l.span {
+			limit = l
+			break
+		}
+	}
+	if bar < limit.minBar || bar > limit.maxBar {
+		return fmt.Errorf("bar size %q not allowed for duration %q", barSize, duration)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package ibsync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryDuration(t *testing.T) {
+	tests := []struct {
+		duration HistoryDuration
+		want     time.Duration
+		wantErr  bool
+	}{
+		{DurationSeconds(60), time.Minute, false},
+		{"3600", time.Hour, false},
+		{DurationDays(2), 2 * historyDay, false},
+		{DurationWeeks(1), 7 * historyDay, false},
+		{DurationMonths(1), 31 * historyDay, false},
+		{DurationYears(2), 2 * 365 * historyDay, false},
+		{"1 H", 0, true},
+		{"0 D", 0, true},
+		{"D", 0, true},
+		{"86401 S", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.duration), func(t *testing.T) {
+			err := tt.duration.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got, _ := tt.duration.Duration(); got != tt.want {
+				t.Errorf("Duration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryDurationFor(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want HistoryDuration
+	}{
+		{90 * time.Minute, "5400 S"},
+		{1500 * time.Millisecond, "2 S"},
+		{historyDay, "86400 S"},
+		{36 * time.Hour, "2 D"},
+		{400 * historyDay, "2 Y"},
+	}
+	for _, tt := range tests {
+		if got := HistoryDurationFor(tt.d); got != tt.want {
+			t.Errorf("HistoryDurationFor(%v) = %v, want %v", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestBarSize(t *testing.T) {
+	if d, err := BarSize("1 sec").Duration(); err != nil || d != time.Second {
+		t.Errorf(`BarSize("1 sec").Duration() = %v, %v`, d, err)
+	}
+	if err := BarSize("7 mins").Validate(); err == nil {
+		t.Errorf(`BarSize("7 mins").Validate() = nil`)
+	}
+	if b, err := BarSizeFor(4 * time.Hour); err != nil || b != BarSize4Hours {
+		t.Errorf("BarSizeFor(4h) = %v, %v", b, err)
+	}
+	if _, err := BarSizeFor(7 * time.Minute); err == nil {
+		t.Errorf("BarSizeFor(7m) error = nil")
+	}
+}
+
+func TestValidateHistoricalRequest(t *testing.T) {
+	tests := []struct {
+		duration   HistoryDuration
+		barSize    BarSize
+		whatToShow WhatToShow
+		wantErr    bool
+	}{
+		{"60 S", BarSize1Sec, ShowTrades, false},
+		{"60 S", BarSize2Mins, ShowTrades, true},
+		{"1 D", BarSize1Sec, ShowTrades, true},
+		{"1 D", BarSize1Min, ShowMidpoint, false},
+		{"1 D", BarSize1Day, ShowMidpoint, false},
+		{"1 W", BarSize3Mins, ShowBidAsk, false},
+		{"1 M", BarSize15Mins, ShowTrades, true},
+		{"1 Y", BarSize1Day, ShowAdjustedLast, false},
+		{"1 Y", BarSize1Hour, ShowTrades, true},
+		{"10 Y", BarSize1Week, ShowTrades, false},
+		{"1 D", BarSize1Min, "LAST", true},
+	}
+	for _, tt := range tests {
+		err := ValidateHistoricalRequest(tt.duration, tt.barSize, tt.whatToShow)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateHistoricalRequest(%v, %v, %v) error = %v, wantErr %v", tt.duration, tt.barSize, tt.whatToShow, err, tt.wantErr)
+		}
+	}
+
+	// Every chunk of DownloadHistory is a legal request.
+	for barSize, chunk := range historyChunks {
+		if err := ValidateHistoricalRequest(chunk.duration, BarSize(barSize), ShowTrades); err != nil {
+			t.Errorf("chunk of %v: %v", barSize, err)
+		}
+	}
+}
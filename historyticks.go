@@ -0,0 +1,117 @@
+package ibsync
+
+import (
+	"context"
+	"iter"
+	"time"
+)
+
+// historicalTicksPage is the number of ticks requested by page.
+const historicalTicksPage = 1000
+
+// pageTicks returns an iterator paging forward through the ticks of [start, end).
+//
+// The ticks have a one second resolution and IB returns all the ticks of the last second of a page, beyond the page size.
+// The ticks of the last second of a full page are nevertheless held back and requested again with the next page,
+// which starts at that second: no tick is dropped or duplicated whether IB completed the second or not.
+// A page made of a single second is yielded whole.
+// Requests are paced with pacer. The iteration stops after yielding the first error.
+func pageTicks[T any](ctx context.Context, pacer *historicalPacer, contractKey string, start, end time.Time, timeOf func(T) time.Time, req func(start time.Time) ([]T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		for cursor := start.Truncate(time.Second); cursor.Before(end); {
+			if err := pacer.wait(ctx, Key(contractKey, "ticks", cursor.Unix()), contractKey); err != nil {
+				yield(zero, err)
+				return
+			}
+			ticks, err := req(cursor)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if len(ticks) == 0 {
+				return
+			}
+			full := len(ticks) >= historicalTicksPage
+			last := timeOf(ticks[len(ticks)-1]).Truncate(time.Second)
+			next := last.Add(time.Second)
+			n := len(ticks)
+			if full && last.After(cursor) {
+				for n > 0 && !timeOf(ticks[n-1]).Before(last) {
+					n--
+				}
+				next = last
+			}
+			for _, tick := range ticks[:n] {
+				t := timeOf(tick)
+				if t.Before(start) {
+					continue
+				}
+				if !t.Before(end) {
+					return
+				}
+				if !yield(tick, nil) {
+					return
+				}
+			}
+			if !full {
+				return
+			}
+			if !next.After(cursor) {
+				next = cursor.Add(time.Second)
+			}
+			cursor = next
+		}
+	}
+}
+
+// HistoricalTicks returns an iterator over the historical midpoint ticks of [start, end), in chronological order.
+//
+// It pages through the range with ReqHistoricalTicks, handling the ticks sharing a second at the page boundaries,
+// and paces the requests within the IB historical data limits. If end is zero, it is now.
+// The iteration stops after yielding the first error.
+//
+//	for tick, err := range ib.HistoricalTicks(contract, start, end, true) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (ib *IB) HistoricalTicks(contract *Contract, start, end time.Time, useRTH bool) iter.Seq2[HistoricalTick, error] {
+	timeOf := func(t HistoricalTick) time.Time { return time.Unix(t.Time, 0) }
+	req := func(start time.Time) ([]HistoricalTick, error) {
+		ticks, err, _ := ib.ReqHistoricalTicks(contract, start, time.Time{}, historicalTicksPage, useRTH, true)
+		return ticks, err
+	}
+	return historicalTicks(ib, contract, ShowMidpoint, start, end, timeOf, req)
+}
+
+// HistoricalTickLasts returns an iterator over the historical last ticks of [start, end), in chronological order.
+// It pages through the range with ReqHistoricalTickLast, see HistoricalTicks.
+func (ib *IB) HistoricalTickLasts(contract *Contract, start, end time.Time, useRTH bool) iter.Seq2[HistoricalTickLast, error] {
+	timeOf := func(t HistoricalTickLast) time.Time { return time.Unix(t.Time, 0) }
+	req := func(start time.Time) ([]HistoricalTickLast, error) {
+		ticks, err, _ := ib.ReqHistoricalTickLast(contract, start, time.Time{}, historicalTicksPage, useRTH, true)
+		return ticks, err
+	}
+	return historicalTicks(ib, contract, ShowTrades, start, end, timeOf, req)
+}
+
+// HistoricalTickBidAsks returns an iterator over the historical bid ask ticks of [start, end), in chronological order.
+// It pages through the range with ReqHistoricalTickBidAsk, see HistoricalTicks.
+func (ib *IB) HistoricalTickBidAsks(contract *Contract, start, end time.Time, useRTH bool) iter.Seq2[HistoricalTickBidAsk, error] {
+	timeOf := func(t HistoricalTickBidAsk) time.Time { return time.Unix(t.Time, 0) }
+	req := func(start time.Time) ([]HistoricalTickBidAsk, error) {
+		ticks, err, _ := ib.ReqHistoricalTickBidAsk(contract, start, time.Time{}, historicalTicksPage, useRTH, true)
+		return ticks, err
+	}
+	return historicalTicks(ib, contract, ShowBidAsk, start, end, timeOf, req)
+}
+
+// historicalTicks returns a paced iterator over the ticks of a whatToShow.
+func historicalTicks[T any](ib *IB, contract *Contract, whatToShow WhatToShow, start, end time.Time, timeOf func(T) time.Time, req func(start time.Time) ([]T, error)) iter.Seq2[T, error] {
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return pageTicks(ib.eClient.Ctx(), ib.historyPacer, historicalContractKey(contract, whatToShow), start, end, timeOf, req)
+}
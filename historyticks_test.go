@@ -0,0 +1,97 @@
+package ibsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPageTicks(t *testing.T) {
+	start := testFillTime
+	timeOf := func(tick HistoricalTick) time.Time { return time.Unix(tick.Time, 0) }
+
+	tests := []struct {
+		name           string
+		completeSecond bool // IB returns all the ticks of the last second of a page
+		largeSecond    bool // A second holds more ticks than a page
+	}{
+		{"complete second", true, false},
+		{"truncated second", false, false},
+		{"second larger than a page", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Ticks are numbered in Price to check their order.
+			var all []HistoricalTick
+			for s := range 600 {
+				n := s%7 + 1
+				if tt.largeSecond && s == 100 {
+					n = historicalTicksPage + 500
+				}
+				for range n {
+					all = append(all, HistoricalTick{Time: start.Add(time.Duration(s) * time.Second).Unix(), Price: float64(len(all))})
+				}
+			}
+			var requests int
+			req := func(from time.Time) ([]HistoricalTick, error) {
+				requests++
+				var page []HistoricalTick
+				for _, tick := range all {
+					if tick.Time < from.Unix() {
+						continue
+					}
+					if len(page) >= historicalTicksPage && (!tt.completeSecond || tick.Time != page[len(page)-1].Time) {
+						break
+					}
+					page = append(page, tick)
+				}
+				return page, nil
+			}
+
+			pacer, _ := newTestPacer()
+			from, end := start.Add(time.Second), start.Add(500*time.Second)
+			var got []HistoricalTick
+			for tick, err := range pageTicks(context.Background(), pacer, "key", from, end, timeOf, req) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, tick)
+			}
+
+			var want []HistoricalTick
+			for _, tick := range all {
+				if tm := timeOf(tick); !tm.Before(from) && tm.Before(end) {
+					want = append(want, tick)
+				}
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got %v ticks, want %v", len(got), len(want))
+			}
+			for i := range got {
+				if got[i].Price != want[i].Price {
+					t.Fatalf("ticks[%v] = %v, want %v", i, got[i].Price, want[i].Price)
+				}
+			}
+			if requests < 2 {
+				t.Errorf("%v requests, want several pages", requests)
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		pacer, _ := newTestPacer()
+		failure := errors.New("failure")
+		req := func(time.Time) ([]HistoricalTick, error) { return nil, failure }
+		var errs int
+		for _, err := range pageTicks(context.Background(), pacer, "key", start, start.Add(time.Hour), timeOf, req) {
+			if !errors.Is(err, failure) {
+				t.Errorf("err = %v, want %v", err, failure)
+			}
+			errs++
+		}
+		if errs != 1 {
+			t.Errorf("got %v errors, want 1", errs)
+		}
+	})
+}
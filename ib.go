@@ -31,6 +31,9 @@ type IB struct {
 	eClient *ibapi.EClient
 	wrapper *WrapperSync
 	config  *Config
+
+	historyPacer *historicalPacer
+	calendars    *calendarCache
 }
 
 func NewIB(config ...*Config) *IB {
@@ -45,6 +48,9 @@ func NewIB(config ...*Config) *IB {
 		eClient: client,
 		wrapper: wrapper,
 		config:  NewConfig(),
+
+		historyPacer: newHistoricalPacer(),
+		calendars:    newCalendarCache(),
 	}
 
 	if len(config) > 0 {
@@ -96,6 +102,10 @@ func (ib *IB) Connect(config ...*Config) error {
 		ib.ReqAutoOpenOrders(true)
 	}
 
+	if ib.config.MarketDataPolicy != 0 {
+		ib.ReqMarketDataType(int64(ib.config.MarketDataPolicy))
+	}
+
 	accounts := ib.ManagedAccounts()
 	if ib.config.Account == "" && len(accounts) == 1 {
 		ib.config.Account = accounts[0]
@@ -565,7 +575,8 @@ func (ib *IB) Tickers() []*Ticker {
 	return ts
 }
 
-// NewTick returns the list of NewsTick
+// NewsTick returns the last news ticks received, at most 1000.
+// Use SubscribeContractNews or SubscribeProviderNews to be notified of the news ticks.
 func (ib *IB) NewsTick() []NewsTick {
 	ib.state.mu.Lock()
 	defer ib.state.mu.Unlock()
@@ -744,6 +755,8 @@ func (ib *IB) Snapshot(contract *Contract, regulatorySnapshot ...bool) (*Ticker,
 //	2 -> frozen market data
 //	3 -> delayed market data
 //	4 -> delayed frozen market data
+//
+// Use SetMarketDataPolicy or WithMarketDataPolicy to keep the setting across reconnections.
 func (ib *IB) ReqMarketDataType(marketDataType int64) {
 	log.Debug().Int64("marketDataType", marketDataType).Msg("<ReqMarketDataType>")
 	ib.eClient.ReqMarketDataType(marketDataType)
@@ -824,6 +837,78 @@ func (ib *IB) ReqTickByTickData(contract *Contract, tickType string, numberOfTic
 	return ticker
 }
 
+// SubscribeTickByTickAllLast subscribes to the "Last" or "AllLast" tick-by-tick data of a contract and returns a subscription streaming the ticks.
+//
+// The ticker of the contract is updated as with ReqTickByTickData. Cancel the subscription to stop the data.
+func (ib *IB) SubscribeTickByTickAllLast(contract *Contract, tickType string, ignoreSize bool) *Subscription[TickByTickAllLast] {
+	ctx := ib.eClient.Ctx()
+
+	reqID := ib.NextID()
+
+	ch, unsubscribe := ib.pubSub.Subscribe(reqID, 100)
+
+	ib.state.mu.Lock()
+	ticker := ib.state.startTicker(reqID, contract, tickType)
+	ib.state.mu.Unlock()
+
+	ib.eClient.ReqTickByTickData(reqID, contract, tickType, 0, ignoreSize)
+
+	sub := newSubscription[TickByTickAllLast](100)
+
+	end := func(err error) {
+		ib.state.mu.Lock()
+		ib.state.endTicker(ticker, tickType)
+		ib.state.mu.Unlock()
+		sub.finish(err)
+	}
+	stop := func(err error) {
+		ib.eClient.CancelTickByTickData(reqID)
+		end(err)
+	}
+
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Error().Err(ctx.Err()).Int64("reqID", reqID).Msg("<SubscribeTickByTickAllLast>")
+				end(ctx.Err())
+				return
+			case <-sub.stop:
+				stop(nil)
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					sub.finish(nil)
+					return
+				}
+				if isErrorMsg(msg) {
+					err := msg2Error(msg)
+					log.Error().Err(err).Int64("reqID", reqID).Msg("<SubscribeTickByTickAllLast>")
+					stop(err)
+					return
+				}
+				items := Split(msg)
+				if items[0] != "AllLast" {
+					continue
+				}
+				var tick TickByTickAllLast
+				if err := Decode(&tick, items[1]); err != nil {
+					log.Error().Err(err).Int64("reqID", reqID).Msg("<SubscribeTickByTickAllLast>")
+					stop(err)
+					return
+				}
+				if !sub.send(tick) {
+					stop(nil)
+					return
+				}
+			}
+		}
+	}()
+
+	return sub
+}
+
 // CancelTickByTickData unsubscribes from tick-by-tick for given contract and tick type.
 func (ib *IB) CancelTickByTickData(contract *Contract, tickType string) error {
 	ib.state.mu.Lock()
@@ -980,6 +1065,9 @@ func (ib *IB) PlaceOrder(contract *Contract, order *Order) *Trade {
 		order.ClientID = ib.config.ClientID
 		trade = NewTrade(contract, order)
 		trade.logs[0].Message = "Placing order"
+		if bid, ask, ok := ib.state.quote(contract); ok {
+			trade.setArrivalQuote(bid, ask, trade.logs[0].Time)
+		}
 		key = orderKey(order.ClientID, order.OrderID, order.PermID) // clientID is updated
 		ib.state.trades[key] = trade
 		log.Debug().Int64("orderID", order.OrderID).Bool("new order", true).Msg("<PlaceOrder>")
@@ -1396,7 +1484,30 @@ func (ib *IB) ReqFills(execFilter ...*ExecutionFilter) ([]Fill, error) {
 // ReqContractDetails downloads all details for a particular underlying.
 // If the returned list is empty then the contract is not known.
 // If the list has multiple values then the contract is ambiguous.
+//
+// Details are served from the contract cache of the config, if enabled with WithContractCache.
 func (ib *IB) ReqContractDetails(contract *Contract) ([]ContractDetails, error) {
+	cache := ib.config.ContractCache
+	if cache == nil {
+		return ib.reqContractDetails(contract)
+	}
+	if cds, ok := cache.Get(contract); ok {
+		return cds, nil
+	}
+	cds, err := ib.reqContractDetails(contract)
+	if err == nil {
+		cache.Set(contract, cds)
+	}
+	return cds, err
+}
+
+// ContractCache returns the contract details cache, nil if disabled.
+func (ib *IB) ContractCache() *ContractCache {
+	return ib.config.ContractCache
+}
+
+// reqContractDetails requests the contract details, bypassing the cache.
+func (ib *IB) reqContractDetails(contract *Contract) ([]ContractDetails, error) {
 	ctx, cancel := context.WithTimeout(ib.eClient.Ctx(), ib.config.Timeout)
 	defer cancel()
 
@@ -1438,53 +1549,18 @@ func (ib *IB) ReqContractDetails(contract *Contract) ([]ContractDetails, error)
 //   - contracts: One or more *Contract structs that need to be qualified.
 //
 // Returns:
-//   - An error if any of the contracts cannot be qualified or if any are ambiguous (ErrAmbiguousContract).
+//   - The first error, in the order of the contracts, if any of the contracts cannot be qualified
+//     (ErrUnknownContract) or is ambiguous (ErrAmbiguousContract).
 //   - If successful, the contracts are updated in place with their corresponding details.
 //
-// QualifyContract fetches and qualifies contract details in parallel
+// QualifyContract fetches and qualifies contract details in parallel.
+// Use QualifyContracts for the result of each contract and the disambiguation rules.
 func (ib *IB) QualifyContract(contracts ...*Contract) error {
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(contracts)) // Channel for collecting errors
-	doneChan := make(chan struct{})             // Channel to signal completion
-
-	for _, contract := range contracts {
-		wg.Add(1)
-		go func(contract *Contract) {
-			defer wg.Done()
-
-			cds, err := ib.ReqContractDetails(contract)
-			if err != nil {
-				errChan <- err
-				return
-			}
-			if len(cds) > 1 {
-				log.Error().Err(ErrAmbiguousContract).Str("Symbol", contract.Symbol).Msg("QualifyContract")
-				errChan <- ErrAmbiguousContract
-				return
-			}
-
-			UpdateStruct(contract, cds[0].Contract)
-		}(contract)
-	}
-
-	// Close the done channel once all goroutines finish
-	go func() {
-		wg.Wait()
-		close(doneChan)
-	}()
-
-	// Wait for completion or an error
-	select {
-	case <-doneChan:
-		close(errChan)
-		// Check if any errors were reported
-		if len(errChan) > 0 {
-			return <-errChan // Return the first error
+	for _, result := range ib.QualifyContracts(contracts) {
+		if result.Err != nil {
+			return result.Err
 		}
-	case err := <-errChan:
-		return err // Return as soon as an error is encountered
 	}
-
 	return nil
 }
 
@@ -1702,7 +1778,7 @@ func (ib *IB) ReplaceFA(faDataType FaDataType, cxml string) (string, error) {
 //
 //    "60 S", "30 D", "13 W", "6 M", "10 Y"...
 //
-// If no unit is specified, seconds are used by default.
+// If no unit is specified, seconds are used by default. Use DurationDays, HistoryDurationFor, etc. to build it.
 //
 // barSizeSetting: Specifies the size of the bars to be returned. Valid values are:
 //
@@ -1731,8 +1807,10 @@ func (ib *IB) ReplaceFA(faDataType FaDataType, cxml string) (string, error) {
 //     2 - Dates are returned as a Unix timestamp (seconds since 1/1/1970 GMT).
 //
 // chartOptions: Reserved for internal use. Use the default value "XYZ".
+//
+// The channel is closed on completion and on error. Use SubscribeHistoricalData to know why it was closed.
 
-func (ib *IB) ReqHistoricalData(contract *Contract, endDateTime string, duration string, barSize string, whatToShow string, useRTH bool, formatDate int, chartOptions ...TagValue) (chan Bar, CancelFunc) {
+func (ib *IB) ReqHistoricalData(contract *Contract, endDateTime string, duration HistoryDuration, barSize BarSize, whatToShow WhatToShow, useRTH bool, formatDate int, chartOptions ...TagValue) (chan Bar, CancelFunc) {
 	barChan, cancel := ib.reqHistoricalData(contract, endDateTime, duration, barSize, whatToShow, useRTH, formatDate, false, chartOptions...)
 	return barChan, cancel
 }
@@ -1755,52 +1833,78 @@ func (ib *IB) ReqHistoricalData(contract *Contract, endDateTime string, duration
 //	A `chan Bar` that streams incoming historical data. The caller must read from this channel until it is closed. The channel should be properly closed once the caller is done processing the data to prevent resource leaks.
 //
 // Do NOT close the channel.
-func (ib *IB) ReqHistoricalDataUpToDate(contract *Contract, duration string, barSize string, whatToShow string, useRTH bool, formatDate int, chartOptions ...TagValue) (chan Bar, CancelFunc) {
+func (ib *IB) ReqHistoricalDataUpToDate(contract *Contract, duration HistoryDuration, barSize BarSize, whatToShow WhatToShow, useRTH bool, formatDate int, chartOptions ...TagValue) (chan Bar, CancelFunc) {
 	barChan, cancel := ib.reqHistoricalData(contract, "", duration, barSize, whatToShow, useRTH, formatDate, true, chartOptions...)
 	return barChan, cancel
 }
 
-func (ib *IB) reqHistoricalData(contract *Contract, endDateTime string, duration string, barSize string, whatToShow string, useRTH bool, formatDate int, keepUpToDate bool, chartOptions ...TagValue) (chan Bar, CancelFunc) {
-	ctx := ib.eClient.Ctx()
+func (ib *IB) reqHistoricalData(contract *Contract, endDateTime string, duration HistoryDuration, barSize BarSize, whatToShow WhatToShow, useRTH bool, formatDate int, keepUpToDate bool, chartOptions ...TagValue) (chan Bar, CancelFunc) {
+	sub := ib.subscribeHistoricalData(contract, endDateTime, duration, barSize, whatToShow, useRTH, formatDate, keepUpToDate, chartOptions...)
+	return sub.ch, sub.Cancel
+}
 
-	reqID := ib.NextID()
+// SubscribeHistoricalData requests historical data and returns a subscription streaming the bars.
+//
+// It takes the same parameters as ReqHistoricalData. The subscription channel is closed after the last bar.
+// Err then tells whether all the data was received (nil) or why the request failed, e.g. a pacing violation or missing permissions.
+// Period returns the start and end dates of the data sent by IB.
+//
+// The duration, bar size and whatToShow are checked with ValidateHistoricalRequest before the request is sent,
+// a rejected request ends the subscription with the validation error.
+func (ib *IB) SubscribeHistoricalData(contract *Contract, endDateTime string, duration HistoryDuration, barSize BarSize, whatToShow WhatToShow, useRTH bool, formatDate int, chartOptions ...TagValue) *HistoricalDataSubscription {
+	if err := ValidateHistoricalRequest(duration, barSize, whatToShow); err != nil {
+		return rejectedHistoricalData(err)
+	}
+	return ib.subscribeHistoricalData(contract, endDateTime, duration, barSize, whatToShow, useRTH, formatDate, false, chartOptions...)
+}
 
-	ch, unsubscribe := ib.pubSub.Subscribe(reqID, 100)
+// SubscribeHistoricalDataUpToDate requests historical data kept up to date and returns a subscription streaming the bars and their updates.
+//
+// It takes the same parameters as ReqHistoricalDataUpToDate. The stream runs until Cancel is called or an error occurs.
+// The request is checked with ValidateHistoricalRequest, like SubscribeHistoricalData.
+func (ib *IB) SubscribeHistoricalDataUpToDate(contract *Contract, duration HistoryDuration, barSize BarSize, whatToShow WhatToShow, useRTH bool, formatDate int, chartOptions ...TagValue) *HistoricalDataSubscription {
+	if err := ValidateHistoricalRequest(duration, barSize, whatToShow); err != nil {
+		return rejectedHistoricalData(err)
+	}
+	return ib.subscribeHistoricalData(contract, "", duration, barSize, whatToShow, useRTH, formatDate, true, chartOptions...)
+}
 
-	ib.eClient.ReqHistoricalData(reqID, contract, endDateTime, duration, barSize, whatToShow, useRTH, formatDate, keepUpToDate, chartOptions)
+// rejectedHistoricalData returns a subscription ended by the validation error of its request.
+func rejectedHistoricalData(err error) *HistoricalDataSubscription {
+	log.Error().Err(err).Msg("<SubscribeHistoricalData>")
+	sub := &HistoricalDataSubscription{Subscription: newSubscription[Bar](0)}
+	sub.finish(err)
+	return sub
+}
 
-	barChan := make(chan Bar, 100)
+// subscribeHistoricalData sends the request as is: IB accepts more combinations than its step size table,
+// which is only enforced by the Subscribe functions.
+func (ib *IB) subscribeHistoricalData(contract *Contract, endDateTime string, duration HistoryDuration, barSize BarSize, whatToShow WhatToShow, useRTH bool, formatDate int, keepUpToDate bool, chartOptions ...TagValue) *HistoricalDataSubscription {
+	sub := &HistoricalDataSubscription{Subscription: newSubscription[Bar](100)}
 
-	cancel := func() { ib.eClient.CancelHistoricalData(reqID) }
+	ctx := ib.eClient.Ctx()
 
-	closeChans := func() {
-		unsubscribe()
-		// drains and safely closes the channel.
-		for {
-			select {
-			case _, ok := <-barChan:
-				if !ok {
-					// Channel is already closed
-					return
-				}
-				// Channel is not empty, drain it.
-			default:
-				// channel is empty, close it.
-				close(barChan)
-				return
-			}
-		}
-	}
+	reqID := ib.NextID()
+
+	ch, unsubscribe := ib.pubSub.Subscribe(reqID, 100)
+
+	ib.eClient.ReqHistoricalData(reqID, contract, endDateTime, string(duration), string(barSize), string(whatToShow), useRTH, formatDate, keepUpToDate, chartOptions)
 
 	go func() {
-		defer closeChans()
+		defer unsubscribe()
 		for {
 			select {
 			case <-ctx.Done():
 				log.Error().Err(ctx.Err()).Int64("reqID", reqID).Msg("<ReqHistoricalData>")
+				sub.finish(ctx.Err())
+				return
+			case <-sub.stop:
+				ib.eClient.CancelHistoricalData(reqID)
+				sub.finish(nil)
 				return
 			case msg, ok := <-ch:
 				if !ok {
+					sub.finish(nil)
 					return
 				}
 				if isErrorMsg(msg) {
@@ -1810,35 +1914,46 @@ func (ib *IB) reqHistoricalData(contract *Contract, endDateTime string, duration
 					} else {
 						log.Error().Err(err).Int64("reqID", reqID).Msg("<ReqHistoricalData>")
 					}
+					sub.finish(err)
 					return
 				}
 				items := Split(msg)
 				switch items[0] {
 				case "HistoricalDataEnd":
+					sub.setPeriod(items[1], items[2])
 					if !keepUpToDate {
 						log.Info().Str("symbol", contract.Symbol).Str("start date", items[1]).Str("end date", items[2]).Msg("<ReqHistoricalData> completed")
+						sub.finish(nil)
 						return
 					}
 				case "HistoricalData", "HistoricalDataUpdate":
 					var bar Bar
 					if err := Decode(&bar, items[1]); err != nil {
 						log.Error().Err(err).Int64("reqID", reqID).Msg("<ReqHistoricalData>")
+						ib.eClient.CancelHistoricalData(reqID)
+						sub.finish(err)
+						return
+					}
+					if !sub.send(bar) {
+						ib.eClient.CancelHistoricalData(reqID)
+						sub.finish(nil)
 						return
 					}
-					barChan <- bar
 				default:
 					log.Error().Err(errUnknowItemType).Int64("reqID", reqID).Str("Type", items[0]).Msg("<ReqHistoricalData>")
+					ib.eClient.CancelHistoricalData(reqID)
+					sub.finish(errUnknowItemType)
 					return
 				}
 			}
 		}
 	}()
 
-	return barChan, cancel
+	return sub
 }
 
 // ReqHistoricalSchedule requests historical schedule.
-func (ib *IB) ReqHistoricalSchedule(contract *Contract, endDateTime string, duration string, useRTH bool) (HistoricalSchedule, error) {
+func (ib *IB) ReqHistoricalSchedule(contract *Contract, endDateTime string, duration HistoryDuration, useRTH bool) (HistoricalSchedule, error) {
 	ctx, cancel := context.WithTimeout(ib.eClient.Ctx(), ib.config.Timeout)
 	defer cancel()
 
@@ -1847,7 +1962,7 @@ func (ib *IB) ReqHistoricalSchedule(contract *Contract, endDateTime string, dura
 	ch, unsubscribe := ib.pubSub.Subscribe(reqID)
 	defer unsubscribe()
 
-	ib.eClient.ReqHistoricalData(reqID, contract, endDateTime, duration, "1 day", "SCHEDULE", useRTH, 1, false, nil)
+	ib.eClient.ReqHistoricalData(reqID, contract, endDateTime, string(duration), string(BarSize1Day), string(ShowSchedule), useRTH, 1, false, nil)
 
 	select {
 	case <-ctx.Done():
@@ -1872,7 +1987,7 @@ func (ib *IB) ReqHistoricalSchedule(contract *Contract, endDateTime string, dura
 //   - whatToShow: Type of data to retrieve (e.g., "TRADES", "MIDPOINT", "BID", "ASK")
 //   - useRTH: When true, queries only Regular Trading Hours data
 //   - formatDate: Determines the format of returned dates (1: utc, 2: local)
-func (ib *IB) ReqHeadTimeStamp(contract *Contract, whatToShow string, useRTH bool, formatDate int) (time.Time, error) {
+func (ib *IB) ReqHeadTimeStamp(contract *Contract, whatToShow WhatToShow, useRTH bool, formatDate int) (time.Time, error) {
 	ctx, cancel := context.WithTimeout(ib.eClient.Ctx(), ib.config.Timeout)
 	defer cancel()
 
@@ -1881,7 +1996,7 @@ func (ib *IB) ReqHeadTimeStamp(contract *Contract, whatToShow string, useRTH boo
 	ch, unsubscribe := ib.pubSub.Subscribe(reqID)
 	defer unsubscribe()
 
-	ib.eClient.ReqHeadTimeStamp(reqID, contract, whatToShow, useRTH, formatDate)
+	ib.eClient.ReqHeadTimeStamp(reqID, contract, string(whatToShow), useRTH, formatDate)
 
 	select {
 	case <-ctx.Done():
@@ -2087,6 +2202,7 @@ type ScannerSubscriptionOptions struct {
 // ReqScannerSubscription subcribes a scanner that matched the subcription.
 // scannerSubscription contains possible parameters used to filter results.
 // scannerSubscriptionOptions, scannerSubscriptionOptions is for internal use only.Use default value XYZ.
+// It returns the first ranking and cancels the scanner. Use SubscribeScanner for the refreshed rankings.
 func (ib *IB) ReqScannerSubscription(subscription *ScannerSubscription, scannerSubscriptionOptions ...ScannerSubscriptionOptions) ([]ScanData, error) {
 	ctx, cancel := context.WithTimeout(ib.eClient.Ctx(), ib.config.Timeout)
 	defer cancel()
@@ -2116,6 +2232,7 @@ func (ib *IB) ReqScannerSubscription(subscription *ScannerSubscription, scannerS
 			}
 			switch msg {
 			case "end":
+				ib.eClient.CancelScannerSubscription(reqID)
 				return sds, nil
 			default:
 				var sd ScanData
@@ -2152,63 +2269,69 @@ func (ib *IB) ReqScannerSubscription(subscription *ScannerSubscription, scannerS
 //		partially or completely outside.
 //
 // realTimeBarOptions is for internal use only. Use default value XYZ.
-func (ib *IB) ReqRealTimeBars(contract *Contract, barSize int, whatToShow string, useRTH bool, realTimeBarsOptions ...TagValue) (chan RealTimeBar, CancelFunc) {
+//
+// The channel is closed on cancel and on error. Use SubscribeRealTimeBars to know why it was closed.
+func (ib *IB) ReqRealTimeBars(contract *Contract, barSize int, whatToShow WhatToShow, useRTH bool, realTimeBarsOptions ...TagValue) (chan RealTimeBar, CancelFunc) {
+	sub := ib.SubscribeRealTimeBars(contract, barSize, whatToShow, useRTH, realTimeBarsOptions...)
+	return sub.ch, sub.Cancel
+}
+
+// SubscribeRealTimeBars requests realtime bars and returns a subscription streaming them.
+//
+// It takes the same parameters as ReqRealTimeBars. The stream runs until Cancel is called or an error occurs,
+// in which case Err returns the error, e.g. missing market data permissions.
+func (ib *IB) SubscribeRealTimeBars(contract *Contract, barSize int, whatToShow WhatToShow, useRTH bool, realTimeBarsOptions ...TagValue) *RealTimeBarSubscription {
 	ctx := ib.eClient.Ctx()
 
 	reqID := ib.NextID()
 
 	ch, unsubscribe := ib.pubSub.Subscribe(reqID, 100)
 
-	ib.eClient.ReqRealTimeBars(reqID, contract, barSize, whatToShow, useRTH, realTimeBarsOptions)
-
-	rtBarChan := make(chan RealTimeBar, 100)
+	ib.eClient.ReqRealTimeBars(reqID, contract, barSize, string(whatToShow), useRTH, realTimeBarsOptions)
 
-	cancel := func() {
-		ib.eClient.CancelRealTimeBars(reqID)
-		unsubscribe()
-		// drains and safely closes the channel.
-		for {
-			select {
-			case _, ok := <-rtBarChan:
-				if !ok {
-					// Channel is already closed
-					return
-				}
-				// Channel is not empty, drain it.
-			default:
-				// channel is empty, close it.
-				close(rtBarChan)
-				return
-			}
-		}
-	}
+	sub := &RealTimeBarSubscription{Subscription: newSubscription[RealTimeBar](100)}
 
 	go func() {
-		defer cancel()
+		defer unsubscribe()
 		for {
 			select {
 			case <-ctx.Done():
 				log.Error().Err(ctx.Err()).Int64("reqID", reqID).Msg("<ReqRealTimeBars>")
+				sub.finish(ctx.Err())
+				return
+			case <-sub.stop:
+				ib.eClient.CancelRealTimeBars(reqID)
+				sub.finish(nil)
 				return
 			case msg, ok := <-ch:
 				if !ok {
+					sub.finish(nil)
 					return
 				}
 				if isErrorMsg(msg) {
-					log.Error().Err(msg2Error(msg)).Int64("reqID", reqID).Msg("<ReqRealTimeBars>")
+					err := msg2Error(msg)
+					log.Error().Err(err).Int64("reqID", reqID).Msg("<ReqRealTimeBars>")
+					ib.eClient.CancelRealTimeBars(reqID)
+					sub.finish(err)
 					return
 				}
 				var bar RealTimeBar
 				if err := Decode(&bar, msg); err != nil {
 					log.Error().Err(err).Int64("reqID", reqID).Msg("<ReqRealTimeBars>")
+					ib.eClient.CancelRealTimeBars(reqID)
+					sub.finish(err)
+					return
+				}
+				if !sub.send(bar) {
+					ib.eClient.CancelRealTimeBars(reqID)
+					sub.finish(nil)
 					return
 				}
-				rtBarChan <- bar
 			}
 		}
 	}()
 
-	return rtBarChan, cancel
+	return sub
 }
 
 // ReqNewsProviders requests a slice of news providers.
@@ -2260,6 +2383,7 @@ func (ib *IB) ReqNewsArticle(providerCode string, articleID string, newsArticleO
 }
 
 // ReqHistoricalNews requests historical news headlines.
+// hasMore reports whether IB has more headlines in the range. Use DownloadNews to page through them.
 //
 // params:
 // contractID: Search news articles for contract with this conId.
@@ -8,6 +8,7 @@ package ibsync
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"math"
@@ -715,6 +716,33 @@ func TestQualifyContract(t *testing.T) {
 	t.Logf("AMD after qualifiying:, %v", amd)
 }
 
+func TestQualifyContracts(t *testing.T) {
+	ib := getIB()
+
+	contracts := []*Contract{
+		NewStock("XXX", "XXX", "XXX"),
+		NewStock("AMD", "", ""),
+		NewStock("AMD", "SMART", "USD"),
+	}
+	results := ib.QualifyContracts(contracts)
+	if results[0].Err == nil {
+		t.Error("Expected error, got nil")
+	}
+	if !errors.Is(results[1].Err, ErrAmbiguousContract) || len(results[1].Candidates) < 2 {
+		t.Errorf("Expected ambiguous contract with candidates, got %v, %v candidates", results[1].Err, len(results[1].Candidates))
+	}
+	if results[2].Err != nil || contracts[2].ConID == 0 {
+		t.Errorf("Unexpected error: %v", results[2].Err)
+	}
+
+	amd := NewStock("AMD", "", "")
+	results = ib.QualifyContracts([]*Contract{amd}, QualifyPreferCurrency("USD"), QualifyPreferPrimaryExchange("NASDAQ"))
+	if results[0].Err != nil {
+		t.Errorf("Unexpected error: %v, candidates: %v", results[0].Err, results[0].Candidates)
+	}
+	t.Logf("AMD after disambiguation:, %v", amd)
+}
+
 func TestJsonCOntract(t *testing.T) {
 	ib := getIB()
 
@@ -793,6 +821,13 @@ func TestReqMktDepth(t *testing.T) {
 		}
 	}
 
+	book := ticker.OrderBook().Snapshot()
+	if len(book.Bids) != len(bids) || len(book.Asks) != len(asks) {
+		t.Errorf("order book rows %v/%v, DOM levels %v/%v", len(book.Bids), len(book.Asks), len(bids), len(asks))
+	}
+	micro, _ := book.Microprice()
+	t.Logf("order book seq %v, microprice %v, imbalance %v", book.Seq, micro, book.Imbalance(5))
+
 	ib.CancelMktDepth(aapl, false)
 }
 
@@ -880,9 +915,9 @@ func TestReqHistoricalData(t *testing.T) {
 
 	// Request Historical Data
 	endDateTime := lastWednesday12ESTString // format "yyyymmdd HH:mm:ss ttt", where "ttt" is an optional time zone
-	duration := "1 D"                       // "60 S", "30 D", "13 W", "6 M", "10 Y". The unit must be specified (S for seconds, D for days, W for weeks, etc.).
-	barSize := "15 mins"                    // "1 secs", "5 secs", "10 secs", "15 secs", "30 secs", "1 min", "2 mins", "5 mins", etc.
-	whatToShow := "MIDPOINT"                // "TRADES", "MIDPOINT", "BID", "ASK", "BID_ASK", "HISTORICAL_VOLATILITY", etc.
+	duration := DurationDays(1)             // "60 S", "30 D", "13 W", "6 M", "10 Y". The unit must be specified (S for seconds, D for days, W for weeks, etc.).
+	barSize := BarSize15Mins                // "1 secs", "5 secs", "10 secs", "15 secs", "30 secs", "1 min", "2 mins", "5 mins", etc.
+	whatToShow := ShowMidpoint              // "TRADES", "MIDPOINT", "BID", "ASK", "BID_ASK", "HISTORICAL_VOLATILITY", etc.
 	useRTH := true                          // `true` limits data to regular trading hours (RTH), `false` includes all data.
 	formatDate := 1                         // `1` for the "yyyymmdd HH:mm:ss ttt" format, or `2` for Unix timestamps.
 
@@ -916,9 +951,9 @@ func TestReqHistoricalDataUpToDate(t *testing.T) {
 	ib := getIB()
 
 	eurusd := NewForex("EUR", "IDEALPRO", "USD")
-	duration := "60 S"
-	barSize := "5 secs"
-	whatToShow := "MIDPOINT"
+	duration := DurationSeconds(60)
+	barSize := BarSize5Secs
+	whatToShow := ShowMidpoint
 	useRTH := true
 	formatDate := 1
 
@@ -969,6 +1004,38 @@ func TestReqHistoricalSchedule(t *testing.T) {
 	}
 }
 
+func TestSubscribeHistoricalData(t *testing.T) {
+	ib := getIB()
+
+	eurusd := NewForex("EUR", "IDEALPRO", "USD")
+	endDateTime := FormatIBTimeUSEastern(LastWednesday12EST())
+
+	sub := ib.SubscribeHistoricalData(eurusd, endDateTime, "1 D", "1 hour", "MIDPOINT", true, 1)
+	var nbBars int
+	for range sub.Chan() {
+		nbBars++
+	}
+	if err := sub.Err(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	start, end, ok := sub.Period()
+	if !ok || !start.Before(end) || nbBars == 0 {
+		t.Errorf("Unexpected result: %v bars, period %v - %v (%v)", nbBars, start, end, ok)
+	}
+	t.Logf("%v bars from %v to %v", nbBars, start, end)
+
+	// An unknown contract ends the stream with an error.
+	unknown := NewStock("XXXXXX", "SMART", "USD")
+	sub = ib.SubscribeHistoricalData(unknown, endDateTime, "1 D", "1 hour", "TRADES", true, 1)
+	for range sub.Chan() {
+	}
+	if sub.Err() == nil {
+		t.Error("Expected an error for an unknown contract")
+	}
+	t.Logf("error: %v", sub.Err())
+}
+
 func TestReqHeadTimeStamp(t *testing.T) {
 	ib := getIB()
 
@@ -985,6 +1052,32 @@ func TestReqHeadTimeStamp(t *testing.T) {
 	t.Logf("headStamp:, %v", headStamp)
 }
 
+func TestDownloadHistory(t *testing.T) {
+	ib := getIB()
+
+	eurusd := NewForex("EUR", "IDEALPRO", "USD")
+
+	end := LastWednesday12EST()
+	start := end.Add(-3 * 24 * time.Hour)
+	bars, err := ib.DownloadHistory(eurusd, start, end, "1 hour", "MIDPOINT")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(bars) == 0 {
+		t.Error("no bars downloaded")
+		return
+	}
+	for i := 1; i < len(bars); i++ {
+		prev, _ := ParseIBTime(bars[i-1].Date)
+		curr, _ := ParseIBTime(bars[i].Date)
+		if !prev.Before(curr) {
+			t.Errorf("bars not in order: %v, %v", bars[i-1].Date, bars[i].Date)
+		}
+	}
+	t.Logf("bars: %v, first: %v, last: %v", len(bars), bars[0], bars[len(bars)-1])
+}
+
 func TestReqHistogramData(t *testing.T) {
 	ib := getIB()
 
@@ -1068,6 +1161,32 @@ func TestReqHistoricalTickBidAsk(t *testing.T) {
 
 }
 
+func TestHistoricalTickLasts(t *testing.T) {
+	ib := getIB()
+
+	aapl := NewStock("AAPL", "SMART", "USD")
+
+	start := LastWednesday12EST()
+	var n int
+	var last int64
+	for tick, err := range ib.HistoricalTickLasts(aapl, start, start.Add(5*time.Minute), true) {
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		if tick.Time < last {
+			t.Errorf("tick %v out of order: %v", n, tick)
+		}
+		last = tick.Time
+		n++
+	}
+
+	if n < 1 {
+		t.Error("No ticks retrieved")
+	}
+	t.Logf("Historical last ticks number %v", n)
+}
+
 func TestReqScannerParameters(t *testing.T) {
 	ib := getIB()
 
@@ -1107,13 +1226,43 @@ func TestReqScannerSubscription(t *testing.T) {
 
 }
 
+func TestSubscribeScanner(t *testing.T) {
+	ib := getIB()
+
+	ss := NewScannerSubscription()
+	ss.Instrument = "STK"
+	ss.LocationCode = "STK.US.MAJOR"
+	ss.ScanCode = "HOT_BY_VOLUME"
+
+	stream := ib.SubscribeScanner(ss)
+	defer stream.Cancel()
+
+	select {
+	case update, ok := <-stream.Chan():
+		if !ok {
+			t.Errorf("Unexpected error: %v", stream.Err())
+			return
+		}
+		if len(update.Added) != len(update.Ranking) || len(update.Removed) != 0 {
+			t.Errorf("first update: %v added, %v removed, want the %v entries added", len(update.Added), len(update.Removed), len(update.Ranking))
+		}
+		if testing.Verbose() {
+			for _, sd := range update.Ranking {
+				t.Logf("Scan data: %v\n", sd)
+			}
+		}
+	case <-time.After(30 * time.Second):
+		t.Error("no ranking received")
+	}
+}
+
 func TestReqRealTimeBars(t *testing.T) {
 	ib := getIB()
 
 	eurusd := NewForex("EUR", "IDEALPRO", "USD")
 
 	useRTH := true
-	whatToShow := "MIDPOINT" // "TRADES", "MIDPOINT", "BID" or "ASK"
+	whatToShow := ShowMidpoint // "TRADES", "MIDPOINT", "BID" or "ASK"
 	rtBarChan, cancel := ib.ReqRealTimeBars(eurusd, 5, whatToShow, useRTH)
 
 	var rtBars []RealTimeBar
@@ -1207,6 +1356,50 @@ func TestReqHistoricalNews(t *testing.T) {
 
 }
 
+func TestDownloadNews(t *testing.T) {
+	ib := getIB()
+
+	aapl := NewStock("AAPL", "SMART", "USD")
+	err := ib.QualifyContract(aapl)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	headlines, err := ib.DownloadNews(aapl.ConID, []string{"BRFG"}, time.Now().AddDate(0, 0, -7), time.Now(), NewsPageSize(20), NewsMaxResults(50), NewsWithArticles())
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(headlines) > 50 {
+		t.Errorf("Expected at most 50 headlines, got %v", len(headlines))
+	}
+	for i, h := range headlines {
+		if i > 0 && h.Time.After(headlines[i-1].Time) {
+			t.Errorf("Headline %v is newer than the previous one", i)
+		}
+		if h.Article == nil {
+			t.Errorf("Headline %v without article", i)
+		}
+	}
+	t.Logf("Number of headlines: %v", len(headlines))
+}
+
+func TestSubscribeProviderNews(t *testing.T) {
+	ib := getIB()
+
+	stream := ib.SubscribeProviderNews("BRFG")
+	select {
+	case h, ok := <-stream.Chan():
+		if ok {
+			t.Logf("News headline: %+v", h)
+		} else {
+			t.Logf("News stream ended: %v", stream.Err())
+		}
+	case <-time.After(10 * time.Second):
+		t.Log("No news within 10 seconds")
+	}
+	stream.Cancel()
+}
+
 func TestQueryDisplayGroups(t *testing.T) {
 	ib := getIB()
 
@@ -187,6 +187,12 @@ const (
 	OrderStatusUnknown       = ibapi.OrderStatusUnknown
 )
 
+const (
+	// FaDataType
+	GROUPS  = ibapi.GROUPS
+	ALIASES = ibapi.ALIASES
+)
+
 const (
 	// TickType
 	BID_SIZE                  = ibapi.BID_SIZE
@@ -0,0 +1,134 @@
+package ibsync
+
+import (
+	"strconv"
+	"sync"
+)
+
+// Market data types, as requested with ReqMarketDataType and reported for each ticker.
+const (
+	MarketDataTypeLive          int64 = 1 // Realtime streaming market data
+	MarketDataTypeFrozen        int64 = 2 // Last data recorded at the close
+	MarketDataTypeDelayed       int64 = 3 // Delayed market data, usually by 15-20 minutes
+	MarketDataTypeDelayedFrozen int64 = 4 // Last delayed data recorded at the close
+)
+
+// MarketDataTypeName returns the name of a market data type.
+func MarketDataTypeName(marketDataType int64) string {
+	switch marketDataType {
+	case 0:
+		return "Unknown"
+	case MarketDataTypeLive:
+		return "Live"
+	case MarketDataTypeFrozen:
+		return "Frozen"
+	case MarketDataTypeDelayed:
+		return "Delayed"
+	case MarketDataTypeDelayedFrozen:
+		return "DelayedFrozen"
+	}
+	return "MarketDataType(" + strconv.FormatInt(marketDataType, 10) + ")"
+}
+
+// IsDelayedMarketDataType reports whether a market data type is delayed.
+func IsDelayedMarketDataType(marketDataType int64) bool {
+	return marketDataType == MarketDataTypeDelayed || marketDataType == MarketDataTypeDelayedFrozen
+}
+
+// MarketDataPolicy tells TWS which data to send when live data is not available.
+//
+// It is applied with ReqMarketDataType, for the whole session. TWS falls back per contract:
+// the contracts with market data subscriptions stream live data,
+// the others stream delayed data if the policy allows it, or fail with an error.
+type MarketDataPolicy int64
+
+const (
+	MarketDataLiveOnly            MarketDataPolicy = MarketDataPolicy(MarketDataTypeLive)          // Live data only, requests without subscription fail
+	MarketDataLiveOrFrozen        MarketDataPolicy = MarketDataPolicy(MarketDataTypeFrozen)        // Live data, frozen data after the close
+	MarketDataLiveOrDelayed       MarketDataPolicy = MarketDataPolicy(MarketDataTypeDelayed)       // Live data, delayed data without subscription
+	MarketDataLiveOrDelayedFrozen MarketDataPolicy = MarketDataPolicy(MarketDataTypeDelayedFrozen) // Live data, delayed data without subscription, frozen data after the close
+)
+
+func (p MarketDataPolicy) String() string {
+	switch p {
+	case MarketDataLiveOnly:
+		return "LiveOnly"
+	case MarketDataLiveOrFrozen:
+		return "LiveOrFrozen"
+	case MarketDataLiveOrDelayed:
+		return "LiveOrDelayed"
+	case MarketDataLiveOrDelayedFrozen:
+		return "LiveOrDelayedFrozen"
+	}
+	return "MarketDataPolicy(" + strconv.FormatInt(int64(p), 10) + ")"
+}
+
+// SetMarketDataPolicy sets the market data policy of the session. It is applied again on reconnection.
+func (ib *IB) SetMarketDataPolicy(policy MarketDataPolicy) {
+	ib.config.MarketDataPolicy = policy
+	ib.ReqMarketDataType(int64(policy))
+}
+
+// MarketDataPolicy returns the market data policy of the session. It is zero if none was set.
+func (ib *IB) MarketDataPolicy() MarketDataPolicy {
+	return ib.config.MarketDataPolicy
+}
+
+// MarketDataTypeEvent reports a change of the market data type of a ticker, e.g. a fall back to delayed data.
+type MarketDataTypeEvent struct {
+	ReqID    int64
+	Contract Contract
+	Previous int64 // 0 for the first type reported
+	Current  int64
+}
+
+// MarketDataTypeChan returns a channel that receives the changes of the market data type of the tickers.
+//
+// Do not close the channel.
+func (ib *IB) MarketDataTypeChan() chan MarketDataTypeEvent {
+	ctx := ib.eClient.Ctx()
+	eventChan := make(chan MarketDataTypeEvent)
+	ch, unsubscribe := ib.pubSub.Subscribe("MarketDataType", 100)
+	var once sync.Once
+	go func() {
+		defer unsubscribe()
+		defer func() { once.Do(func() { close(eventChan) }) }()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				var event MarketDataTypeEvent
+				if err := Decode(&event, msg); err != nil {
+					return
+				}
+				eventChan <- event
+			}
+		}
+	}()
+
+	return eventChan
+}
+
+// isDelayedTickType reports whether a tick type is only sent with delayed data.
+func isDelayedTickType(tickType TickType) bool {
+	switch tickType {
+	case DELAYED_BID, DELAYED_ASK, DELAYED_LAST, DELAYED_BID_SIZE, DELAYED_ASK_SIZE, DELAYED_LAST_SIZE,
+		DELAYED_HIGH, DELAYED_LOW, DELAYED_VOLUME, DELAYED_CLOSE, DELAYED_OPEN,
+		DELAYED_BID_OPTION, DELAYED_ASK_OPTION, DELAYED_LAST_OPTION, DELAYED_MODEL_OPTION,
+		DELAYED_LAST_TIMESTAMP, DELAYED_HALTED, DELAYED_YIELD_BID, DELAYED_YIELD_ASK:
+		return true
+	}
+	return false
+}
+
+// delayedMarketDataType returns the delayed counterpart of a market data type.
+func delayedMarketDataType(marketDataType int64) int64 {
+	if marketDataType == MarketDataTypeFrozen || marketDataType == MarketDataTypeDelayedFrozen {
+		return MarketDataTypeDelayedFrozen
+	}
+	return MarketDataTypeDelayed
+}
@@ -0,0 +1,61 @@
+package ibsync
+
+import (
+	"testing"
+)
+
+func TestTickerMarketDataType(t *testing.T) {
+	state := NewState()
+	pubSub := NewPubSub()
+	w := NewWrapperSync(state, pubSub)
+
+	contract := NewStock("AAPL", "SMART", "USD")
+	state.mu.Lock()
+	ticker := state.startTicker(1, contract, "mktData")
+	state.mu.Unlock()
+
+	events, unsubscribe := pubSub.Subscribe("MarketDataType", 10)
+	defer unsubscribe()
+	nextEvent := func() (MarketDataTypeEvent, bool) {
+		select {
+		case msg := <-events:
+			var event MarketDataTypeEvent
+			if err := Decode(&event, msg); err != nil {
+				t.Fatal(err)
+			}
+			return event, true
+		default:
+			return MarketDataTypeEvent{}, false
+		}
+	}
+
+	tests := []struct {
+		name      string
+		update    func()
+		want      int64
+		wantEvent bool
+	}{
+		{"reported live", func() { w.MarketDataType(1, MarketDataTypeLive) }, MarketDataTypeLive, true},
+		{"live tick", func() { w.TickPrice(1, BID, 100, TickAttrib{}) }, MarketDataTypeLive, false},
+		{"delayed tick", func() { w.TickPrice(1, DELAYED_ASK, 101, TickAttrib{}) }, MarketDataTypeDelayed, true},
+		{"delayed again", func() { w.TickSize(1, DELAYED_BID_SIZE, StringToDecimal("10")) }, MarketDataTypeDelayed, false},
+		{"reported frozen", func() { w.MarketDataType(1, MarketDataTypeFrozen) }, MarketDataTypeFrozen, true},
+		{"delayed warning", func() { w.Error(1, 0, WarnDelayedMarketData.Code, WarnDelayedMarketData.Msg, "") }, MarketDataTypeDelayedFrozen, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := ticker.MarketDataType()
+			tt.update()
+			if got := ticker.MarketDataType(); got != tt.want {
+				t.Errorf("MarketDataType() = %v, want %v", MarketDataTypeName(got), MarketDataTypeName(tt.want))
+			}
+			event, ok := nextEvent()
+			if ok != tt.wantEvent {
+				t.Fatalf("event received = %v, want %v", ok, tt.wantEvent)
+			}
+			if ok && (event.Previous != previous || event.Current != tt.want || event.Contract.Symbol != "AAPL" || event.ReqID != 1) {
+				t.Errorf("event = %+v", event)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package ibsync
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxNewsTicks is the number of news ticks kept by the state, the oldest are dropped.
+const maxNewsTicks = 1000
+
+// appendNewsTick appends a news tick, keeping the last maxNewsTicks.
+func appendNewsTick(ticks []NewsTick, nt NewsTick) []NewsTick {
+	ticks = append(ticks, nt)
+	if len(ticks) > maxNewsTicks {
+		ticks = slices.Delete(ticks, 0, len(ticks)-maxNewsTicks)
+	}
+	return ticks
+}
+
+// NewsHeadline is a news headline, from a news tick or the historical news, with its article if fetched.
+type NewsHeadline struct {
+	Time         time.Time
+	ProviderCode string
+	ArticleID    string
+	Headline     string
+	ExtraData    string       // Extra data of a news tick
+	Article      *NewsArticle // Body of the article, nil if not fetched
+}
+
+func newsHeadlineFromTick(nt NewsTick) NewsHeadline {
+	return NewsHeadline{
+		Time:         time.UnixMilli(nt.TimeStamp),
+		ProviderCode: nt.ProviderCode,
+		ArticleID:    nt.ArticleId,
+		Headline:     nt.Headline,
+		ExtraData:    nt.ExtraData,
+	}
+}
+
+func newsHeadlineFromHistorical(hn HistoricalNews) NewsHeadline {
+	return NewsHeadline{Time: hn.Time, ProviderCode: hn.ProviderCode, ArticleID: hn.ArticleID, Headline: hn.Headline}
+}
+
+// NewsOptions are the options of the news streams and of DownloadNews.
+type NewsOptions struct {
+	Articles   bool  // Fetch the article of each headline with ReqNewsArticle
+	PageSize   int64 // Headlines per historical news request, default and maximum 300
+	MaxResults int   // Maximum number of historical headlines, 0 for no limit
+}
+
+// NewsWithArticles is a news option to fetch the article of each headline.
+func NewsWithArticles() func(*NewsOptions) {
+	return func(o *NewsOptions) {
+		o.Articles = true
+	}
+}
+
+// NewsPageSize is an option of DownloadNews to set the number of headlines per request.
+func NewsPageSize(n int64) func(*NewsOptions) {
+	return func(o *NewsOptions) {
+		o.PageSize = n
+	}
+}
+
+// NewsMaxResults is an option of DownloadNews to limit the number of headlines.
+func NewsMaxResults(n int) func(*NewsOptions) {
+	return func(o *NewsOptions) {
+		o.MaxResults = n
+	}
+}
+
+func newsOptions(options []func(*NewsOptions)) NewsOptions {
+	opts := NewsOptions{PageSize: 300}
+	for _, option := range options {
+		option(&opts)
+	}
+	if opts.PageSize <= 0 || opts.PageSize > 300 {
+		opts.PageSize = 300
+	}
+	return opts
+}
+
+// fetchArticle sets the article of a headline. Failures are logged and leave the article nil.
+func (ib *IB) fetchArticle(h *NewsHeadline) {
+	article, err := ib.ReqNewsArticle(h.ProviderCode, h.ArticleID)
+	if err != nil {
+		log.Warn().Err(err).Str("provider", h.ProviderCode).Str("articleID", h.ArticleID).Msg("<NewsArticle>")
+		return
+	}
+	h.Article = article
+}
+
+// NewsStream is a stream of news headlines.
+type NewsStream struct {
+	*Subscription[NewsHeadline]
+}
+
+// NewsProviderContract returns the contract of the broad tape news feed of a provider, e.g. "BRFG".
+func NewsProviderContract(providerCode string) *Contract {
+	contract := NewContract()
+	contract.Symbol = providerCode + ":" + providerCode + "_ALL"
+	contract.SecType = "NEWS"
+	contract.Exchange = providerCode
+	return contract
+}
+
+// SubscribeContractNews streams the news ticks of a contract from the providers, e.g. "BRFG" and "DJ-N".
+// The stream runs until Cancel is called or an error occurs, e.g. no news subscription.
+func (ib *IB) SubscribeContractNews(contract *Contract, providerCodes []string, options ...func(*NewsOptions)) *NewsStream {
+	return ib.subscribeNews(contract, "mdoff,292:"+strings.Join(providerCodes, "+"), newsOptions(options))
+}
+
+// SubscribeProviderNews streams all the news ticks of a provider, its broad tape news feed.
+// The stream runs until Cancel is called or an error occurs, e.g. no news subscription.
+func (ib *IB) SubscribeProviderNews(providerCode string, options ...func(*NewsOptions)) *NewsStream {
+	return ib.subscribeNews(NewsProviderContract(providerCode), "mdoff,292", newsOptions(options))
+}
+
+// subscribeNews requests the news ticks as market data, without ticker: the stream has its own request.
+//
+// The headlines are queued and sent by a worker, which fetches their articles if requested,
+// so that slow article requests or a slow reader never block the reading of the ticks.
+func (ib *IB) subscribeNews(contract *Contract, genericTickList string, opts NewsOptions) *NewsStream {
+	ctx := ib.eClient.Ctx()
+
+	reqID := ib.NextID()
+
+	ch, unsubscribe := ib.pubSub.Subscribe(reqID, 100)
+
+	ib.state.mu.Lock()
+	ib.state.newsReqIDs[reqID] = struct{}{}
+	ib.state.mu.Unlock()
+
+	ib.eClient.ReqMktData(reqID, contract, genericTickList, false, false, nil)
+
+	stream := &NewsStream{Subscription: newSubscription[NewsHeadline](100)}
+
+	headlines := make(chan NewsHeadline)
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		for h := range headlines {
+			if opts.Articles {
+				ib.fetchArticle(&h)
+			}
+			if !stream.send(h) {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer unsubscribe()
+		var err error
+		defer func() {
+			ib.state.mu.Lock()
+			delete(ib.state.newsReqIDs, reqID)
+			ib.state.mu.Unlock()
+			// Unblock a worker waiting for the reader, which may only watch Done.
+			stream.stopOnce.Do(func() { close(stream.stop) })
+			close(headlines)
+			<-workerDone
+			stream.finish(err)
+		}()
+		var queue []NewsHeadline
+		for {
+			var next chan<- NewsHeadline
+			var head NewsHeadline
+			if len(queue) > 0 {
+				next, head = headlines, queue[0]
+			}
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+				log.Error().Err(err).Int64("reqID", reqID).Msg("<SubscribeNews>")
+				return
+			case <-stream.stop:
+				ib.eClient.CancelMktData(reqID)
+				return
+			case <-workerDone:
+				// The worker stops when the stream is cancelled
+				ib.eClient.CancelMktData(reqID)
+				return
+			case next <- head:
+				queue = queue[1:]
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				if isErrorMsg(msg) {
+					cmp := msg2Error(msg)
+					if IsWarning(cmp) {
+						log.Warn().Err(cmp).Int64("reqID", reqID).Msg("<SubscribeNews>")
+						continue
+					}
+					err = cmp
+					log.Error().Err(err).Int64("reqID", reqID).Msg("<SubscribeNews>")
+					ib.eClient.CancelMktData(reqID)
+					return
+				}
+				var nt NewsTick
+				if err = Decode(&nt, msg); err != nil {
+					log.Error().Err(err).Int64("reqID", reqID).Msg("<SubscribeNews>")
+					ib.eClient.CancelMktData(reqID)
+					return
+				}
+				queue = append(queue, newsHeadlineFromTick(nt))
+			}
+		}
+	}()
+
+	return stream
+}
+
+// pageNews pages through the historical news, newest first, requesting pages ending at the oldest headline received
+// until IB has no more or maxResults is reached. The headlines are deduplicated by provider and article ID.
+func pageNews(end time.Time, pageSize int64, maxResults int, fetch func(end time.Time, n int64) ([]HistoricalNews, bool, error)) ([]HistoricalNews, error) {
+	var all []HistoricalNews
+	seen := make(map[string]bool)
+	atEnd := 0 // Headlines received at the end, requested again as the end is inclusive
+	for {
+		n := pageSize
+		if maxResults > 0 {
+			n = min(n, int64(maxResults-len(all)+atEnd))
+		}
+		page, hasMore, err := fetch(end, n)
+		if err != nil {
+			return all, err
+		}
+		added := 0
+		for _, hn := range page {
+			key := Key(hn.ProviderCode, hn.ArticleID)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			all = append(all, hn)
+			added++
+			if hn.Time.Before(end) {
+				end = hn.Time
+			}
+		}
+		atEnd = 0
+		for _, hn := range all {
+			if hn.Time.Equal(end) {
+				atEnd++
+			}
+		}
+		// The end is inclusive: a page of headlines all at the same time would be requested forever.
+		if !hasMore || added == 0 || (maxResults > 0 && len(all) >= maxResults) {
+			return all, nil
+		}
+	}
+}
+
+// DownloadNews downloads the historical news headlines of a contract between start and end, newest first,
+// paging through ReqHistoricalNews until IB has no more. providerCodes are the providers, e.g. "BRFG" and "DJ-N".
+// With NewsWithArticles, the article of each headline is fetched too.
+func (ib *IB) DownloadNews(contractID int64, providerCodes []string, start, end time.Time, options ...func(*NewsOptions)) ([]NewsHeadline, error) {
+	opts := newsOptions(options)
+	providers := strings.Join(providerCodes, "+")
+	hns, err := pageNews(end, opts.PageSize, opts.MaxResults, func(end time.Time, n int64) ([]HistoricalNews, bool, error) {
+		page, err, hasMore := ib.ReqHistoricalNews(contractID, providers, start, end, n)
+		return page, hasMore, err
+	})
+	headlines := make([]NewsHeadline, 0, len(hns))
+	for _, hn := range hns {
+		h := newsHeadlineFromHistorical(hn)
+		if opts.Articles {
+			ib.fetchArticle(&h)
+		}
+		headlines = append(headlines, h)
+	}
+	return headlines, err
+}
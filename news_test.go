@@ -0,0 +1,75 @@
+package ibsync
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAppendNewsTick(t *testing.T) {
+	var ticks []NewsTick
+	for i := range maxNewsTicks + 10 {
+		ticks = appendNewsTick(ticks, NewsTick{ArticleId: fmt.Sprint(i)})
+	}
+	if len(ticks) != maxNewsTicks || ticks[0].ArticleId != "10" {
+		t.Errorf("%v ticks, first %q, want %v ticks from 10", len(ticks), ticks[0].ArticleId, maxNewsTicks)
+	}
+
+	h := newsHeadlineFromTick(NewsTick{TimeStamp: 1704207600000, ProviderCode: "BRFG", ArticleId: "BRFG$1", Headline: "h"})
+	if !h.Time.Equal(time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)) || h.ArticleID != "BRFG$1" {
+		t.Errorf("newsHeadlineFromTick() = %+v", h)
+	}
+}
+
+func TestPageNews(t *testing.T) {
+	base := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	// 7 headlines, one per hour, two of them at the same time.
+	var news []HistoricalNews
+	for i, hour := range []int{6, 5, 4, 3, 3, 2, 1} {
+		news = append(news, HistoricalNews{Time: base.Add(time.Duration(hour) * time.Hour), ProviderCode: "BRFG", ArticleID: fmt.Sprint(i)})
+	}
+	var requests int
+	fetch := func(end time.Time, n int64) ([]HistoricalNews, bool, error) {
+		requests++
+		var page []HistoricalNews
+		for _, hn := range news {
+			if !hn.Time.After(end) && int64(len(page)) < n {
+				page = append(page, hn)
+			}
+		}
+		// IB has more if the page is full and older headlines remain.
+		hasMore := int64(len(page)) == n && page[len(page)-1].Time.After(news[len(news)-1].Time)
+		return page, hasMore, nil
+	}
+
+	got, err := pageNews(base.Add(10*time.Hour), 3, 0, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 7 {
+		t.Fatalf("pageNews() = %v headlines in %v requests, want 7", len(got), requests)
+	}
+	for i, hn := range got {
+		if hn.ArticleID != fmt.Sprint(i) {
+			t.Errorf("headline %v = %v, want newest first without duplicates", i, hn.ArticleID)
+		}
+	}
+
+	if got, _ := pageNews(base.Add(10*time.Hour), 3, 4, fetch); len(got) != 4 {
+		t.Errorf("pageNews() with maxResults = %v headlines, want 4", len(got))
+	}
+
+	errTest := errors.New("test")
+	calls := 0
+	got, err = pageNews(base.Add(10*time.Hour), 3, 0, func(end time.Time, n int64) ([]HistoricalNews, bool, error) {
+		calls++
+		if calls == 2 {
+			return nil, false, errTest
+		}
+		return fetch(end, n)
+	})
+	if !errors.Is(err, errTest) || len(got) != 3 {
+		t.Errorf("pageNews() with an error = %v headlines, %v", len(got), err)
+	}
+}
@@ -0,0 +1,448 @@
+package ibsync
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// OptionChainOptions holds the filters and settings of IB.OptionChain.
+type OptionChainOptions struct {
+	Exchange       string   // Exchange of the options, default "SMART"
+	TradingClass   string   // Trading class of the options, default the underlying symbol if listed, else the first one
+	MinDTE         int      // Minimum days to expiration
+	MaxDTE         int      // Maximum days to expiration, 0 for no limit
+	MaxExpirations int      // Maximum number of expirations, nearest first, 0 for no limit
+	Moneyness      float64  // Keep the strikes within Moneyness of the underlying price, e.g. 0.1 for +/-10%, 0 for no limit
+	StrikesAround  int      // Keep the StrikesAround strikes below and above the underlying price, 0 for no limit
+	MinDelta       float64  // Minimum absolute delta, 0 for no limit
+	MaxDelta       float64  // Maximum absolute delta, 0 for no limit
+	Rights         []string // Option rights, default "C" and "P"
+	Concurrency    int      // Maximum number of concurrent requests, default 8
+	Stream         bool     // Stream the market data of the options instead of taking snapshots
+}
+
+// OptionChainDTE is an option of OptionChain to keep the expirations between minDTE and maxDTE days, maxDTE 0 for no limit.
+func OptionChainDTE(minDTE, maxDTE int) func(*OptionChainOptions) {
+	return func(o *OptionChainOptions) {
+		o.MinDTE = minDTE
+		o.MaxDTE = maxDTE
+	}
+}
+
+// OptionChainExpirations is an option of OptionChain to keep the n nearest expirations.
+func OptionChainExpirations(n int) func(*OptionChainOptions) {
+	return func(o *OptionChainOptions) {
+		o.MaxExpirations = n
+	}
+}
+
+// OptionChainMoneyness is an option of OptionChain to keep the strikes within moneyness of the underlying price, e.g. 0.1 for +/-10%.
+func OptionChainMoneyness(moneyness float64) func(*OptionChainOptions) {
+	return func(o *OptionChainOptions) {
+		o.Moneyness = moneyness
+	}
+}
+
+// OptionChainStrikesAround is an option of OptionChain to keep the n strikes below and the n strikes above the underlying price.
+func OptionChainStrikesAround(n int) func(*OptionChainOptions) {
+	return func(o *OptionChainOptions) {
+		o.StrikesAround = n
+	}
+}
+
+// OptionChainDelta is an option of OptionChain to keep the options with an absolute delta between minDelta and maxDelta.
+// Options without greeks are dropped.
+func OptionChainDelta(minDelta, maxDelta float64) func(*OptionChainOptions) {
+	return func(o *OptionChainOptions) {
+		o.MinDelta = minDelta
+		o.MaxDelta = maxDelta
+	}
+}
+
+// OptionChainRights is an option of OptionChain to select the rights, "C" and/or "P".
+func OptionChainRights(rights ...string) func(*OptionChainOptions) {
+	return func(o *OptionChainOptions) {
+		o.Rights = rights
+	}
+}
+
+// OptionChainExchange is an option of OptionChain to select the exchange and trading class of the options.
+func OptionChainExchange(exchange, tradingClass string) func(*OptionChainOptions) {
+	return func(o *OptionChainOptions) {
+		o.Exchange = exchange
+		o.TradingClass = tradingClass
+	}
+}
+
+// OptionChainConcurrency is an option of OptionChain to set the maximum number of concurrent requests.
+func OptionChainConcurrency(n int) func(*OptionChainOptions) {
+	return func(o *OptionChainOptions) {
+		o.Concurrency = n
+	}
+}
+
+// OptionChainStream is an option of OptionChain to stream the market data of the options.
+// Call Cancel on the matrix to stop the streams.
+func OptionChainStream() func(*OptionChainOptions) {
+	return func(o *OptionChainOptions) {
+		o.Stream = true
+	}
+}
+
+// OptionQuote is the market data of an option of the chain.
+// With a streaming chain, its values are updated live.
+type OptionQuote struct {
+	Contract *Contract
+	Ticker   *Ticker
+}
+
+// Bid returns the bid price.
+func (q *OptionQuote) Bid() float64 { return q.Ticker.Bid() }
+
+// Ask returns the ask price.
+func (q *OptionQuote) Ask() float64 { return q.Ticker.Ask() }
+
+// Greeks returns the greeks of the option, see Ticker.Greeks.
+func (q *OptionQuote) Greeks() TickOptionComputation { return q.Ticker.Greeks() }
+
+// optionKey identifies an option of the matrix.
+type optionKey struct {
+	expiry string
+	strike float64
+	right  string
+}
+
+// OptionChainMatrix is the quotes and greeks of an option chain, by expiration, strike and right.
+type OptionChainMatrix struct {
+	Underlying      *Contract
+	UnderlyingPrice float64
+	Exchange        string
+	TradingClass    string
+	Multiplier      string
+	Expirations     []string  // Sorted expirations, "YYYYMMDD"
+	Strikes         []float64 // Sorted strikes
+	quotes          map[optionKey]*OptionQuote
+	cancel          func()
+}
+
+// Quote returns the quote of an option. ok is false if the option is not part of the matrix.
+func (m *OptionChainMatrix) Quote(expiry string, strike float64, right string) (quote *OptionQuote, ok bool) {
+	quote, ok = m.quotes[optionKey{expiry, strike, right}]
+	return quote, ok
+}
+
+// Quotes returns all the quotes, sorted by expiration, strike and right.
+func (m *OptionChainMatrix) Quotes() []*OptionQuote {
+	keys := make([]optionKey, 0, len(m.quotes))
+	for k := range m.quotes {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, compareOptionKeys)
+	quotes := make([]*OptionQuote, len(keys))
+	for i, k := range keys {
+		quotes[i] = m.quotes[k]
+	}
+	return quotes
+}
+
+func compareOptionKeys(a, b optionKey) int {
+	switch {
+	case a.expiry != b.expiry:
+		if a.expiry < b.expiry {
+			return -1
+		}
+		return 1
+	case a.strike != b.strike:
+		if a.strike < b.strike {
+			return -1
+		}
+		return 1
+	case a.right < b.right:
+		return -1
+	case a.right > b.right:
+		return 1
+	}
+	return 0
+}
+
+// Cancel stops the market data streams of a streaming chain. It does nothing for a snapshot chain.
+func (m *OptionChainMatrix) Cancel() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+}
+
+// OptionChain builds the option chain of an underlying with the quotes and greeks of its options.
+//
+// The expirations and strikes come from ReqSecDefOptParams and are filtered by days to expiration and moneyness.
+// The options are qualified with one ReqContractDetails per expiration and right, then their market data requested,
+// with at most Concurrency requests at once.
+// The options that do not exist, e.g. a strike not listed for an expiration, are skipped.
+// The delta filter is applied once the greeks are received.
+//
+//	chain, err := ib.OptionChain(spx, OptionChainDTE(0, 45), OptionChainMoneyness(0.05))
+func (ib *IB) OptionChain(underlying *Contract, options ...func(*OptionChainOptions)) (*OptionChainMatrix, error) {
+	opts := OptionChainOptions{Exchange: "SMART", Rights: []string{"C", "P"}, Concurrency: 8}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	if underlying.ConID == 0 {
+		if err := ib.QualifyContract(underlying); err != nil {
+			return nil, fmt.Errorf("qualify underlying: %w", err)
+		}
+	}
+	secType, futFopExchange := "OPT", ""
+	if underlying.SecType == "FUT" {
+		secType, futFopExchange = "FOP", underlying.Exchange
+	}
+	chains, err := ib.ReqSecDefOptParams(underlying.Symbol, futFopExchange, underlying.SecType, underlying.ConID)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := selectOptionChain(chains, opts.Exchange, opts.TradingClass, underlying.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &OptionChainMatrix{
+		Underlying:   underlying,
+		Exchange:     chain.Exchange,
+		TradingClass: chain.TradingClass,
+		Multiplier:   chain.Multiplier,
+		quotes:       make(map[optionKey]*OptionQuote),
+	}
+	m.UnderlyingPrice, err = ib.underlyingPrice(underlying)
+	if err != nil && (opts.Moneyness > 0 || opts.StrikesAround > 0) {
+		return nil, err
+	}
+
+	expirations := filterExpirations(chain.Expirations, time.Now(), opts.MinDTE, opts.MaxDTE, opts.MaxExpirations)
+	strikes := filterStrikes(chain.Strikes, m.UnderlyingPrice, opts.Moneyness, opts.StrikesAround)
+
+	template := NewOption(underlying.Symbol, "", 0, "", chain.Exchange, chain.Multiplier, underlying.Currency)
+	template.SecType = secType
+	template.TradingClass = chain.TradingClass
+	contracts := ib.qualifyOptions(template, expirations, strikes, opts.Rights, opts.Concurrency)
+
+	stream := opts.Stream && opts.MinDelta == 0 && opts.MaxDelta == 0
+	quotes := ib.optionQuotes(contracts, opts.Concurrency, stream)
+
+	if opts.MinDelta > 0 || opts.MaxDelta > 0 {
+		quotes = slices.DeleteFunc(quotes, func(q *OptionQuote) bool {
+			return !deltaInRange(q.Greeks(), opts.MinDelta, opts.MaxDelta)
+		})
+		if opts.Stream {
+			var kept []*Contract
+			for _, q := range quotes {
+				kept = append(kept, q.Contract)
+			}
+			quotes = ib.optionQuotes(kept, opts.Concurrency, true)
+		}
+	}
+
+	for _, q := range quotes {
+		key := optionKey{q.Contract.LastTradeDateOrContractMonth, q.Contract.Strike, q.Contract.Right}
+		m.quotes[key] = q
+		if !slices.Contains(m.Expirations, key.expiry) {
+			m.Expirations = append(m.Expirations, key.expiry)
+		}
+		if !slices.Contains(m.Strikes, key.strike) {
+			m.Strikes = append(m.Strikes, key.strike)
+		}
+	}
+	slices.Sort(m.Expirations)
+	slices.Sort(m.Strikes)
+
+	if opts.Stream {
+		m.cancel = sync.OnceFunc(func() {
+			for _, q := range quotes {
+				ib.CancelMktData(q.Contract)
+			}
+		})
+	}
+	return m, nil
+}
+
+// qualifyOptions qualifies the options of the expirations, strikes and rights, with one ReqContractDetails
+// per expiration and right, the strike unset, concurrency at a time. The options that do not exist are skipped.
+// template holds the other fields of the options. The contracts are sorted by expiration, strike and right.
+func (ib *IB) qualifyOptions(template *Contract, expirations []string, strikes []float64, rights []string, concurrency int) []*Contract {
+	var mu sync.Mutex
+	var contracts []*Contract
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(concurrency, 1))
+	for _, expiry := range expirations {
+		for _, right := range rights {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				request := *template
+				request.LastTradeDateOrContractMonth = expiry
+				request.Right = right
+				cds, err := ib.ReqContractDetails(&request)
+				if err != nil {
+					log.Debug().Err(err).Stringer("contract", &request).Msg("<OptionChain> skipped")
+					return
+				}
+				matched := matchOptions(cds, strikes)
+				mu.Lock()
+				contracts = append(contracts, matched...)
+				mu.Unlock()
+			}()
+		}
+	}
+	wg.Wait()
+	slices.SortFunc(contracts, func(a, b *Contract) int {
+		return compareOptionKeys(optionKey{a.LastTradeDateOrContractMonth, a.Strike, a.Right}, optionKey{b.LastTradeDateOrContractMonth, b.Strike, b.Right})
+	})
+	return contracts
+}
+
+// matchOptions returns the contracts of the details whose strike is one of strikes.
+func matchOptions(cds []ContractDetails, strikes []float64) []*Contract {
+	var contracts []*Contract
+	for _, cd := range cds {
+		if slices.Contains(strikes, cd.Contract.Strike) {
+			contract := cd.Contract
+			contracts = append(contracts, &contract)
+		}
+	}
+	return contracts
+}
+
+// optionQuotes requests the market data of qualified contracts, concurrency at a time.
+// With stream, the market data is streamed, else snapshots are taken.
+// A snapshot failing, e.g. with WarnCompetingLiveSession, skips the option: its data would be missing.
+func (ib *IB) optionQuotes(contracts []*Contract, concurrency int, stream bool) []*OptionQuote {
+	var mu sync.Mutex
+	var quotes []*OptionQuote
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(concurrency, 1))
+	for _, contract := range contracts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			var ticker *Ticker
+			if stream {
+				ticker = ib.ReqMktData(contract, "")
+			} else {
+				var err error
+				ticker, err = ib.Snapshot(contract)
+				if err != nil && !errors.Is(err, WarnDelayedMarketData) && !errors.Is(err, ErrPartlyNotSubsribed) {
+					log.Warn().Err(err).Stringer("contract", contract).Msg("<OptionChain> snapshot")
+					return
+				}
+			}
+			mu.Lock()
+			quotes = append(quotes, &OptionQuote{Contract: contract, Ticker: ticker})
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return quotes
+}
+
+// underlyingPrice returns the market price of the underlying, or its close if there is none.
+// Snapshot returns at once on WarnCompetingLiveSession, without data: it is an error.
+func (ib *IB) underlyingPrice(underlying *Contract) (float64, error) {
+	ticker, err := ib.Snapshot(underlying)
+	if err != nil && !errors.Is(err, WarnDelayedMarketData) && !errors.Is(err, ErrPartlyNotSubsribed) {
+		return math.NaN(), fmt.Errorf("underlying snapshot: %w", err)
+	}
+	price := ticker.MarketPrice()
+	if math.IsNaN(price) || price <= 0 {
+		price = ticker.Close()
+	}
+	if math.IsNaN(price) || price <= 0 {
+		return math.NaN(), errors.New("no underlying price")
+	}
+	return price, nil
+}
+
+// selectOptionChain selects the option chain of an exchange and trading class.
+// Without trading class, the one named after the symbol is preferred.
+func selectOptionChain(chains []OptionChain, exchange, tradingClass, symbol string) (OptionChain, error) {
+	var candidates []OptionChain
+	for _, c := range chains {
+		if c.Exchange == exchange && (tradingClass == "" || c.TradingClass == tradingClass) {
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) == 0 {
+		return OptionChain{}, fmt.Errorf("no option chain on %v for trading class %q", exchange, tradingClass)
+	}
+	for _, c := range candidates {
+		if c.TradingClass == symbol {
+			return c, nil
+		}
+	}
+	return candidates[0], nil
+}
+
+// filterExpirations returns the sorted expirations between minDTE and maxDTE days from now, at most maxCount of them.
+func filterExpirations(expirations []string, now time.Time, minDTE, maxDTE, maxCount int) []string {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	var filtered []string
+	for _, expiry := range expirations {
+		t, err := time.Parse("20060102", expiry)
+		if err != nil {
+			continue
+		}
+		dte := int(t.Sub(today) / historyDay)
+		if dte < minDTE || (maxDTE > 0 && dte > maxDTE) {
+			continue
+		}
+		filtered = append(filtered, expiry)
+	}
+	slices.Sort(filtered)
+	if maxCount > 0 && len(filtered) > maxCount {
+		filtered = filtered[:maxCount]
+	}
+	return filtered
+}
+
+// filterStrikes returns the sorted strikes within moneyness of price and among the around strikes on each side of it.
+func filterStrikes(strikes []float64, price float64, moneyness float64, around int) []float64 {
+	sorted := slices.Clone(strikes)
+	slices.Sort(sorted)
+	if math.IsNaN(price) {
+		return sorted
+	}
+	if moneyness > 0 {
+		sorted = slices.DeleteFunc(sorted, func(k float64) bool {
+			return k < price*(1-moneyness) || k > price*(1+moneyness)
+		})
+	}
+	if around > 0 {
+		i, _ := slices.BinarySearch(sorted, price)
+		sorted = sorted[max(i-around, 0):min(i+around, len(sorted))]
+	}
+	return sorted
+}
+
+// deltaInRange reports whether the absolute delta of greeks is between minDelta and maxDelta, maxDelta 0 for no limit.
+func deltaInRange(greeks TickOptionComputation, minDelta, maxDelta float64) bool {
+	if greeks == (TickOptionComputation{}) || math.IsNaN(greeks.Delta) || math.Abs(greeks.Delta) > 1 {
+		return false
+	}
+	delta := math.Abs(greeks.Delta)
+	return delta >= minDelta && (maxDelta == 0 || delta <= maxDelta)
+}
+
+// Tickers returns the tickers of the options, e.g. to build a volatility surface.
+func (m *OptionChainMatrix) Tickers() []*Ticker {
+	var tickers []*Ticker
+	for _, q := range m.Quotes() {
+		tickers = append(tickers, q.Ticker)
+	}
+	return tickers
+}
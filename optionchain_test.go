@@ -0,0 +1,129 @@
+package ibsync
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFilterExpirations(t *testing.T) {
+	now := time.Date(2024, 3, 1, 15, 30, 0, 0, time.UTC)
+	expirations := []string{"20240419", "20240301", "20240308", "20240315", "bad", "20240621"}
+
+	tests := []struct {
+		name                     string
+		minDTE, maxDTE, maxCount int
+		want                     []string
+	}{
+		{"all", 0, 0, 0, []string{"20240301", "20240308", "20240315", "20240419", "20240621"}},
+		{"no 0DTE", 1, 0, 0, []string{"20240308", "20240315", "20240419", "20240621"}},
+		{"within 2 weeks", 0, 14, 0, []string{"20240301", "20240308", "20240315"}},
+		{"nearest 2", 1, 60, 2, []string{"20240308", "20240315"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterExpirations(expirations, now, tt.minDTE, tt.maxDTE, tt.maxCount)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("filterExpirations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterStrikes(t *testing.T) {
+	strikes := []float64{110, 90, 95, 100, 105, 80, 120}
+
+	tests := []struct {
+		name      string
+		price     float64
+		moneyness float64
+		around    int
+		want      []float64
+	}{
+		{"all", 101, 0, 0, []float64{80, 90, 95, 100, 105, 110, 120}},
+		{"moneyness", 101, 0.1, 0, []float64{95, 100, 105, 110}},
+		{"around", 101, 0, 2, []float64{95, 100, 105, 110}},
+		{"around on a strike", 100, 0, 1, []float64{95, 100}},
+		{"both", 101, 0.1, 1, []float64{100, 105}},
+		{"no price", math.NaN(), 0.1, 1, []float64{80, 90, 95, 100, 105, 110, 120}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterStrikes(strikes, tt.price, tt.moneyness, tt.around)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("filterStrikes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectOptionChain(t *testing.T) {
+	chains := []OptionChain{
+		{Exchange: "CBOE", TradingClass: "SPX"},
+		{Exchange: "SMART", TradingClass: "SPXW"},
+		{Exchange: "SMART", TradingClass: "SPX"},
+	}
+	if c, err := selectOptionChain(chains, "SMART", "", "SPX"); err != nil || c.TradingClass != "SPX" {
+		t.Errorf("selectOptionChain() = %v, %v, want SPX", c.TradingClass, err)
+	}
+	if c, err := selectOptionChain(chains, "SMART", "SPXW", "SPX"); err != nil || c.TradingClass != "SPXW" {
+		t.Errorf("selectOptionChain() = %v, %v, want SPXW", c.TradingClass, err)
+	}
+	if _, err := selectOptionChain(chains, "BOX", "", "SPX"); err == nil {
+		t.Errorf("selectOptionChain() on an unknown exchange = nil error")
+	}
+}
+
+func TestOptionChainMatrix(t *testing.T) {
+	m := &OptionChainMatrix{quotes: make(map[optionKey]*OptionQuote)}
+	for _, key := range []optionKey{{"20240315", 100, "P"}, {"20240308", 105, "C"}, {"20240308", 100, "P"}, {"20240308", 100, "C"}} {
+		contract := NewOption("SPY", key.expiry, key.strike, key.right, "SMART", "100", "USD")
+		m.quotes[key] = &OptionQuote{Contract: contract, Ticker: NewTicker(contract)}
+	}
+	var got []optionKey
+	for _, q := range m.Quotes() {
+		got = append(got, optionKey{q.Contract.LastTradeDateOrContractMonth, q.Contract.Strike, q.Contract.Right})
+	}
+	want := []optionKey{{"20240308", 100, "C"}, {"20240308", 100, "P"}, {"20240308", 105, "C"}, {"20240315", 100, "P"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("Quotes() = %v, want %v", got, want)
+	}
+	if _, ok := m.Quote("20240308", 105, "P"); ok {
+		t.Errorf("Quote() of a missing option ok = true")
+	}
+	m.Cancel()
+}
+
+func TestDeltaInRange(t *testing.T) {
+	if deltaInRange(TickOptionComputation{}, 0.1, 0.5) {
+		t.Errorf("deltaInRange() without greeks = true")
+	}
+	if !deltaInRange(TickOptionComputation{Delta: -0.3}, 0.1, 0.5) {
+		t.Errorf("deltaInRange(-0.3) = false")
+	}
+	if deltaInRange(TickOptionComputation{Delta: 0.7}, 0.1, 0.5) {
+		t.Errorf("deltaInRange(0.7) = true")
+	}
+	if deltaInRange(TickOptionComputation{Delta: -2}, 0, 0) {
+		t.Errorf("deltaInRange(-2) = true")
+	}
+}
+
+func TestMatchOptions(t *testing.T) {
+	var cds []ContractDetails
+	for _, strike := range []float64{95, 97.5, 100, 105} {
+		var cd ContractDetails
+		cd.Contract = *NewOption("SPY", "20240315", strike, "C", "SMART", "100", "USD")
+		cd.Contract.ConID = int64(strike * 10)
+		cds = append(cds, cd)
+	}
+	got := matchOptions(cds, []float64{95, 100, 110})
+	if len(got) != 2 || got[0].Strike != 95 || got[1].Strike != 100 || got[1].ConID != 1000 {
+		t.Fatalf("matchOptions() = %v, want the 95 and 100 strikes", got)
+	}
+	got[0].Strike = 0
+	if cds[0].Contract.Strike != 95 {
+		t.Errorf("matchOptions() contracts share the details")
+	}
+}
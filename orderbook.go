@@ -0,0 +1,265 @@
+package ibsync
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Market depth sides and operations, as sent by IB.
+const (
+	DepthAsk int64 = 0
+	DepthBid int64 = 1
+
+	DepthInsert int64 = 0
+	DepthUpdate int64 = 1
+	DepthDelete int64 = 2
+)
+
+var (
+	errUnknownDepthSide      = errors.New("unknown DOM side")
+	errUnknownDepthOperation = errors.New("unknown DOM operation")
+)
+
+// PriceLevel is the order book aggregated at a price.
+type PriceLevel struct {
+	Price        float64
+	Size         float64  // Total size of the rows at this price
+	CumSize      float64  // Cumulative size from the best price down to this one
+	MarketMakers []string // Exchanges or market makers quoting this price, in row order
+}
+
+// OrderBookChange is a change of an order book, as received from IB.
+type OrderBookChange struct {
+	Seq int64 // Sequence number of the change, incremented by one for each change
+	MktDepthData
+}
+
+// OrderBook is the market-by-order book of a market depth ticker.
+//
+// Rows are kept by position, as sent by IB: inserting or deleting a row shifts the following rows.
+// With SMART depth, the market maker of a row is the exchange quoting it.
+// Use Snapshot to get a consistent view of the book and its metrics.
+type OrderBook struct {
+	mu          sync.Mutex
+	bids        []DOMLevel
+	asks        []DOMLevel
+	time        time.Time
+	seq         int64
+	subscribers []chan OrderBookChange
+}
+
+// NewOrderBook creates an empty order book.
+func NewOrderBook() *OrderBook {
+	return &OrderBook{}
+}
+
+// apply applies a market depth update to the book and notifies the subscribers.
+func (b *OrderBook) apply(tick MktDepthData) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var rows *[]DOMLevel
+	switch tick.Side {
+	case DepthAsk:
+		rows = &b.asks
+	case DepthBid:
+		rows = &b.bids
+	default:
+		return errUnknownDepthSide
+	}
+	level := DOMLevel{Price: tick.Price, Size: tick.Size, MarketMaker: tick.MarketMaker}
+	position := int(max(tick.Position, 0))
+	switch tick.Operation {
+	case DepthInsert:
+		*rows = slices.Insert(*rows, min(position, len(*rows)), level)
+	case DepthUpdate:
+		if position < len(*rows) {
+			(*rows)[position] = level
+		} else {
+			*rows = append(*rows, level)
+		}
+	case DepthDelete:
+		if position < len(*rows) {
+			*rows = slices.Delete(*rows, position, position+1)
+		}
+	default:
+		return errUnknownDepthOperation
+	}
+
+	b.time = tick.Time
+	b.seq++
+	change := OrderBookChange{Seq: b.seq, MktDepthData: tick}
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- change:
+		default:
+		}
+	}
+	return nil
+}
+
+// Changes returns a channel receiving the changes of the book and a function to stop them.
+//
+// Changes are dropped when the channel buffer of size is full: a gap in Seq tells it, use Snapshot to resync.
+func (b *OrderBook) Changes(size int) (<-chan OrderBookChange, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan OrderBookChange, max(size, 1))
+	b.subscribers = append(b.subscribers, ch)
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if i := slices.Index(b.subscribers, ch); i >= 0 {
+				b.subscribers = slices.Delete(b.subscribers, i, i+1)
+			}
+			close(ch)
+		})
+	}
+}
+
+// Snapshot returns a consistent copy of the book.
+func (b *OrderBook) Snapshot() OrderBookSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return OrderBookSnapshot{
+		Time: b.time,
+		Seq:  b.seq,
+		Bids: slices.Clone(b.bids),
+		Asks: slices.Clone(b.asks),
+	}
+}
+
+// OrderBookSnapshot is a copy of an order book at a sequence number.
+type OrderBookSnapshot struct {
+	Time time.Time  // Time of the last change
+	Seq  int64      // Sequence number of the last change
+	Bids []DOMLevel // Bid rows by position, best first
+	Asks []DOMLevel // Ask rows by position, best first
+}
+
+// ByMarketMaker returns the rows of a side grouped by exchange or market maker, best first.
+func (s OrderBookSnapshot) ByMarketMaker(side int64) map[string][]DOMLevel {
+	m := make(map[string][]DOMLevel)
+	for _, row := range s.rows(side) {
+		m[row.MarketMaker] = append(m[row.MarketMaker], row)
+	}
+	return m
+}
+
+func (s OrderBookSnapshot) rows(side int64) []DOMLevel {
+	if side == DepthBid {
+		return s.Bids
+	}
+	return s.Asks
+}
+
+// Aggregated returns the rows of a side aggregated by price, best price first, with their cumulative size.
+func (s OrderBookSnapshot) Aggregated(side int64) []PriceLevel {
+	var levels []PriceLevel
+	for _, row := range s.rows(side) {
+		i := slices.IndexFunc(levels, func(l PriceLevel) bool { return l.Price == row.Price })
+		if i < 0 {
+			levels = append(levels, PriceLevel{Price: row.Price})
+			i = len(levels) - 1
+		}
+		levels[i].Size += decimalToFloat(row.Size)
+		if row.MarketMaker != "" && !slices.Contains(levels[i].MarketMakers, row.MarketMaker) {
+			levels[i].MarketMakers = append(levels[i].MarketMakers, row.MarketMaker)
+		}
+	}
+	slices.SortStableFunc(levels, func(a, b PriceLevel) int {
+		if side == DepthBid {
+			a, b = b, a
+		}
+		switch {
+		case a.Price < b.Price:
+			return -1
+		case a.Price > b.Price:
+			return 1
+		}
+		return 0
+	})
+	var cum float64
+	for i := range levels {
+		cum += levels[i].Size
+		levels[i].CumSize = cum
+	}
+	return levels
+}
+
+// BestBid returns the best aggregated bid level. ok is false if there are no bids.
+func (s OrderBookSnapshot) BestBid() (level PriceLevel, ok bool) {
+	return bestLevel(s.Aggregated(DepthBid))
+}
+
+// BestAsk returns the best aggregated ask level. ok is false if there are no asks.
+func (s OrderBookSnapshot) BestAsk() (level PriceLevel, ok bool) {
+	return bestLevel(s.Aggregated(DepthAsk))
+}
+
+func bestLevel(levels []PriceLevel) (PriceLevel, bool) {
+	if len(levels) == 0 {
+		return PriceLevel{}, false
+	}
+	return levels[0], true
+}
+
+// Spread returns the best ask minus the best bid. ok is false if a side is empty.
+func (s OrderBookSnapshot) Spread() (spread float64, ok bool) {
+	bid, okBid := s.BestBid()
+	ask, okAsk := s.BestAsk()
+	if !okBid || !okAsk {
+		return 0, false
+	}
+	return ask.Price - bid.Price, true
+}
+
+// Midpoint returns the average of the best bid and ask prices. ok is false if a side is empty.
+func (s OrderBookSnapshot) Midpoint() (mid float64, ok bool) {
+	bid, okBid := s.BestBid()
+	ask, okAsk := s.BestAsk()
+	if !okBid || !okAsk {
+		return 0, false
+	}
+	return (bid.Price + ask.Price) / 2, true
+}
+
+// Microprice returns the best bid and ask prices weighted by the opposite sizes,
+// (bid * askSize + ask * bidSize) / (bidSize + askSize). ok is false if a side is empty.
+func (s OrderBookSnapshot) Microprice() (price float64, ok bool) {
+	bid, okBid := s.BestBid()
+	ask, okAsk := s.BestAsk()
+	if !okBid || !okAsk {
+		return 0, false
+	}
+	if bid.Size+ask.Size == 0 {
+		return (bid.Price + ask.Price) / 2, true
+	}
+	return (bid.Price*ask.Size + ask.Price*bid.Size) / (bid.Size + ask.Size), true
+}
+
+// Depth returns the cumulative size of the best levels price levels of a side, all levels if levels <= 0.
+func (s OrderBookSnapshot) Depth(side int64, levels int) float64 {
+	aggregated := s.Aggregated(side)
+	if len(aggregated) == 0 {
+		return 0
+	}
+	if levels <= 0 || levels > len(aggregated) {
+		levels = len(aggregated)
+	}
+	return aggregated[levels-1].CumSize
+}
+
+// Imbalance returns (bidDepth - askDepth) / (bidDepth + askDepth) over the best levels price levels, all levels if levels <= 0.
+// It ranges from -1 (only asks) to 1 (only bids) and is 0 for an empty book.
+func (s OrderBookSnapshot) Imbalance(levels int) float64 {
+	bid, ask := s.Depth(DepthBid, levels), s.Depth(DepthAsk, levels)
+	if bid+ask == 0 {
+		return 0
+	}
+	return (bid - ask) / (bid + ask)
+}
@@ -0,0 +1,128 @@
+package ibsync
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func depth(position, operation, side int64, marketMaker string, price float64, size string) MktDepthData {
+	return MktDepthData{Time: testFillTime, Position: position, MarketMaker: marketMaker, Operation: operation, Side: side, Price: price, Size: StringToDecimal(size), IsSmartDepth: true}
+}
+
+func TestOrderBookRows(t *testing.T) {
+	b := NewOrderBook()
+	updates := []MktDepthData{
+		depth(0, DepthInsert, DepthBid, "NSDQ", 100, "10"),
+		depth(1, DepthInsert, DepthBid, "ARCA", 99, "20"),
+		depth(0, DepthInsert, DepthBid, "IEX", 100.5, "5"), // shifts the rows down
+		depth(2, DepthUpdate, DepthBid, "ARCA", 99, "25"),
+		depth(0, DepthDelete, DepthBid, "IEX", 100.5, "5"), // shifts the rows up
+		depth(0, DepthInsert, DepthAsk, "NSDQ", 101, "7"),
+	}
+	for _, u := range updates {
+		if err := b.apply(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.apply(depth(0, 5, DepthBid, "", 0, "0")); err == nil {
+		t.Errorf("apply() of an unknown operation = nil")
+	}
+
+	s := b.Snapshot()
+	if s.Seq != int64(len(updates)) {
+		t.Errorf("Seq = %v, want %v", s.Seq, len(updates))
+	}
+	wantBids := []string{"NSDQ", "ARCA"}
+	var gotBids []string
+	for _, row := range s.Bids {
+		gotBids = append(gotBids, row.MarketMaker)
+	}
+	if !slices.Equal(gotBids, wantBids) {
+		t.Errorf("bids = %v, want %v", gotBids, wantBids)
+	}
+	if s.Bids[1].Size.Float() != 25 {
+		t.Errorf("updated bid size = %v, want 25", s.Bids[1].Size)
+	}
+	if len(s.ByMarketMaker(DepthBid)["ARCA"]) != 1 {
+		t.Errorf("ByMarketMaker() = %v", s.ByMarketMaker(DepthBid))
+	}
+}
+
+func TestOrderBookMetrics(t *testing.T) {
+	s := OrderBookSnapshot{
+		Bids: []DOMLevel{
+			{Price: 100, Size: StringToDecimal("10"), MarketMaker: "NSDQ"},
+			{Price: 100, Size: StringToDecimal("30"), MarketMaker: "ARCA"},
+			{Price: 99, Size: StringToDecimal("50"), MarketMaker: "NSDQ"},
+		},
+		Asks: []DOMLevel{
+			{Price: 101, Size: StringToDecimal("20"), MarketMaker: "ARCA"},
+			{Price: 102, Size: StringToDecimal("40"), MarketMaker: "IEX"},
+		},
+	}
+
+	bids := s.Aggregated(DepthBid)
+	if len(bids) != 2 || bids[0].Price != 100 || bids[0].Size != 40 || bids[1].CumSize != 90 {
+		t.Fatalf("Aggregated(bids) = %+v", bids)
+	}
+	if !slices.Equal(bids[0].MarketMakers, []string{"NSDQ", "ARCA"}) {
+		t.Errorf("MarketMakers = %v", bids[0].MarketMakers)
+	}
+
+	if spread, _ := s.Spread(); spread != 1 {
+		t.Errorf("Spread() = %v, want 1", spread)
+	}
+	if mid, _ := s.Midpoint(); mid != 100.5 {
+		t.Errorf("Midpoint() = %v, want 100.5", mid)
+	}
+	// (100 * 20 + 101 * 40) / 60
+	if micro, _ := s.Microprice(); math.Abs(micro-(100*20+101*40)/60.0) > 1e-9 {
+		t.Errorf("Microprice() = %v", micro)
+	}
+	if d := s.Depth(DepthAsk, 0); d != 60 {
+		t.Errorf("Depth(asks) = %v, want 60", d)
+	}
+	// (40 - 20) / 60 on the best level
+	if imb := s.Imbalance(1); math.Abs(imb-1.0/3) > 1e-9 {
+		t.Errorf("Imbalance(1) = %v, want 1/3", imb)
+	}
+	if _, ok := (OrderBookSnapshot{}).Microprice(); ok {
+		t.Errorf("Microprice() of an empty book ok = true")
+	}
+}
+
+func TestOrderBookChanges(t *testing.T) {
+	b := NewOrderBook()
+	changes, stop := b.Changes(1)
+	b.apply(depth(0, DepthInsert, DepthBid, "", 100, "1"))
+	b.apply(depth(0, DepthUpdate, DepthBid, "", 100, "2")) // dropped, the buffer is full
+
+	change := <-changes
+	if change.Seq != 1 || change.Operation != DepthInsert {
+		t.Errorf("change = %+v", change)
+	}
+	if s := b.Snapshot(); s.Seq != 2 {
+		t.Errorf("Seq = %v, want 2", s.Seq)
+	}
+	stop()
+	stop()
+	if _, ok := <-changes; ok {
+		t.Errorf("changes not closed")
+	}
+}
+
+func TestTickerMktDepth(t *testing.T) {
+	ticker := NewTicker(NewStock("AAPL", "SMART", "USD"))
+	ticker.setMktDepth(depth(0, DepthInsert, DepthAsk, "", 101, "1"))
+	ticker.setMktDepth(depth(0, DepthInsert, DepthAsk, "", 100, "1"))
+	ticker.setMktDepth(depth(1, DepthDelete, DepthAsk, "", 101, "1"))
+
+	asks := ticker.DomAsks()
+	if len(asks) != 1 || asks[0].Price != 100 {
+		t.Errorf("DomAsks() = %v", asks)
+	}
+	if s := ticker.OrderBook().Snapshot(); len(s.Asks) != 1 || len(ticker.DomTicks()) != 3 {
+		t.Errorf("order book = %+v", s)
+	}
+}
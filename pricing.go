@@ -0,0 +1,312 @@
+package ibsync
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// OptionModel is the pricing model of an option.
+type OptionModel int
+
+const (
+	BlackScholesMerton OptionModel = iota // Equity and index options, the underlying is the spot price
+	Black76                               // Futures options, the underlying is the futures price
+)
+
+func (m OptionModel) String() string {
+	switch m {
+	case BlackScholesMerton:
+		return "BlackScholesMerton"
+	case Black76:
+		return "Black76"
+	}
+	return "OptionModel(" + strconv.Itoa(int(m)) + ")"
+}
+
+// OptionParams are the inputs of the local option pricing, with PriceOption and ImpliedVolatility.
+type OptionParams struct {
+	Model      OptionModel
+	Right      string  // "C" or "P"
+	Underlying float64 // Spot price, or futures price with Black76
+	Strike     float64
+	Expiry     float64 // Time to expiration in years, see YearsToExpiry
+	Rate       float64 // Continuously compounded risk-free rate, e.g. 0.05 for 5%
+	Dividend   float64 // Continuous dividend yield, ignored with Black76
+	PvDividend float64 // Present value of the cash dividends paid before expiration, deducted from the spot price. Ignored with Black76
+	American   bool    // Early exercise, priced with the Barone-Adesi and Whaley approximation
+}
+
+func (p OptionParams) isCall() bool {
+	return p.Right == "C" || p.Right == "CALL"
+}
+
+// spot returns the underlying price net of the cash dividends.
+func (p OptionParams) spot() float64 {
+	if p.Model == Black76 {
+		return p.Underlying
+	}
+	return p.Underlying - p.PvDividend
+}
+
+// carry returns the cost of carry of the underlying.
+func (p OptionParams) carry() float64 {
+	if p.Model == Black76 {
+		return 0
+	}
+	return p.Rate - p.Dividend
+}
+
+// YearsToExpiry returns the time in years from now to the close of the last trading day, "YYYYMMDD", at 16:00 US/Eastern.
+// It returns 0 if the option is expired or the date cannot be parsed.
+func YearsToExpiry(lastTradeDate string, now time.Time) float64 {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		loc = time.UTC
+	}
+	if len(lastTradeDate) < 8 {
+		return 0
+	}
+	day, err := time.ParseInLocation("20060102", lastTradeDate[:8], loc)
+	if err != nil {
+		return 0
+	}
+	expiry := day.Add(16 * time.Hour)
+	if !expiry.After(now) {
+		return 0
+	}
+	return expiry.Sub(now).Hours() / (365 * 24)
+}
+
+// PriceOption prices an option at a volatility, e.g. 0.2 for 20%.
+//
+// The result has the shape of the option computations sent by IB:
+// Vega is the price change for one volatility point, Theta the price change for one calendar day.
+// American options are priced with the Barone-Adesi and Whaley approximation and their greeks by finite differences.
+func PriceOption(p OptionParams, volatility float64) TickOptionComputation {
+	tc := TickOptionComputation{
+		TickType:   MODEL_OPTION,
+		ImpliedVol: volatility,
+		UndPrice:   p.Underlying,
+		PvDividend: p.PvDividend,
+	}
+	if p.American {
+		price := func(s, v, t float64) float64 {
+			q := p
+			q.Underlying, q.Expiry = s+(p.Underlying-p.spot()), t
+			return americanPrice(q, v)
+		}
+		s, t := p.spot(), p.Expiry
+		h := 0.001 * s
+		dv := 0.005
+		tc.OptPrice = price(s, volatility, t)
+		up, down := price(s+h, volatility, t), price(s-h, volatility, t)
+		tc.Delta = (up - down) / (2 * h)
+		tc.Gamma = (up - 2*tc.OptPrice + down) / (h * h)
+		tc.Vega = price(s, volatility+dv, t) - price(s, max(volatility-dv, 0), t)
+		tc.Theta = price(s, volatility, max(t-1.0/365, 0)) - tc.OptPrice
+		return tc
+	}
+	g := europeanGreeks(p, volatility)
+	tc.OptPrice, tc.Delta, tc.Gamma, tc.Vega, tc.Theta = g.price, g.delta, g.gamma, g.vega/100, g.theta/365
+	return tc
+}
+
+// optionGreeks are the price and greeks of an option, vega per unit of volatility and theta per year.
+type optionGreeks struct {
+	price, delta, gamma, vega, theta float64
+}
+
+// normCDF is the standard normal cumulative distribution function.
+func normCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// normPDF is the standard normal probability density function.
+func normPDF(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
+
+// europeanGreeks prices a european option with the generalized Black-Scholes formula.
+func europeanGreeks(p OptionParams, v float64) optionGreeks {
+	s, k, t, r, b := p.spot(), p.Strike, p.Expiry, p.Rate, p.carry()
+	if t <= 0 || v <= 0 || s <= 0 || k <= 0 {
+		// Deterministic forward, no time value.
+		forward, discount := s*math.Exp((b-r)*t), math.Exp(-r*t)
+		if p.isCall() {
+			if forward > k*discount {
+				return optionGreeks{price: forward - k*discount, delta: math.Exp((b - r) * t)}
+			}
+			return optionGreeks{}
+		}
+		if k*discount > forward {
+			return optionGreeks{price: k*discount - forward, delta: -math.Exp((b - r) * t)}
+		}
+		return optionGreeks{}
+	}
+	sqrtT := math.Sqrt(t)
+	d1 := (math.Log(s/k) + (b+v*v/2)*t) / (v * sqrtT)
+	d2 := d1 - v*sqrtT
+	carry, discount := math.Exp((b-r)*t), math.Exp(-r*t)
+	g := optionGreeks{
+		gamma: carry * normPDF(d1) / (s * v * sqrtT),
+		vega:  s * carry * normPDF(d1) * sqrtT,
+	}
+	decay := -s * carry * normPDF(d1) * v / (2 * sqrtT)
+	if p.isCall() {
+		g.price = s*carry*normCDF(d1) - k*discount*normCDF(d2)
+		g.delta = carry * normCDF(d1)
+		g.theta = decay - (b-r)*s*carry*normCDF(d1) - r*k*discount*normCDF(d2)
+	} else {
+		g.price = k*discount*normCDF(-d2) - s*carry*normCDF(-d1)
+		g.delta = carry * (normCDF(d1) - 1)
+		g.theta = decay + (b-r)*s*carry*normCDF(-d1) + r*k*discount*normCDF(-d2)
+	}
+	return g
+}
+
+// americanPrice prices an american option with the Barone-Adesi and Whaley quadratic approximation.
+func americanPrice(p OptionParams, v float64) float64 {
+	s, k, t, r, b := p.spot(), p.Strike, p.Expiry, p.Rate, p.carry()
+	european := europeanGreeks(p, v).price
+	intrinsic := max(s-k, 0)
+	if !p.isCall() {
+		intrinsic = max(k-s, 0)
+	}
+	switch {
+	case t <= 0 || v <= 0:
+		return max(european, intrinsic)
+	case p.isCall() && b >= r:
+		// Never optimal to exercise early.
+		return european
+	case r <= 0:
+		return max(european, intrinsic)
+	}
+
+	sqrtT := math.Sqrt(t)
+	n := 2 * b / (v * v)
+	m := 2 * r / (v * v)
+	kk := 1 - math.Exp(-r*t)
+	carry := math.Exp((b - r) * t)
+	at := func(spot float64) OptionParams {
+		q := p
+		q.Model, q.Underlying, q.PvDividend, q.Dividend, q.American = BlackScholesMerton, spot, 0, r-b, false
+		return q
+	}
+	d1 := func(spot float64) float64 {
+		return (math.Log(spot/k) + (b+v*v/2)*t) / (v * sqrtT)
+	}
+
+	if p.isCall() {
+		q2 := (-(n - 1) + math.Sqrt((n-1)*(n-1)+4*m/kk)) / 2
+		q2inf := (-(n - 1) + math.Sqrt((n-1)*(n-1)+4*m)) / 2
+		sInf := k / (1 - 1/q2inf)
+		h2 := -(b*t + 2*v*sqrtT) * k / (sInf - k)
+		si := k + (sInf-k)*(1-math.Exp(h2))
+		for range 100 {
+			nd1 := normCDF(d1(si))
+			rhs := europeanGreeks(at(si), v).price + (1-carry*nd1)*si/q2
+			if math.Abs(si-k-rhs)/k < 1e-8 {
+				break
+			}
+			bi := carry*nd1*(1-1/q2) + (1-carry*normPDF(d1(si))/(v*sqrtT))/q2
+			si = (k + rhs - bi*si) / (1 - bi)
+		}
+		if s >= si {
+			return s - k
+		}
+		a2 := si / q2 * (1 - carry*normCDF(d1(si)))
+		return european + a2*math.Pow(s/si, q2)
+	}
+
+	q1 := (-(n - 1) - math.Sqrt((n-1)*(n-1)+4*m/kk)) / 2
+	q1inf := (-(n - 1) - math.Sqrt((n-1)*(n-1)+4*m)) / 2
+	sInf := k / (1 - 1/q1inf)
+	h1 := (b*t - 2*v*sqrtT) * k / (k - sInf)
+	si := sInf + (k-sInf)*math.Exp(h1)
+	for range 100 {
+		nd1 := normCDF(-d1(si))
+		rhs := europeanGreeks(at(si), v).price - (1-carry*nd1)*si/q1
+		if math.Abs(k-si-rhs)/k < 1e-8 {
+			break
+		}
+		bi := -carry*nd1*(1-1/q1) - (1+carry*normPDF(-d1(si))/(v*sqrtT))/q1
+		si = (k - rhs + bi*si) / (1 + bi)
+	}
+	if s <= si {
+		return k - s
+	}
+	a1 := -si / q1 * (1 - carry*normCDF(-d1(si)))
+	return european + a1*math.Pow(s/si, q1)
+}
+
+// optionPriceBounds returns the no-arbitrage bounds of the price of an option.
+func optionPriceBounds(p OptionParams) (lower, upper float64) {
+	s, k, t, r, b := p.spot(), p.Strike, p.Expiry, p.Rate, p.carry()
+	forward, discount := s*math.Exp((b-r)*t), math.Exp(-r*t)
+	if p.isCall() {
+		lower, upper = max(forward-k*discount, 0), forward
+		if p.American {
+			lower, upper = max(lower, s-k), max(upper, s)
+		}
+		return lower, upper
+	}
+	lower, upper = max(k*discount-forward, 0), k*discount
+	if p.American {
+		lower, upper = max(lower, k-s), k
+	}
+	return lower, upper
+}
+
+// Bounds of the implied volatility solver.
+const (
+	minImpliedVol = 1e-6
+	maxImpliedVol = 10.0
+)
+
+// ImpliedVolatility returns the volatility at which PriceOption prices the option at price.
+//
+// It returns ErrOptionPriceOutOfBounds if the price is not above the intrinsic value or not below the maximum price
+// of the option, and ErrImpliedVolNotFound if the solver does not converge.
+func ImpliedVolatility(p OptionParams, price float64) (float64, error) {
+	if p.Expiry <= 0 || math.IsNaN(price) {
+		return 0, ErrOptionPriceOutOfBounds
+	}
+	lower, upper := optionPriceBounds(p)
+	tolerance := 1e-10 * max(price, 1)
+	if price <= lower+tolerance || price >= upper {
+		return 0, ErrOptionPriceOutOfBounds
+	}
+
+	f := func(v float64) float64 { return PriceOption(p, v).OptPrice - price }
+	lo, hi := minImpliedVol, maxImpliedVol
+	if f(lo) > 0 || f(hi) < 0 {
+		return 0, ErrImpliedVolNotFound
+	}
+	// Brenner and Subrahmanyam at the money guess, then Newton steps safeguarded by bisection.
+	v := math.Sqrt(2*math.Pi/p.Expiry) * price / p.Underlying
+	if !(v > lo && v < hi) {
+		v = 0.3
+	}
+	for range 100 {
+		tc := PriceOption(p, v)
+		diff := tc.OptPrice - price
+		if math.Abs(diff) < tolerance {
+			return v, nil
+		}
+		if diff > 0 {
+			hi = v
+		} else {
+			lo = v
+		}
+		if hi-lo < 1e-12 {
+			return v, nil
+		}
+		next := v - diff/(tc.Vega*100)
+		if tc.Vega <= 0 || !(next > lo && next < hi) {
+			next = (lo + hi) / 2
+		}
+		v = next
+	}
+	return 0, ErrImpliedVolNotFound
+}
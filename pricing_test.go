@@ -0,0 +1,135 @@
+package ibsync
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPriceOptionEuropean(t *testing.T) {
+	p := OptionParams{Right: "C", Underlying: 100, Strike: 100, Expiry: 1, Rate: 0.05}
+	call := PriceOption(p, 0.2)
+	if math.Abs(call.OptPrice-10.4506) > 1e-4 {
+		t.Errorf("call price = %v, want 10.4506", call.OptPrice)
+	}
+	if math.Abs(call.Delta-0.6368) > 1e-4 || math.Abs(call.Gamma-0.018762) > 1e-6 {
+		t.Errorf("call delta, gamma = %v, %v", call.Delta, call.Gamma)
+	}
+	// Vega per volatility point, theta per day.
+	if math.Abs(call.Vega-0.37524) > 1e-5 || math.Abs(call.Theta-(-6.4140/365)) > 1e-5 {
+		t.Errorf("call vega, theta = %v, %v", call.Vega, call.Theta)
+	}
+
+	p.Right = "P"
+	put := PriceOption(p, 0.2)
+	// Put-call parity: C - P = S - K * exp(-rT)
+	if parity := call.OptPrice - put.OptPrice - (100 - 100*math.Exp(-0.05)); math.Abs(parity) > 1e-9 {
+		t.Errorf("put-call parity off by %v", parity)
+	}
+	if math.Abs(call.Delta-put.Delta-1) > 1e-9 {
+		t.Errorf("call delta - put delta = %v, want 1", call.Delta-put.Delta)
+	}
+
+	// Cash dividends lower the call price like a lower spot.
+	p.Right, p.PvDividend = "C", 2
+	lower := PriceOption(OptionParams{Right: "C", Underlying: 98, Strike: 100, Expiry: 1, Rate: 0.05}, 0.2)
+	if got := PriceOption(p, 0.2); math.Abs(got.OptPrice-lower.OptPrice) > 1e-12 || got.UndPrice != 100 {
+		t.Errorf("price with dividends = %v, want %v", got.OptPrice, lower.OptPrice)
+	}
+}
+
+func TestPriceOptionBlack76(t *testing.T) {
+	// Haug, The Complete Guide to Option Pricing Formulas: F = 19, K = 19, T = 0.75, r = 0.1, v = 0.28.
+	p := OptionParams{Model: Black76, Right: "C", Underlying: 19, Strike: 19, Expiry: 0.75, Rate: 0.1, Dividend: 0.5}
+	if c := PriceOption(p, 0.28); math.Abs(c.OptPrice-1.7011) > 1e-4 {
+		t.Errorf("Black76 call = %v, want 1.7011", c.OptPrice)
+	}
+}
+
+func TestPriceOptionAmerican(t *testing.T) {
+	// Barone-Adesi and Whaley, as implemented by Haug: K = 100, T = 0.1, r = 0.1, b = 0, v = 0.15.
+	for _, tt := range []struct {
+		spot, want float64
+	}{
+		{90, 0.0206}, {100, 1.8769}, {110, 10.0061},
+	} {
+		p := OptionParams{Right: "C", Underlying: tt.spot, Strike: 100, Expiry: 0.1, Rate: 0.1, Dividend: 0.1, American: true}
+		if got := PriceOption(p, 0.15).OptPrice; math.Abs(got-tt.want) > 1e-3 {
+			t.Errorf("american call at %v = %v, want %v", tt.spot, got, tt.want)
+		}
+	}
+
+	p := OptionParams{Right: "P", Underlying: 100, Strike: 110, Expiry: 0.5, Rate: 0.08, American: true}
+	american := PriceOption(p, 0.25)
+	p.American = false
+	european := PriceOption(p, 0.25)
+	if american.OptPrice <= european.OptPrice {
+		t.Errorf("american put %v <= european put %v", american.OptPrice, european.OptPrice)
+	}
+	if american.Delta >= 0 || american.Delta < -1 || american.Gamma <= 0 || american.Vega <= 0 {
+		t.Errorf("american put greeks = %+v", american)
+	}
+
+	// Deep in the money, the put is exercised.
+	p = OptionParams{Right: "P", Underlying: 50, Strike: 110, Expiry: 0.5, Rate: 0.08, American: true}
+	if got := PriceOption(p, 0.25).OptPrice; got != 60 {
+		t.Errorf("deep in the money american put = %v, want 60", got)
+	}
+
+	// Without dividends, an american call is worth the european one.
+	p = OptionParams{Right: "C", Underlying: 100, Strike: 100, Expiry: 1, Rate: 0.05, American: true}
+	if got := PriceOption(p, 0.2).OptPrice; math.Abs(got-10.4506) > 1e-4 {
+		t.Errorf("american call without dividend = %v, want 10.4506", got)
+	}
+}
+
+func TestImpliedVolatility(t *testing.T) {
+	for _, p := range []OptionParams{
+		{Right: "C", Underlying: 100, Strike: 100, Expiry: 1, Rate: 0.05},
+		{Right: "P", Underlying: 100, Strike: 80, Expiry: 0.05, Rate: 0.05, Dividend: 0.02},
+		{Right: "C", Underlying: 100, Strike: 150, Expiry: 2, Rate: 0.03},
+		{Model: Black76, Right: "P", Underlying: 5000, Strike: 5100, Expiry: 0.25, Rate: 0.04},
+		{Right: "P", Underlying: 100, Strike: 110, Expiry: 0.5, Rate: 0.08, American: true},
+	} {
+		for _, vol := range []float64{0.05, 0.2, 0.8, 2} {
+			price := PriceOption(p, vol).OptPrice
+			if lower, _ := optionPriceBounds(p); price-lower < 1e-6 {
+				continue // No time value left to solve for.
+			}
+			got, err := ImpliedVolatility(p, price)
+			if err != nil {
+				t.Errorf("ImpliedVolatility(%+v, %v) error: %v", p, price, err)
+				continue
+			}
+			if math.Abs(PriceOption(p, got).OptPrice-price) > 1e-6 {
+				t.Errorf("ImpliedVolatility(%+v) = %v, want %v", p, got, vol)
+			}
+		}
+	}
+
+	p := OptionParams{Right: "C", Underlying: 100, Strike: 90, Expiry: 1, Rate: 0.05}
+	if _, err := ImpliedVolatility(p, 5); !errors.Is(err, ErrOptionPriceOutOfBounds) {
+		t.Errorf("price below intrinsic error = %v", err)
+	}
+	if _, err := ImpliedVolatility(p, 101); !errors.Is(err, ErrOptionPriceOutOfBounds) {
+		t.Errorf("price above spot error = %v", err)
+	}
+}
+
+func TestYearsToExpiry(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	now := time.Date(2024, 3, 14, 16, 0, 0, 0, ny)
+	if got := YearsToExpiry("20240315", now); math.Abs(got-1.0/365) > 1e-12 {
+		t.Errorf("YearsToExpiry() = %v, want 1/365", got)
+	}
+	if got := YearsToExpiry("20240314", now); got != 0 {
+		t.Errorf("YearsToExpiry() of an expired option = %v, want 0", got)
+	}
+	if got := YearsToExpiry("bad", now); got != 0 {
+		t.Errorf("YearsToExpiry(bad) = %v, want 0", got)
+	}
+}
@@ -0,0 +1,123 @@
+package ibsync
+
+import (
+	"slices"
+	"sync"
+)
+
+// QualifyRule narrows the candidates of an ambiguous contract. It returns the candidates it prefers, none if it has no preference.
+type QualifyRule func(candidates []ContractDetails) []ContractDetails
+
+// QualifyOptions are the options of QualifyContracts.
+type QualifyOptions struct {
+	Concurrency int           // Maximum number of concurrent requests, default 8
+	Rules       []QualifyRule // Disambiguation rules, applied in order until a single candidate remains
+}
+
+// QualifyConcurrency is an option of QualifyContracts to set the maximum number of concurrent requests.
+func QualifyConcurrency(n int) func(*QualifyOptions) {
+	return func(o *QualifyOptions) {
+		o.Concurrency = n
+	}
+}
+
+// QualifyPrefer is an option of QualifyContracts adding a disambiguation rule.
+func QualifyPrefer(rule QualifyRule) func(*QualifyOptions) {
+	return func(o *QualifyOptions) {
+		o.Rules = append(o.Rules, rule)
+	}
+}
+
+// preferField returns a rule preferring the candidates whose field is the first of values matching any.
+func preferField(field func(ContractDetails) string, values []string) QualifyRule {
+	return func(candidates []ContractDetails) []ContractDetails {
+		for _, v := range values {
+			preferred := slices.DeleteFunc(slices.Clone(candidates), func(cd ContractDetails) bool { return field(cd) != v })
+			if len(preferred) > 0 {
+				return preferred
+			}
+		}
+		return nil
+	}
+}
+
+// QualifyPreferPrimaryExchange is an option of QualifyContracts preferring the candidates listed on the exchanges, in order.
+func QualifyPreferPrimaryExchange(exchanges ...string) func(*QualifyOptions) {
+	return QualifyPrefer(preferField(func(cd ContractDetails) string { return cd.Contract.PrimaryExchange }, exchanges))
+}
+
+// QualifyPreferCurrency is an option of QualifyContracts preferring the candidates in the currencies, in order.
+func QualifyPreferCurrency(currencies ...string) func(*QualifyOptions) {
+	return QualifyPrefer(preferField(func(cd ContractDetails) string { return cd.Contract.Currency }, currencies))
+}
+
+// QualifyPreferSecType is an option of QualifyContracts preferring the candidates of the security types, in order.
+func QualifyPreferSecType(secTypes ...string) func(*QualifyOptions) {
+	return QualifyPrefer(preferField(func(cd ContractDetails) string { return cd.Contract.SecType }, secTypes))
+}
+
+// QualifyResult is the result of the qualification of a contract.
+type QualifyResult struct {
+	Contract   *Contract         // The contract, updated in place if qualified
+	Err        error             // nil if qualified, ErrUnknownContract, ErrAmbiguousContract or the request error
+	Candidates []ContractDetails // The candidates left by the rules if the contract is ambiguous
+}
+
+// disambiguate applies the rules to the candidates until a single one remains.
+// It returns the remaining candidates.
+func disambiguate(candidates []ContractDetails, rules []QualifyRule) []ContractDetails {
+	for _, rule := range rules {
+		if len(candidates) <= 1 {
+			break
+		}
+		if preferred := rule(candidates); len(preferred) > 0 {
+			candidates = preferred
+		}
+	}
+	return candidates
+}
+
+// QualifyContracts qualifies the contracts with at most Concurrency requests at once, and returns a result per contract, in order.
+// Qualified contracts are updated in place. Ambiguous contracts are resolved with the disambiguation rules, if any.
+func (ib *IB) QualifyContracts(contracts []*Contract, options ...func(*QualifyOptions)) []QualifyResult {
+	opts := QualifyOptions{Concurrency: 8}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	results := make([]QualifyResult, len(contracts))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(opts.Concurrency, 1))
+	for i, contract := range contracts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = ib.qualify(contract, opts.Rules)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (ib *IB) qualify(contract *Contract, rules []QualifyRule) QualifyResult {
+	result := QualifyResult{Contract: contract}
+	cds, err := ib.ReqContractDetails(contract)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	cds = disambiguate(cds, rules)
+	switch {
+	case len(cds) == 0:
+		result.Err = ErrUnknownContract
+	case len(cds) > 1:
+		log.Error().Err(ErrAmbiguousContract).Str("Symbol", contract.Symbol).Int("candidates", len(cds)).Msg("QualifyContract")
+		result.Err = ErrAmbiguousContract
+		result.Candidates = cds
+	default:
+		UpdateStruct(contract, cds[0].Contract)
+	}
+	return result
+}
@@ -0,0 +1,45 @@
+package ibsync
+
+import "testing"
+
+func TestDisambiguate(t *testing.T) {
+	candidate := func(conID int64, secType, primaryExchange, currency string) ContractDetails {
+		var cd ContractDetails
+		cd.Contract = Contract{ConID: conID, SecType: secType, PrimaryExchange: primaryExchange, Currency: currency}
+		return cd
+	}
+	candidates := []ContractDetails{
+		candidate(1, "STK", "IBIS", "EUR"),
+		candidate(2, "STK", "NASDAQ", "USD"),
+		candidate(3, "STK", "LSE", "GBP"),
+		candidate(4, "CFD", "NASDAQ", "USD"),
+	}
+	conIDs := func(cds []ContractDetails) []int64 {
+		var ids []int64
+		for _, cd := range cds {
+			ids = append(ids, cd.Contract.ConID)
+		}
+		return ids
+	}
+
+	var opts QualifyOptions
+	QualifyPreferCurrency("CHF", "USD")(&opts)
+	if got := disambiguate(candidates, opts.Rules); len(got) != 2 {
+		t.Errorf("currency rule = %v, want 2 and 4", conIDs(got))
+	}
+	QualifyPreferSecType("STK")(&opts)
+	if got := disambiguate(candidates, opts.Rules); len(got) != 1 || got[0].Contract.ConID != 2 {
+		t.Errorf("currency and secType rules = %v, want 2", conIDs(got))
+	}
+
+	// A rule without preference keeps the candidates.
+	opts = QualifyOptions{}
+	QualifyPreferPrimaryExchange("ARCA")(&opts)
+	QualifyPreferPrimaryExchange("LSE", "IBIS")(&opts)
+	if got := disambiguate(candidates, opts.Rules); len(got) != 1 || got[0].Contract.ConID != 3 {
+		t.Errorf("primary exchange rules = %v, want 3", conIDs(got))
+	}
+	if got := disambiguate(candidates, nil); len(got) != 4 {
+		t.Errorf("no rule = %v", conIDs(got))
+	}
+}
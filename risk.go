@@ -0,0 +1,280 @@
+package ibsync
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Exposure is the position weighted greeks of one or several positions.
+//
+// Delta, gamma, vega and theta are in underlying units: a long call of delta 0.5 and multiplier 100 has a delta of 50.
+// DollarDelta is the delta times the underlying price.
+type Exposure struct {
+	Delta       float64
+	Gamma       float64
+	Vega        float64
+	Theta       float64
+	DollarDelta float64
+}
+
+func (e *Exposure) add(o Exposure) {
+	e.Delta += o.Delta
+	e.Gamma += o.Gamma
+	e.Vega += o.Vega
+	e.Theta += o.Theta
+	e.DollarDelta += o.DollarDelta
+}
+
+// UnderlyingRisk is the exposure of the positions of an underlying.
+type UnderlyingRisk struct {
+	Symbol          string
+	UnderlyingPrice float64 // Last underlying price seen, 0 if unknown
+	Positions       int     // Number of positions included in the exposure
+	Missing         int     // Number of option positions without greeks yet, excluded from the exposure
+	Exposure
+}
+
+// PortfolioRisk is the exposure of a portfolio per underlying symbol and in total.
+type PortfolioRisk struct {
+	Time        time.Time
+	Underlyings map[string]UnderlyingRisk
+	Missing     int // Number of option positions without greeks yet
+	Total       Exposure
+}
+
+// Symbols returns the sorted underlying symbols.
+func (r PortfolioRisk) Symbols() []string {
+	return slices.Sorted(maps.Keys(r.Underlyings))
+}
+
+// isOption reports whether a contract is an option, priced with greeks.
+func isOption(contract *Contract) bool {
+	return contract.SecType == "OPT" || contract.SecType == "FOP"
+}
+
+// isDeltaOne reports whether a contract has a delta of one per unit of its underlying.
+func isDeltaOne(contract *Contract) bool {
+	switch contract.SecType {
+	case "STK", "FUT", "CFD", "CASH", "CRYPTO":
+		return true
+	}
+	return false
+}
+
+// greekValue returns a greek sent by IB, 0 if it is not computed.
+func greekValue(v float64) float64 {
+	if math.IsNaN(v) || v == UNSET_FLOAT || math.Abs(v) > 1e100 {
+		return 0
+	}
+	return v
+}
+
+// positionExposure returns the exposure of a portfolio item and the price of its underlying.
+// ok is false if the item is an option without greeks or a contract the exposure does not cover, e.g. a bond.
+func positionExposure(item PortfolioItem, greeks TickOptionComputation) (exposure Exposure, underlyingPrice float64, ok bool) {
+	units := decimalToFloat(item.Position) * contractMultiplier(item.Contract)
+	switch {
+	case isOption(item.Contract):
+		if !deltaInRange(greeks, 0, 0) {
+			return Exposure{}, 0, false
+		}
+		underlyingPrice = greekValue(greeks.UndPrice)
+		exposure = Exposure{
+			Delta: greeks.Delta * units,
+			Gamma: greekValue(greeks.Gamma) * units,
+			Vega:  greekValue(greeks.Vega) * units,
+			Theta: greekValue(greeks.Theta) * units,
+		}
+	case isDeltaOne(item.Contract):
+		underlyingPrice = item.MarketPrice
+		exposure = Exposure{Delta: units}
+	default:
+		return Exposure{}, 0, false
+	}
+	exposure.DollarDelta = exposure.Delta * underlyingPrice
+	return exposure, underlyingPrice, true
+}
+
+// portfolioRisk aggregates the exposure of the portfolio items by underlying symbol.
+// greeksOf returns the greeks of an option position.
+func portfolioRisk(items []PortfolioItem, greeksOf func(*Contract) TickOptionComputation) PortfolioRisk {
+	risk := PortfolioRisk{Time: time.Now(), Underlyings: make(map[string]UnderlyingRisk)}
+	for _, item := range items {
+		if item.Contract == nil || decimalToFloat(item.Position) == 0 {
+			continue
+		}
+		var greeks TickOptionComputation
+		if isOption(item.Contract) {
+			greeks = greeksOf(item.Contract)
+		}
+		u := risk.Underlyings[item.Contract.Symbol]
+		u.Symbol = item.Contract.Symbol
+		exposure, underlyingPrice, ok := positionExposure(item, greeks)
+		switch {
+		case ok:
+			u.Positions++
+			u.add(exposure)
+			risk.Total.add(exposure)
+			if underlyingPrice > 0 {
+				u.UnderlyingPrice = underlyingPrice
+			}
+		case isOption(item.Contract):
+			u.Missing++
+			risk.Missing++
+		default:
+			continue
+		}
+		risk.Underlyings[u.Symbol] = u
+	}
+	return risk
+}
+
+// RiskView is a live view of the greeks of a portfolio, per underlying and in total.
+//
+// It combines the positions of Portfolio with the greeks of the option positions,
+// streaming their market data as needed. The positions come from the account updates,
+// they must be subscribed with ReqAccountUpdates if the session manages several accounts.
+type RiskView struct {
+	ib       *IB
+	accounts []string
+	mu       sync.Mutex
+	tickers  map[int64]*Ticker // Tickers of the option positions by ConID
+	updates  chan PortfolioRisk
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewRiskView creates a risk view of the portfolio of the accounts, all accounts if none is given.
+// The risk is recomputed every interval, 1 second if interval <= 0, and sent on Updates when it changes.
+// Close the view to stop the market data of the option positions.
+func (ib *IB) NewRiskView(interval time.Duration, account ...string) *RiskView {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	rv := &RiskView{
+		ib:       ib,
+		accounts: account,
+		tickers:  make(map[int64]*Ticker),
+		updates:  make(chan PortfolioRisk, 1),
+		done:     make(chan struct{}),
+	}
+	go rv.run(interval)
+	return rv
+}
+
+func (rv *RiskView) run(interval time.Duration) {
+	var last PortfolioRisk
+	pollLatest(rv.updates, interval, rv.done, rv.ib.eClient.Ctx().Done(), func() (PortfolioRisk, bool) {
+		risk := rv.Risk()
+		if sameRisk(risk, last) {
+			return risk, false
+		}
+		last = risk
+		return risk, true
+	})
+}
+
+func sameRisk(a, b PortfolioRisk) bool {
+	return a.Total == b.Total && a.Missing == b.Missing && maps.Equal(a.Underlyings, b.Underlyings)
+}
+
+// Updates returns the channel of the risk, sent when it changes. An unread risk is replaced by the newer one.
+// It is closed by Close or on disconnection.
+func (rv *RiskView) Updates() <-chan PortfolioRisk {
+	return rv.updates
+}
+
+// Risk returns the current risk of the portfolio. It subscribes the market data of the new option positions
+// and cancels the one of the closed positions.
+func (rv *RiskView) Risk() PortfolioRisk {
+	items := rv.ib.Portfolio(rv.accounts...)
+	rv.sync(items)
+	return portfolioRisk(items, func(contract *Contract) TickOptionComputation {
+		rv.mu.Lock()
+		ticker, ok := rv.tickers[contract.ConID]
+		rv.mu.Unlock()
+		if !ok {
+			return TickOptionComputation{}
+		}
+		return ticker.Greeks()
+	})
+}
+
+// sync subscribes the market data of the option positions and cancels the one of the closed positions.
+// The new contracts are qualified and subscribed without the lock, which only guards the tickers.
+func (rv *RiskView) sync(items []PortfolioItem) {
+	open := make(map[int64]bool)
+	var added []*Contract
+	var closed []*Ticker
+	rv.mu.Lock()
+	select {
+	case <-rv.done:
+		rv.mu.Unlock()
+		return
+	default:
+	}
+	for _, item := range items {
+		if item.Contract == nil || !isOption(item.Contract) || decimalToFloat(item.Position) == 0 {
+			continue
+		}
+		conID := item.Contract.ConID
+		if open[conID] {
+			continue
+		}
+		open[conID] = true
+		if _, ok := rv.tickers[conID]; !ok {
+			added = append(added, item.Contract)
+		}
+	}
+	for conID, ticker := range rv.tickers {
+		if !open[conID] {
+			closed = append(closed, ticker)
+			delete(rv.tickers, conID)
+		}
+	}
+	rv.mu.Unlock()
+
+	for _, ticker := range closed {
+		rv.ib.CancelMktData(ticker.Contract())
+	}
+	for _, c := range added {
+		// Portfolio contracts have no exchange, they are qualified by ConID.
+		contract := &Contract{ConID: c.ConID}
+		if err := rv.ib.QualifyContract(contract); err != nil {
+			log.Warn().Err(err).Int64("conID", c.ConID).Str("symbol", c.Symbol).Msg("<RiskView>")
+			contract = &Contract{ConID: c.ConID, Exchange: "SMART"}
+		}
+		ticker := rv.ib.ReqMktData(contract, "")
+		rv.mu.Lock()
+		installed := false
+		select {
+		case <-rv.done:
+		default:
+			// A concurrent sync may have subscribed the contract meanwhile.
+			if _, ok := rv.tickers[c.ConID]; !ok {
+				rv.tickers[c.ConID] = ticker
+				installed = true
+			}
+		}
+		rv.mu.Unlock()
+		if !installed {
+			rv.ib.CancelMktData(contract)
+		}
+	}
+}
+
+// Close stops the view and cancels the market data of the option positions.
+func (rv *RiskView) Close() {
+	rv.once.Do(func() {
+		rv.mu.Lock()
+		defer rv.mu.Unlock()
+		close(rv.done)
+		for conID, ticker := range rv.tickers {
+			rv.ib.CancelMktData(ticker.Contract())
+			delete(rv.tickers, conID)
+		}
+	})
+}
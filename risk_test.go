@@ -0,0 +1,65 @@
+package ibsync
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestPortfolioRisk(t *testing.T) {
+	stock := NewStock("AAPL", "SMART", "USD")
+	stock.ConID = 1
+	call := NewOption("AAPL", "20240315", 200, "C", "SMART", "100", "USD")
+	call.ConID = 2
+	put := NewOption("AAPL", "20240315", 180, "P", "SMART", "100", "USD")
+	put.ConID = 3
+	future := NewFuture("ES", "202403", "CME", "50", "USD")
+	future.ConID = 4
+	noGreeks := NewOption("ES", "20240315", 5000, "C", "CME", "50", "USD")
+	noGreeks.ConID = 5
+	bond := &Contract{ConID: 6, Symbol: "T", SecType: "BOND"}
+
+	items := []PortfolioItem{
+		{Contract: stock, Position: StringToDecimal("100"), MarketPrice: 190},
+		{Contract: call, Position: StringToDecimal("2")},
+		{Contract: put, Position: StringToDecimal("-1")},
+		{Contract: future, Position: StringToDecimal("1"), MarketPrice: 5000},
+		{Contract: noGreeks, Position: StringToDecimal("1")},
+		{Contract: bond, Position: StringToDecimal("10"), MarketPrice: 99},
+	}
+	greeks := map[int64]TickOptionComputation{
+		2: {Delta: 0.4, Gamma: 0.02, Vega: 0.3, Theta: -0.1, UndPrice: 190},
+		3: {Delta: -0.3, Gamma: 0.01, Vega: 0.25, Theta: UNSET_FLOAT, UndPrice: 191},
+	}
+	risk := portfolioRisk(items, func(c *Contract) TickOptionComputation { return greeks[c.ConID] })
+
+	if !slices.Equal(risk.Symbols(), []string{"AAPL", "ES"}) {
+		t.Errorf("Symbols() = %v", risk.Symbols())
+	}
+	aapl := risk.Underlyings["AAPL"]
+	// 100 shares + 2 calls * 0.4 * 100 - 1 put * -0.3 * 100
+	if math.Abs(aapl.Delta-210) > 1e-9 {
+		t.Errorf("AAPL delta = %v, want 210", aapl.Delta)
+	}
+	if math.Abs(aapl.Gamma-3) > 1e-9 || math.Abs(aapl.Vega-35) > 1e-9 || math.Abs(aapl.Theta+20) > 1e-9 {
+		t.Errorf("AAPL greeks = %+v", aapl.Exposure)
+	}
+	// 100 * 190 + 80 * 190 + 30 * 191
+	if math.Abs(aapl.DollarDelta-(100*190+80*190+30*191)) > 1e-9 {
+		t.Errorf("AAPL dollar delta = %v", aapl.DollarDelta)
+	}
+	if aapl.Positions != 3 || aapl.Missing != 0 {
+		t.Errorf("AAPL positions = %v, missing = %v", aapl.Positions, aapl.Missing)
+	}
+
+	es := risk.Underlyings["ES"]
+	if es.Delta != 50 || es.DollarDelta != 250000 || es.Positions != 1 || es.Missing != 1 {
+		t.Errorf("ES = %+v", es)
+	}
+	if _, ok := risk.Underlyings["T"]; ok {
+		t.Errorf("bond included in the risk")
+	}
+	if risk.Missing != 1 || math.Abs(risk.Total.Delta-260) > 1e-9 {
+		t.Errorf("total = %+v, missing = %v", risk.Total, risk.Missing)
+	}
+}
@@ -0,0 +1,123 @@
+package ibsync
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// ScannerUpdate is a ranking refreshed by a scanner subscription.
+type ScannerUpdate struct {
+	Time    time.Time
+	Ranking []ScanData // The ranking, sorted by rank
+	Added   []ScanData // The entries of the ranking not in the previous one
+	Removed []ScanData // The entries of the previous ranking no longer in the ranking
+}
+
+// ScannerStream is a stream of the rankings of a scanner subscription.
+// The first update has every entry added.
+type ScannerStream struct {
+	*Subscription[ScannerUpdate]
+}
+
+// scanDataKey identifies the contract of a scanner entry.
+func scanDataKey(sd ScanData) int64 {
+	if sd.ContractDetails == nil {
+		return 0
+	}
+	return sd.ContractDetails.Contract.ConID
+}
+
+// diffRanking returns the entries of next not in prev, and those of prev not in next, by ConID.
+func diffRanking(prev, next []ScanData) (added, removed []ScanData) {
+	inPrev := make(map[int64]bool, len(prev))
+	for _, sd := range prev {
+		inPrev[scanDataKey(sd)] = true
+	}
+	inNext := make(map[int64]bool, len(next))
+	for _, sd := range next {
+		inNext[scanDataKey(sd)] = true
+		if !inPrev[scanDataKey(sd)] {
+			added = append(added, sd)
+		}
+	}
+	for _, sd := range prev {
+		if !inNext[scanDataKey(sd)] {
+			removed = append(removed, sd)
+		}
+	}
+	return added, removed
+}
+
+// SubscribeScanner subscribes a scanner and streams its rankings, refreshed by IB about every 30 seconds.
+//
+// It takes the same parameters as ReqScannerSubscription. The scanner runs until Cancel is called or an error occurs,
+// in which case Err returns the error. IB limits the number of concurrent scanner subscriptions, cancel them when done.
+func (ib *IB) SubscribeScanner(subscription *ScannerSubscription, scannerSubscriptionOptions ...ScannerSubscriptionOptions) *ScannerStream {
+	ctx := ib.eClient.Ctx()
+
+	reqID := ib.NextID()
+
+	ch, unsubscribe := ib.pubSub.Subscribe(reqID, 100)
+
+	var options, filterOptions []TagValue
+	if len(scannerSubscriptionOptions) > 0 {
+		options = scannerSubscriptionOptions[0].Options
+		filterOptions = scannerSubscriptionOptions[0].FilterOptions
+	}
+
+	ib.eClient.ReqScannerSubscription(reqID, subscription, options, filterOptions)
+
+	stream := &ScannerStream{Subscription: newSubscription[ScannerUpdate](10)}
+
+	go func() {
+		defer unsubscribe()
+		var ranking, rows []ScanData
+		for {
+			select {
+			case <-ctx.Done():
+				log.Error().Err(ctx.Err()).Int64("reqID", reqID).Msg("<SubscribeScanner>")
+				stream.finish(ctx.Err())
+				return
+			case <-stream.stop:
+				ib.eClient.CancelScannerSubscription(reqID)
+				stream.finish(nil)
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					stream.finish(nil)
+					return
+				}
+				if isErrorMsg(msg) {
+					err := msg2Error(msg)
+					log.Error().Err(err).Int64("reqID", reqID).Msg("<SubscribeScanner>")
+					ib.eClient.CancelScannerSubscription(reqID)
+					stream.finish(err)
+					return
+				}
+				if msg != "end" {
+					var sd ScanData
+					if err := Decode(&sd, msg); err != nil {
+						log.Error().Err(err).Int64("reqID", reqID).Msg("<SubscribeScanner>")
+						ib.eClient.CancelScannerSubscription(reqID)
+						stream.finish(err)
+						return
+					}
+					rows = append(rows, sd)
+					continue
+				}
+				slices.SortStableFunc(rows, func(a, b ScanData) int { return cmp.Compare(a.Rank, b.Rank) })
+				added, removed := diffRanking(ranking, rows)
+				update := ScannerUpdate{Time: time.Now(), Ranking: rows, Added: added, Removed: removed}
+				ranking, rows = rows, nil
+				if !stream.send(update) {
+					ib.eClient.CancelScannerSubscription(reqID)
+					stream.finish(nil)
+					return
+				}
+			}
+		}
+	}()
+
+	return stream
+}
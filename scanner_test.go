@@ -0,0 +1,26 @@
+package ibsync
+
+import "testing"
+
+func TestDiffRanking(t *testing.T) {
+	entry := func(rank, conID int64) ScanData {
+		cd := &ContractDetails{}
+		cd.Contract.ConID = conID
+		return ScanData{Rank: rank, ContractDetails: cd}
+	}
+	prev := []ScanData{entry(0, 1), entry(1, 2), entry(2, 3)}
+	next := []ScanData{entry(0, 3), entry(1, 1), entry(2, 4)}
+
+	added, removed := diffRanking(prev, next)
+	if len(added) != 1 || added[0].ContractDetails.Contract.ConID != 4 {
+		t.Errorf("added = %v, want ConID 4", added)
+	}
+	if len(removed) != 1 || removed[0].ContractDetails.Contract.ConID != 2 {
+		t.Errorf("removed = %v, want ConID 2", removed)
+	}
+
+	// The first ranking is all added.
+	if added, removed := diffRanking(nil, prev); len(added) != 3 || len(removed) != 0 {
+		t.Errorf("first ranking: added %v, removed %v", len(added), len(removed))
+	}
+}
@@ -0,0 +1,373 @@
+package ibsync
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ScannerInstrument is an instrument of the scanner parameters, the Instrument of a ScannerSubscription.
+type ScannerInstrument struct {
+	Type    string   // e.g. "STK" or "ETF.EQ.US"
+	Name    string   // e.g. "US Stocks"
+	SecType string   // Security type of the instrument, if different from Type
+	Filters []string // IDs of the filters available for the instrument
+}
+
+// ScannerLocation is a location of the scanner parameters, the LocationCode of a ScannerSubscription.
+type ScannerLocation struct {
+	Code          string // e.g. "STK.US.MAJOR"
+	DisplayName   string
+	Instruments   []string // Instrument types available in the location
+	RouteExchange string
+	Parent        string // Code of the parent location, empty for the roots
+}
+
+// ScanCode is a scan type of the scanner parameters, the ScanCode of a ScannerSubscription.
+type ScanCode struct {
+	Code        string // e.g. "TOP_PERC_GAIN"
+	DisplayName string
+	Instruments []string // Instrument types the scan supports
+}
+
+// ScannerFilter is a filter field of the scanner parameters, set with its Code in the filter options of a subscription.
+type ScannerFilter struct {
+	Code        string // Tag of the filter option, e.g. "priceAbove"
+	DisplayName string
+	Type        string   // Type of the value: Double, Int, Boolean, Combo, Date, String, StringList, SubstrList or Conid
+	Values      []string // Accepted values of a Combo filter
+	FilterID    string   // ID of the filter the field belongs to, listed in the Filters of the instruments
+	Category    string
+}
+
+// ScannerCatalogue is the typed content of the scanner parameters XML returned by ReqScannerParameters.
+type ScannerCatalogue struct {
+	Instruments []ScannerInstrument
+	Locations   []ScannerLocation // Location tree, flattened depth first
+	ScanCodes   []ScanCode
+	Filters     []ScannerFilter
+}
+
+// XML representation of the scanner parameters. Only the parts used by the catalogue are decoded.
+type xmlScannerParameters struct {
+	XMLName         xml.Name                   `xml:"ScanParameterResponse"`
+	InstrumentLists []xmlScannerInstrumentList `xml:"InstrumentList"`
+	Locations       []xmlScannerLocation       `xml:"LocationTree>Location"`
+	ScanTypes       []xmlScanType              `xml:"ScanTypeList>ScanType"`
+	FilterLists     []xmlScannerFilterList     `xml:"FilterList"`
+}
+
+type xmlScannerInstrumentList struct {
+	VarName     string                 `xml:"varName,attr"`
+	Instruments []xmlScannerInstrument `xml:"Instrument"`
+}
+
+type xmlScannerInstrument struct {
+	Name    string `xml:"name"`
+	Type    string `xml:"type"`
+	SecType string `xml:"secType"`
+	Filters string `xml:"filters"`
+}
+
+type xmlScannerLocation struct {
+	DisplayName   string               `xml:"displayName"`
+	LocationCode  string               `xml:"locationCode"`
+	Instruments   string               `xml:"instruments"`
+	RouteExchange string               `xml:"routeExchange"`
+	Children      []xmlScannerLocation `xml:"LocationTree>Location"`
+}
+
+type xmlScanType struct {
+	DisplayName string `xml:"displayName"`
+	ScanCode    string `xml:"scanCode"`
+	Instruments string `xml:"instruments"`
+}
+
+type xmlScannerFilterList struct {
+	VarName string             `xml:"varName,attr"`
+	Filters []xmlScannerFilter `xml:",any"`
+}
+
+type xmlScannerFilter struct {
+	ID       string                  `xml:"id"`
+	Category string                  `xml:"category"`
+	Fields   []xmlScannerFilterField `xml:"AbstractField"`
+	Triple   *struct {
+		Code        string `xml:"code"`
+		CodeNot     string `xml:"codeNot"`
+		DisplayName string `xml:"displayName"`
+	} `xml:"TripleComboField"`
+}
+
+type xmlScannerFilterField struct {
+	Type        string `xml:"type,attr"`
+	Code        string `xml:"code"`
+	DisplayName string `xml:"displayName"`
+	Values      []struct {
+		Code string `xml:"code"`
+	} `xml:"ComboValues>ComboValue"`
+}
+
+// splitList splits a comma separated list of the scanner parameters.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// scannerFilterType returns the short type of a filter field, e.g. "Double" for "scanner.filter.DoubleField".
+func scannerFilterType(t string) string {
+	t = strings.TrimPrefix(t, "scanner.filter.")
+	t, _, _ = strings.Cut(t, "$")
+	return strings.TrimSuffix(t, "Field")
+}
+
+// listByVarName returns the list with the varName, or the first one.
+func listByVarName[T any](lists []T, varName func(T) string, name string) (T, bool) {
+	for _, l := range lists {
+		if varName(l) == name {
+			return l, true
+		}
+	}
+	if len(lists) > 0 {
+		return lists[0], true
+	}
+	var zero T
+	return zero, false
+}
+
+func flattenScannerLocations(locations []xmlScannerLocation, parent string, flat []ScannerLocation) []ScannerLocation {
+	for _, xl := range locations {
+		flat = append(flat, ScannerLocation{
+			Code:          strings.TrimSpace(xl.LocationCode),
+			DisplayName:   strings.TrimSpace(xl.DisplayName),
+			Instruments:   splitList(xl.Instruments),
+			RouteExchange: strings.TrimSpace(xl.RouteExchange),
+			Parent:        parent,
+		})
+		flat = flattenScannerLocations(xl.Children, strings.TrimSpace(xl.LocationCode), flat)
+	}
+	return flat
+}
+
+// ParseScannerParameters parses the scanner parameters XML returned by ReqScannerParameters.
+// The filter fields without code, which cannot be set through the API, are skipped.
+func ParseScannerParameters(sxml string) (*ScannerCatalogue, error) {
+	var x xmlScannerParameters
+	if err := xml.Unmarshal([]byte(sxml), &x); err != nil {
+		return nil, fmt.Errorf("parse scanner parameters: %w", err)
+	}
+	c := &ScannerCatalogue{Locations: flattenScannerLocations(x.Locations, "", nil)}
+	if list, ok := listByVarName(x.InstrumentLists, func(l xmlScannerInstrumentList) string { return l.VarName }, "instrumentList"); ok {
+		for _, xi := range list.Instruments {
+			c.Instruments = append(c.Instruments, ScannerInstrument{
+				Type:    strings.TrimSpace(xi.Type),
+				Name:    strings.TrimSpace(xi.Name),
+				SecType: strings.TrimSpace(xi.SecType),
+				Filters: splitList(xi.Filters),
+			})
+		}
+	}
+	for _, xs := range x.ScanTypes {
+		c.ScanCodes = append(c.ScanCodes, ScanCode{
+			Code:        strings.TrimSpace(xs.ScanCode),
+			DisplayName: strings.TrimSpace(xs.DisplayName),
+			Instruments: splitList(xs.Instruments),
+		})
+	}
+	if list, ok := listByVarName(x.FilterLists, func(l xmlScannerFilterList) string { return l.VarName }, "filterList"); ok {
+		for _, xf := range list.Filters {
+			id, category := strings.TrimSpace(xf.ID), strings.TrimSpace(xf.Category)
+			for _, field := range xf.Fields {
+				if field.Code = strings.TrimSpace(field.Code); field.Code == "" {
+					continue
+				}
+				f := ScannerFilter{
+					Code:        field.Code,
+					DisplayName: strings.TrimSpace(field.DisplayName),
+					Type:        scannerFilterType(field.Type),
+					FilterID:    id,
+					Category:    category,
+				}
+				for _, v := range field.Values {
+					if v := strings.TrimSpace(v.Code); v != "" {
+						f.Values = append(f.Values, v)
+					}
+				}
+				c.Filters = append(c.Filters, f)
+			}
+			if t := xf.Triple; t != nil {
+				for _, code := range []string{t.Code, t.CodeNot} {
+					if code = strings.TrimSpace(code); code != "" {
+						c.Filters = append(c.Filters, ScannerFilter{Code: code, DisplayName: strings.TrimSpace(t.DisplayName), Type: "String", FilterID: id, Category: category})
+					}
+				}
+			}
+		}
+	}
+	return c, nil
+}
+
+// ReqScannerCatalogue requests the scanner parameters and parses them into a catalogue.
+func (ib *IB) ReqScannerCatalogue() (*ScannerCatalogue, error) {
+	sxml, err := ib.ReqScannerParameters()
+	if err != nil {
+		return nil, err
+	}
+	return ParseScannerParameters(sxml)
+}
+
+// Instrument returns the instrument of the type.
+func (c *ScannerCatalogue) Instrument(instrumentType string) (ScannerInstrument, bool) {
+	i := slices.IndexFunc(c.Instruments, func(si ScannerInstrument) bool { return si.Type == instrumentType })
+	if i < 0 {
+		return ScannerInstrument{}, false
+	}
+	return c.Instruments[i], true
+}
+
+// Location returns the location of the code.
+func (c *ScannerCatalogue) Location(code string) (ScannerLocation, bool) {
+	i := slices.IndexFunc(c.Locations, func(l ScannerLocation) bool { return l.Code == code })
+	if i < 0 {
+		return ScannerLocation{}, false
+	}
+	return c.Locations[i], true
+}
+
+// ScanCode returns the scan type of the code.
+func (c *ScannerCatalogue) ScanCode(code string) (ScanCode, bool) {
+	i := slices.IndexFunc(c.ScanCodes, func(sc ScanCode) bool { return sc.Code == code })
+	if i < 0 {
+		return ScanCode{}, false
+	}
+	return c.ScanCodes[i], true
+}
+
+// Filter returns the filter of the code.
+func (c *ScannerCatalogue) Filter(code string) (ScannerFilter, bool) {
+	i := slices.IndexFunc(c.Filters, func(f ScannerFilter) bool { return f.Code == code })
+	if i < 0 {
+		return ScannerFilter{}, false
+	}
+	return c.Filters[i], true
+}
+
+// FiltersFor returns the filters available for an instrument type.
+func (c *ScannerCatalogue) FiltersFor(instrumentType string) []ScannerFilter {
+	instrument, ok := c.Instrument(instrumentType)
+	if !ok {
+		return nil
+	}
+	var filters []ScannerFilter
+	for _, f := range c.Filters {
+		if slices.Contains(instrument.Filters, f.FilterID) {
+			filters = append(filters, f)
+		}
+	}
+	return filters
+}
+
+// validateValue checks the value of a filter option against the type of the filter.
+func (f ScannerFilter) validateValue(value string) error {
+	var err error
+	switch f.Type {
+	case "Double":
+		_, err = strconv.ParseFloat(value, 64)
+	case "Int", "Conid":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "Boolean":
+		_, err = strconv.ParseBool(value)
+	case "Combo":
+		if len(f.Values) > 0 && !slices.Contains(f.Values, value) {
+			err = fmt.Errorf("want one of %v", strings.Join(f.Values, ", "))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("scanner filter %q: invalid %v value %q: %w", f.Code, f.Type, value, err)
+	}
+	return nil
+}
+
+// Validate checks a scanner subscription and its filter options against the catalogue:
+// the instrument, location and scan code must be known and compatible,
+// and the filters must be known, available for the instrument and have valid values.
+func (c *ScannerCatalogue) Validate(subscription *ScannerSubscription, filterOptions []TagValue) error {
+	var errs []error
+	instrument, ok := c.Instrument(subscription.Instrument)
+	if !ok {
+		errs = append(errs, fmt.Errorf("scanner: unknown instrument %q", subscription.Instrument))
+	}
+	if location, ok := c.Location(subscription.LocationCode); !ok {
+		errs = append(errs, fmt.Errorf("scanner: unknown location %q", subscription.LocationCode))
+	} else if instrument.Type != "" && !slices.Contains(location.Instruments, instrument.Type) {
+		errs = append(errs, fmt.Errorf("scanner: location %q does not support instrument %q", location.Code, instrument.Type))
+	}
+	if scanCode, ok := c.ScanCode(subscription.ScanCode); !ok {
+		errs = append(errs, fmt.Errorf("scanner: unknown scan code %q", subscription.ScanCode))
+	} else if instrument.Type != "" && !slices.Contains(scanCode.Instruments, instrument.Type) {
+		errs = append(errs, fmt.Errorf("scanner: scan code %q does not support instrument %q", scanCode.Code, instrument.Type))
+	}
+	for _, tv := range filterOptions {
+		f, ok := c.Filter(tv.Tag)
+		if !ok {
+			errs = append(errs, fmt.Errorf("scanner: unknown filter %q", tv.Tag))
+			continue
+		}
+		if instrument.Type != "" && !slices.Contains(instrument.Filters, f.FilterID) {
+			errs = append(errs, fmt.Errorf("scanner: filter %q is not available for instrument %q", f.Code, instrument.Type))
+		}
+		if err := f.validateValue(tv.Value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ScannerOptions are the options of ScannerCatalogue.NewScannerSubscription.
+type ScannerOptions struct {
+	Rows    int64      // Number of rows, 0 for the IB default (50)
+	Filters []TagValue // Filter options
+}
+
+// ScannerRows is an option of NewScannerSubscription to set the number of rows.
+func ScannerRows(n int64) func(*ScannerOptions) {
+	return func(o *ScannerOptions) {
+		o.Rows = n
+	}
+}
+
+// ScannerFilterValue is an option of NewScannerSubscription adding a filter option, e.g. ScannerFilterValue("priceAbove", 5).
+func ScannerFilterValue(code string, value any) func(*ScannerOptions) {
+	return func(o *ScannerOptions) {
+		o.Filters = append(o.Filters, TagValue{Tag: code, Value: fmt.Sprint(value)})
+	}
+}
+
+// NewScannerSubscription builds a scanner subscription and its options, validated against the catalogue.
+// The result is ready for ReqScannerSubscription or SubscribeScanner.
+func (c *ScannerCatalogue) NewScannerSubscription(instrument, location, scanCode string, options ...func(*ScannerOptions)) (*ScannerSubscription, ScannerSubscriptionOptions, error) {
+	var opts ScannerOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	subscription := NewScannerSubscription()
+	subscription.Instrument = instrument
+	subscription.LocationCode = location
+	subscription.ScanCode = scanCode
+	if opts.Rows > 0 {
+		subscription.NumberOfRows = opts.Rows
+	}
+	subscriptionOptions := ScannerSubscriptionOptions{FilterOptions: opts.Filters}
+	if err := c.Validate(subscription, opts.Filters); err != nil {
+		return nil, ScannerSubscriptionOptions{}, err
+	}
+	return subscription, subscriptionOptions, nil
+}
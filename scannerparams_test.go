@@ -0,0 +1,156 @@
+package ibsync
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+const testScannerParameters = `<?xml version="1.0" encoding="UTF-8"?>
+<ScanParameterResponse>
+	<InstrumentList varName="instrumentList">
+		<Instrument>
+			<name>US Stocks</name>
+			<type>STK</type>
+			<filters>PRICE,VOLUME,STKTYPE</filters>
+		</Instrument>
+		<Instrument>
+			<name>US Futures</name>
+			<type>FUT.US</type>
+			<secType>FUT</secType>
+			<filters>VOLUME</filters>
+		</Instrument>
+	</InstrumentList>
+	<LocationTree varName="locationTree">
+		<Location>
+			<displayName>US Stocks</displayName>
+			<locationCode>STK.US</locationCode>
+			<instruments>STK</instruments>
+			<LocationTree varName="locationTree">
+				<Location>
+					<displayName>Listed/NASDAQ</displayName>
+					<locationCode>STK.US.MAJOR</locationCode>
+					<instruments>STK</instruments>
+				</Location>
+			</LocationTree>
+		</Location>
+		<Location>
+			<displayName>US Futures</displayName>
+			<locationCode>FUT.US</locationCode>
+			<instruments>FUT.US</instruments>
+		</Location>
+	</LocationTree>
+	<ScanTypeList varName="scanTypeList">
+		<ScanType>
+			<displayName>Top % Gainers</displayName>
+			<scanCode>TOP_PERC_GAIN</scanCode>
+			<instruments>STK,STOCK.EU</instruments>
+		</ScanType>
+	</ScanTypeList>
+	<FilterList varName="filterList">
+		<RangeFilter>
+			<id>PRICE</id>
+			<category>Prices</category>
+			<AbstractField type="scanner.filter.DoubleField" varName="min"><code>priceAbove</code><displayName>Price Above</displayName></AbstractField>
+			<AbstractField type="scanner.filter.DoubleField" varName="max"><code>priceBelow</code><displayName>Price Below</displayName></AbstractField>
+		</RangeFilter>
+		<SimpleFilter>
+			<id>VOLUME</id>
+			<AbstractField type="scanner.filter.IntField" varName="field"><code>volumeAbove</code></AbstractField>
+		</SimpleFilter>
+		<SimpleFilter>
+			<id>STKTYPE</id>
+			<AbstractField type="scanner.filter.ComboField" varName="field">
+				<code>stkTypes</code>
+				<ComboValues varName="values">
+					<ComboValue><default>true</default></ComboValue>
+					<ComboValue><code>inc:CORP</code></ComboValue>
+					<ComboValue><code>exc:ETF</code></ComboValue>
+				</ComboValues>
+			</AbstractField>
+		</SimpleFilter>
+		<SimpleFilter>
+			<id>HALTED</id>
+			<AbstractField type="scanner.filter.BooleanField" varName="field"><code>halted</code></AbstractField>
+		</SimpleFilter>
+	</FilterList>
+	<FilterList varName="uiFilters">
+		<SimpleFilter>
+			<id>UI</id>
+			<AbstractField type="scanner.filter.IntField" varName="field"><code>uiOnly</code></AbstractField>
+		</SimpleFilter>
+	</FilterList>
+</ScanParameterResponse>`
+
+func TestParseScannerParameters(t *testing.T) {
+	c, err := ParseScannerParameters(testScannerParameters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Instruments) != 2 || len(c.ScanCodes) != 1 || len(c.Filters) != 5 {
+		t.Fatalf("catalogue: %v instruments, %v scan codes, %v filters", len(c.Instruments), len(c.ScanCodes), len(c.Filters))
+	}
+	if l, ok := c.Location("STK.US.MAJOR"); !ok || l.Parent != "STK.US" || len(c.Locations) != 3 {
+		t.Errorf("Location() = %+v, %v of %v", l, ok, len(c.Locations))
+	}
+	if f, ok := c.Filter("stkTypes"); !ok || f.Type != "Combo" || len(f.Values) != 2 || f.FilterID != "STKTYPE" {
+		t.Errorf("Filter() = %+v, %v", f, ok)
+	}
+	if fs := c.FiltersFor("FUT.US"); len(fs) != 1 || fs[0].Code != "volumeAbove" {
+		t.Errorf("FiltersFor() = %+v", fs)
+	}
+}
+
+func TestScannerCatalogueValidate(t *testing.T) {
+	c, err := ParseScannerParameters(testScannerParameters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss, opts, err := c.NewScannerSubscription("STK", "STK.US.MAJOR", "TOP_PERC_GAIN",
+		ScannerRows(20), ScannerFilterValue("priceAbove", 5.5), ScannerFilterValue("volumeAbove", 10000), ScannerFilterValue("stkTypes", "inc:CORP"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss.NumberOfRows != 20 || ss.ScanCode != "TOP_PERC_GAIN" || len(opts.FilterOptions) != 3 || opts.FilterOptions[0].Value != "5.5" {
+		t.Errorf("NewScannerSubscription() = %+v, %+v", ss, opts)
+	}
+
+	_, _, err = c.NewScannerSubscription("FUT.US", "STK.US", "TOP_PERC_GAIN",
+		ScannerFilterValue("priceAbove", "cheap"), ScannerFilterValue("stkTypes", "inc:XXX"), ScannerFilterValue("unknown", 1))
+	if err == nil {
+		t.Fatal("NewScannerSubscription() of an invalid scanner error = nil")
+	}
+	for _, want := range []string{
+		`location "STK.US" does not support instrument "FUT.US"`,
+		`scan code "TOP_PERC_GAIN" does not support instrument "FUT.US"`,
+		`filter "priceAbove" is not available`,
+		`invalid Double value "cheap"`,
+		`invalid Combo value "inc:XXX"`,
+		`unknown filter "unknown"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+	if err := c.Validate(&ScannerSubscription{Instrument: "OPT", LocationCode: "X", ScanCode: "Y"}, []TagValue{{Tag: "halted", Value: "maybe"}}); err == nil {
+		t.Errorf("Validate() of unknown codes error = nil")
+	}
+}
+
+func TestParseScannerParametersExample(t *testing.T) {
+	data, err := os.ReadFile("examples/scanners/scanner_parameters.xml")
+	if err != nil {
+		t.Skip(err)
+	}
+	c, err := ParseScannerParameters(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.ScanCode("TOP_PERC_GAIN"); !ok {
+		t.Errorf("TOP_PERC_GAIN not found in %v scan codes", len(c.ScanCodes))
+	}
+	if _, _, err := c.NewScannerSubscription("STK", "STK.US.MAJOR", "TOP_PERC_GAIN",
+		ScannerFilterValue("changePercAbove", 20), ScannerFilterValue("priceAbove", 5), ScannerFilterValue("priceBelow", 50)); err != nil {
+		t.Errorf("NewScannerSubscription() of the example scanner: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package ibsync
 
 import (
+	"math"
 	"sync"
 	"time"
 )
@@ -9,6 +10,7 @@ import (
 // Note: It is the responsibility of the user to lock and unlock this state!
 type ibState struct {
 	mu                  sync.Mutex
+	commissionMu        sync.RWMutex // guards the commission reports of the fills, read without the state lock
 	accounts            []string
 	nextValidID         int64
 	updateAccountTime   time.Time
@@ -27,7 +29,8 @@ type ibState struct {
 	reqID2PnlSingle     map[int64]*PnlSingle               // reqId -> PnlSingle
 	pnlKey2ReqID        map[string]int64                   // Key(account, modelCode) -> reqID
 	pnlSingleKey2ReqID  map[string]int64                   // Key(account, modelCode, conID) -> reqID
-	newsTicks           []NewsTick
+	newsTicks           []NewsTick                         // last maxNewsTicks news ticks
+	newsReqIDs          map[int64]struct{}                 // reqId of the news streams, market data without ticker
 }
 
 // NewState creates and initializes a new ibState instance.
@@ -58,6 +61,7 @@ func (s *ibState) reset() {
 	s.pnlKey2ReqID = make(map[string]int64)
 	s.pnlSingleKey2ReqID = make(map[string]int64)
 	s.newsTicks = nil
+	s.newsReqIDs = make(map[int64]struct{})
 }
 
 // startTicker registers a new ticker with the state for a specific request ID and contract.
@@ -83,6 +87,52 @@ func (s *ibState) endTicker(ticker *Ticker, tickerType string) (int64, bool) {
 	return reqID, true
 }
 
+// marketPrice returns the last market price known for a contract ID.
+// Live tickers are used first, then the portfolio.
+// The state must be locked by the caller.
+func (s *ibState) marketPrice(conID int64) (float64, bool) {
+	if conID == 0 {
+		return 0, false
+	}
+	for contract, ticker := range s.tickers {
+		if contract.ConID != conID {
+			continue
+		}
+		if price := ticker.MarketPrice(); price > 0 && !math.IsNaN(price) {
+			return price, true
+		}
+	}
+	for _, items := range s.portfolio {
+		if pi, ok := items[conID]; ok && pi.MarketPrice > 0 {
+			return pi.MarketPrice, true
+		}
+	}
+	return 0, false
+}
+
+// quote returns the current bid and ask of a contract.
+// The ticker of the contract is used first, then any ticker with the same contract ID.
+// The state must be locked by the caller.
+func (s *ibState) quote(contract *Contract) (bid, ask float64, ok bool) {
+	if ticker, exists := s.tickers[contract]; exists {
+		if bid, ask = ticker.Bid(), ticker.Ask(); bid > 0 && ask > 0 {
+			return bid, ask, true
+		}
+	}
+	if contract == nil || contract.ConID == 0 {
+		return 0, 0, false
+	}
+	for c, ticker := range s.tickers {
+		if c.ConID != contract.ConID {
+			continue
+		}
+		if bid, ask = ticker.Bid(), ticker.Ask(); bid > 0 && ask > 0 {
+			return bid, ask, true
+		}
+	}
+	return 0, 0, false
+}
+
 // updateID updates the next requested ID to be at least the specified minimum ID.
 func (s *ibState) updateID(minID int64) {
 	s.nextValidID = max(s.nextValidID, minID)
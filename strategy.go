@@ -0,0 +1,227 @@
+package ibsync
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// StrategyPosition holds the position, PnL and commissions attributed to a strategy for a given account and contract.
+//
+// Strategies are identified by the OrderRef of their orders. Executions without OrderRef are attributed to the "" strategy.
+type StrategyPosition struct {
+	Strategy      string    // Strategy tag, i.e. the OrderRef of the orders
+	Account       string    // Account of the executions
+	Contract      *Contract // Contract of the executions
+	Position      float64   // Signed position, negative if short
+	AvgCost       float64   // Average price per unit of the open position (not multiplied)
+	MarketPrice   float64   // Last known market price, 0 if unknown
+	RealizedPNL   float64   // Realized PnL, commissions excluded
+	UnrealizedPNL float64   // Unrealized PnL at MarketPrice, 0 if the market price is unknown
+	Commission    float64   // Commissions and fees paid
+}
+
+// StrategyPnl aggregates the PnL and commissions of all the positions of a strategy.
+type StrategyPnl struct {
+	Strategy      string
+	RealizedPNL   float64
+	UnrealizedPNL float64
+	Commission    float64
+}
+
+// quantityEpsilon is the tolerance used to consider a float quantity as zero.
+const quantityEpsilon = 1e-9
+
+// isZeroQuantity checks if a quantity is zero within quantityEpsilon.
+func isZeroQuantity(q float64) bool {
+	return math.Abs(q) < quantityEpsilon
+}
+
+// sortFills sorts the fills in chronological order. ExecID breaks ties for fills with the same time.
+func sortFills(fills []*Fill) {
+	slices.SortStableFunc(fills, func(a, b *Fill) int {
+		if c := a.Time.Compare(b.Time); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Execution.ExecID, b.Execution.ExecID)
+	})
+}
+
+// fillCommission returns the commission and fees of a fill, 0 if not yet received.
+func fillCommission(f *Fill) float64 {
+	c := f.CommissionReport().CommissionAndFees
+	if c == UNSET_FLOAT || math.IsNaN(c) {
+		return 0
+	}
+	return c
+}
+
+// strategyPositions replays the fills in chronological order and computes the average cost positions
+// by strategy, account and contract. strategyOf returns the strategy tag of a fill.
+func strategyPositions(fills []*Fill, strategyOf func(*Fill) string) []StrategyPosition {
+	fills = slices.Clone(fills)
+	sortFills(fills)
+
+	positions := make(map[string]*StrategyPosition)
+	for _, f := range fills {
+		if f == nil || f.Execution == nil || f.Contract == nil {
+			continue
+		}
+		strategy := strategyOf(f)
+		key := Key(strategy, f.Execution.AcctNumber, f.Contract.ConID)
+		sp, ok := positions[key]
+		if !ok {
+			sp = &StrategyPosition{Strategy: strategy, Account: f.Execution.AcctNumber, Contract: f.Contract}
+			positions[key] = sp
+		}
+		sp.Commission += fillCommission(f)
+
+		q := f.signedShares()
+		price := f.Execution.Price
+		if isZeroQuantity(sp.Position) || (sp.Position > 0) == (q > 0) {
+			// Opening or increasing the position
+			total := math.Abs(sp.Position) + math.Abs(q)
+			if total > 0 {
+				sp.AvgCost = (sp.AvgCost*math.Abs(sp.Position) + price*math.Abs(q)) / total
+			}
+			sp.Position += q
+			continue
+		}
+		// Reducing, closing or reversing the position
+		closed := math.Min(math.Abs(q), math.Abs(sp.Position))
+		direction := 1.0
+		if sp.Position < 0 {
+			direction = -1.0
+		}
+		sp.RealizedPNL += (price - sp.AvgCost) * closed * direction * contractMultiplier(f.Contract)
+		sp.Position += q
+		switch {
+		case isZeroQuantity(sp.Position):
+			sp.Position = 0
+			sp.AvgCost = 0
+		case math.Abs(q) > closed:
+			// The position is reversed, the remaining quantity is opened at the fill price.
+			sp.AvgCost = price
+		}
+	}
+
+	sps := make([]StrategyPosition, 0, len(positions))
+	for _, sp := range positions {
+		sps = append(sps, *sp)
+	}
+	slices.SortFunc(sps, func(a, b StrategyPosition) int {
+		return cmp.Or(
+			cmp.Compare(a.Strategy, b.Strategy),
+			cmp.Compare(a.Account, b.Account),
+			cmp.Compare(a.Contract.ConID, b.Contract.ConID),
+		)
+	})
+	return sps
+}
+
+// strategyOf returns the strategy tag of a fill.
+// It is the OrderRef of the execution or, if empty, the OrderRef of the order of the fill.
+// The state must be locked by the caller.
+func (s *ibState) strategyOf(f *Fill) string {
+	if f.Execution.OrderRef != "" {
+		return f.Execution.OrderRef
+	}
+	trade, ok := s.permID2Trade[f.Execution.PermID]
+	if !ok {
+		trade, ok = s.trades[orderKey(f.Execution.ClientID, f.Execution.OrderID, f.Execution.PermID)]
+	}
+	if !ok || trade.Order == nil {
+		return ""
+	}
+	return trade.Order.OrderRef
+}
+
+// Strategies returns the sorted list of the strategy tags (order references) of the trades and fills of this session.
+// Untagged orders and fills are not listed.
+func (ib *IB) Strategies() []string {
+	ib.state.mu.Lock()
+	defer ib.state.mu.Unlock()
+	var strategies []string
+	for _, t := range ib.state.trades {
+		if t.Order != nil && t.Order.OrderRef != "" {
+			strategies = append(strategies, t.Order.OrderRef)
+		}
+	}
+	for _, f := range ib.state.fills {
+		if s := ib.state.strategyOf(f); s != "" {
+			strategies = append(strategies, s)
+		}
+	}
+	slices.Sort(strategies)
+	return slices.Compact(strategies)
+}
+
+// StrategyPositions returns the positions attributed to the given strategies.
+//
+// If no strategy is provided it will return the positions of all strategies, including the untagged "" strategy.
+// Positions are computed from the fills of this session with the average cost method.
+// Call ReqStrategies to include the executions of previous sessions of the day.
+// Closed positions are returned as they can still hold realized PnL and commissions.
+func (ib *IB) StrategyPositions(strategy ...string) []StrategyPosition {
+	ib.state.mu.Lock()
+	defer ib.state.mu.Unlock()
+
+	fills := make([]*Fill, 0, len(ib.state.fills))
+	for _, f := range ib.state.fills {
+		fill := *f
+		fills = append(fills, &fill)
+	}
+
+	var sps []StrategyPosition
+	for _, sp := range strategyPositions(fills, ib.state.strategyOf) {
+		if len(strategy) > 0 && !slices.Contains(strategy, sp.Strategy) {
+			continue
+		}
+		if price, ok := ib.state.marketPrice(sp.Contract.ConID); ok {
+			sp.MarketPrice = price
+			sp.UnrealizedPNL = (price - sp.AvgCost) * sp.Position * contractMultiplier(sp.Contract)
+		}
+		sps = append(sps, sp)
+	}
+	return sps
+}
+
+// StrategyPnl returns the realized and unrealized PnL and the commissions of a strategy.
+func (ib *IB) StrategyPnl(strategy string) StrategyPnl {
+	pnl := StrategyPnl{Strategy: strategy}
+	for _, sp := range ib.StrategyPositions(strategy) {
+		pnl.RealizedPNL += sp.RealizedPNL
+		pnl.UnrealizedPNL += sp.UnrealizedPNL
+		pnl.Commission += sp.Commission
+	}
+	return pnl
+}
+
+// StrategyOpenTrades returns the open trades of a strategy.
+func (ib *IB) StrategyOpenTrades(strategy string) []*Trade {
+	ib.state.mu.Lock()
+	defer ib.state.mu.Unlock()
+	var ts []*Trade
+	for _, t := range ib.state.trades {
+		if t.Order != nil && t.Order.OrderRef == strategy && !t.IsDone() {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+// ReqStrategies rebuilds the strategy attribution after a restart.
+//
+// It requests the open orders, the completed orders and the executions of the day from all clients,
+// so that StrategyPositions, StrategyPnl and StrategyOpenTrades also cover the previous sessions,
+// the other client IDs and TWS.
+func (ib *IB) ReqStrategies() error {
+	if err := ib.ReqAllOpenOrders(); err != nil {
+		return err
+	}
+	if err := ib.ReqCompletedOrders(false); err != nil {
+		return err
+	}
+	_, err := ib.ReqExecutions(NewExecutionFilter())
+	return err
+}
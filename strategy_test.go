@@ -0,0 +1,109 @@
+package ibsync
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var testFillTime = time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+
+// newTestFill creates a fill of shares at price, seconds after testFillTime.
+// side is "BOT" or "SLD".
+func newTestFill(execID string, orderRef string, contract *Contract, side string, shares float64, price float64, seconds int) *Fill {
+	return &Fill{
+		Contract: contract,
+		Execution: &Execution{
+			ExecID:     execID,
+			AcctNumber: "DU123",
+			Side:       side,
+			Shares:     StringToDecimal(FloatMaxString(shares)),
+			Price:      price,
+			OrderRef:   orderRef,
+		},
+		CommissionAndFeesReport: CommissionAndFeesReport{ExecID: execID, CommissionAndFees: 1},
+		Time:                    testFillTime.Add(time.Duration(seconds) * time.Second),
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestStrategyPositions(t *testing.T) {
+	aapl := &Contract{ConID: 265598, Symbol: "AAPL", SecType: "STK"}
+	es := &Contract{ConID: 495512563, Symbol: "ES", SecType: "FUT", Multiplier: "50"}
+
+	fills := []*Fill{
+		newTestFill("3", "momentum", aapl, "SLD", 150, 13, 3),
+		newTestFill("1", "momentum", aapl, "BOT", 100, 10, 1),
+		newTestFill("2", "momentum", aapl, "BOT", 100, 12, 2),
+		newTestFill("4", "meanrev", aapl, "SLD", 50, 20, 4),
+		newTestFill("5", "meanrev", es, "BOT", 1, 5000, 5),
+		newTestFill("6", "meanrev", es, "SLD", 3, 5010, 6),
+	}
+
+	sps := strategyPositions(fills, func(f *Fill) string { return f.Execution.OrderRef })
+	if len(sps) != 3 {
+		t.Fatalf("strategyPositions() len = %v, want %v", len(sps), 3)
+	}
+
+	tests := []struct {
+		name       string
+		sp         StrategyPosition
+		strategy   string
+		position   float64
+		avgCost    float64
+		realized   float64
+		commission float64
+	}{
+		{"meanrev short stock", sps[0], "meanrev", -50, 20, 0, 1},
+		{"meanrev reversed future", sps[1], "meanrev", -2, 5010, 500, 2},
+		{"momentum partially closed", sps[2], "momentum", 50, 11, 300, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.sp.Strategy != tt.strategy {
+				t.Errorf("Strategy = %v, want %v", tt.sp.Strategy, tt.strategy)
+			}
+			if !almostEqual(tt.sp.Position, tt.position) {
+				t.Errorf("Position = %v, want %v", tt.sp.Position, tt.position)
+			}
+			if !almostEqual(tt.sp.AvgCost, tt.avgCost) {
+				t.Errorf("AvgCost = %v, want %v", tt.sp.AvgCost, tt.avgCost)
+			}
+			if !almostEqual(tt.sp.RealizedPNL, tt.realized) {
+				t.Errorf("RealizedPNL = %v, want %v", tt.sp.RealizedPNL, tt.realized)
+			}
+			if !almostEqual(tt.sp.Commission, tt.commission) {
+				t.Errorf("Commission = %v, want %v", tt.sp.Commission, tt.commission)
+			}
+		})
+	}
+}
+
+func TestStrategyOf(t *testing.T) {
+	s := NewState()
+	order := NewOrder()
+	order.OrderID = 7
+	order.ClientID = 1
+	order.OrderRef = "breakout"
+	s.trades[orderKey(1, 7, 0)] = NewTrade(&Contract{}, order)
+
+	tagged := newTestFill("1", "momentum", &Contract{}, "BOT", 1, 1, 0)
+	untagged := newTestFill("2", "", &Contract{}, "BOT", 1, 1, 0)
+	untagged.Execution.ClientID = 1
+	untagged.Execution.OrderID = 7
+	unknown := newTestFill("3", "", &Contract{}, "BOT", 1, 1, 0)
+
+	if got := s.strategyOf(tagged); got != "momentum" {
+		t.Errorf("strategyOf(tagged) = %v, want %v", got, "momentum")
+	}
+	if got := s.strategyOf(untagged); got != "breakout" {
+		t.Errorf("strategyOf(untagged) = %v, want %v", got, "breakout")
+	}
+	if got := s.strategyOf(unknown); got != "" {
+		t.Errorf("strategyOf(unknown) = %v, want empty", got)
+	}
+}
@@ -0,0 +1,132 @@
+package ibsync
+
+import (
+	"sync"
+	"time"
+)
+
+// Subscription is a stream of data that reports why it ended.
+//
+// Chan is closed when the stream ends: on completion, on Cancel, on disconnection or on an IB error.
+// Err then returns the terminal error, an ibapi.CodeMsgPair for IB errors, or nil if the stream completed or was cancelled.
+type Subscription[T any] struct {
+	ch         chan T
+	done       chan struct{}
+	stop       chan struct{}
+	stopOnce   sync.Once
+	finishOnce sync.Once
+	mu         sync.Mutex
+	err        error
+}
+
+// newSubscription creates a subscription with a channel buffer of size.
+func newSubscription[T any](size int) *Subscription[T] {
+	return &Subscription[T]{
+		ch:   make(chan T, size),
+		done: make(chan struct{}),
+		stop: make(chan struct{}),
+	}
+}
+
+// Chan returns the channel of the data. Do NOT close the channel.
+func (s *Subscription[T]) Chan() <-chan T {
+	return s.ch
+}
+
+// Done returns a channel closed when the stream has ended and Err is set.
+func (s *Subscription[T]) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error that ended the stream. It is nil while the stream is running,
+// if the stream completed or if it was cancelled.
+func (s *Subscription[T]) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Cancel cancels the subscription and waits for the stream to end. It is safe to call it several times.
+func (s *Subscription[T]) Cancel() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// send sends v on the channel. It returns false if the subscription was cancelled.
+func (s *Subscription[T]) send(v T) bool {
+	select {
+	case s.ch <- v:
+		return true
+	case <-s.stop:
+		return false
+	}
+}
+
+// finish ends the stream with err.
+func (s *Subscription[T]) finish(err error) {
+	s.finishOnce.Do(func() {
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		close(s.ch)
+		close(s.done)
+	})
+}
+
+// pollLatest calls next every interval, from now, until done or disconnected is closed, then closes ch.
+// The values for which next returns true are sent on ch, of size 1, replacing an unread value.
+// A nil disconnected channel is never closed.
+func pollLatest[T any](ch chan T, interval time.Duration, done, disconnected <-chan struct{}, next func() (T, bool)) {
+	defer close(ch)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if v, ok := next(); ok {
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- v
+		}
+		select {
+		case <-done:
+			return
+		case <-disconnected:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// HistoricalDataSubscription is a stream of historical bars.
+type HistoricalDataSubscription struct {
+	*Subscription[Bar]
+	periodMu sync.Mutex
+	start    string
+	end      string
+}
+
+// Period returns the start and end dates of the bars sent by IB with the end of the historical data.
+// ok is false until they are received.
+func (s *HistoricalDataSubscription) Period() (start, end time.Time, ok bool) {
+	s.periodMu.Lock()
+	defer s.periodMu.Unlock()
+	if s.start == "" && s.end == "" {
+		return time.Time{}, time.Time{}, false
+	}
+	start, _ = ParseIBTime(s.start)
+	end, _ = ParseIBTime(s.end)
+	return start, end, true
+}
+
+// setPeriod records the start and end dates received with HistoricalDataEnd.
+func (s *HistoricalDataSubscription) setPeriod(start, end string) {
+	s.periodMu.Lock()
+	defer s.periodMu.Unlock()
+	s.start, s.end = start, end
+}
+
+// RealTimeBarSubscription is a stream of real time bars.
+type RealTimeBarSubscription struct {
+	*Subscription[RealTimeBar]
+}
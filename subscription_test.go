@@ -0,0 +1,87 @@
+package ibsync
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSubscription(t *testing.T) {
+	t.Run("error", func(t *testing.T) {
+		sub := newSubscription[int](1)
+		errTest := errors.New("test")
+		go func() {
+			sub.send(1)
+			sub.send(2)
+			sub.finish(errTest)
+		}()
+		var got []int
+		for v := range sub.Chan() {
+			got = append(got, v)
+		}
+		<-sub.Done()
+		if len(got) != 2 || !errors.Is(sub.Err(), errTest) {
+			t.Errorf("got %v, err %v, want [1 2], %v", got, sub.Err(), errTest)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		sub := newSubscription[int](0)
+		sent := make(chan bool)
+		go func() {
+			// Nobody reads, send is unblocked by Cancel.
+			ok := sub.send(1)
+			sub.finish(nil)
+			sent <- ok
+		}()
+		sub.Cancel()
+		sub.Cancel()
+		select {
+		case ok := <-sent:
+			if ok {
+				t.Errorf("send() after Cancel = true, want false")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Cancel did not unblock send")
+		}
+		if sub.Err() != nil {
+			t.Errorf("Err() after Cancel = %v, want nil", sub.Err())
+		}
+	})
+
+	t.Run("period", func(t *testing.T) {
+		sub := &HistoricalDataSubscription{Subscription: newSubscription[Bar](0)}
+		if _, _, ok := sub.Period(); ok {
+			t.Errorf("Period() ok before HistoricalDataEnd")
+		}
+		sub.setPeriod("20240102 09:30:00 US/Eastern", "20240102 16:00:00 US/Eastern")
+		start, end, ok := sub.Period()
+		if !ok || end.Sub(start) != 390*time.Minute {
+			t.Errorf("Period() = %v, %v, %v", start, end, ok)
+		}
+	})
+}
+
+func TestPollLatest(t *testing.T) {
+	ch := make(chan int, 1)
+	done := make(chan struct{})
+	calls := 0
+	go pollLatest(ch, time.Millisecond, done, nil, func() (int, bool) {
+		calls++
+		return calls, calls%2 == 1
+	})
+	if v := <-ch; v != 1 {
+		t.Errorf("first value = %v, want 1", v)
+	}
+	for v := range ch {
+		if v%2 == 0 {
+			t.Errorf("value %v sent, want only odd values", v)
+		}
+		if v >= 5 {
+			close(done)
+			break
+		}
+	}
+	for range ch {
+	}
+}
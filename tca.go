@@ -0,0 +1,409 @@
+package ibsync
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// FillRatePoint is the cumulative filled quantity of a trade after a fill.
+type FillRatePoint struct {
+	Time     time.Time
+	Elapsed  time.Duration // Time elapsed since the order was placed
+	Filled   float64       // Cumulative filled quantity
+	FillRate float64       // Filled / ordered quantity, NaN if the ordered quantity is unknown
+}
+
+// TradeCost is the transaction cost analysis of a trade.
+//
+// Prices are per unit (not multiplied). Costs are positive when the execution is worse than the benchmark.
+// Values depending on the arrival price are NaN if no quote was available when the order was placed.
+type TradeCost struct {
+	OrderID            int64
+	PermID             int64
+	OrderRef           string
+	Symbol             string
+	ConID              int64
+	Side               string // "BUY" or "SELL"
+	OrderedQuantity    float64
+	FilledQuantity     float64
+	FilledNotional     float64 // AvgFillPrice * FilledQuantity * multiplier
+	PlacedTime         time.Time
+	FirstFillTime      time.Time
+	LastFillTime       time.Time
+	ArrivalBid         float64
+	ArrivalAsk         float64
+	ArrivalPrice       float64 // Mid price when the order was placed
+	AvgFillPrice       float64 // Quantity weighted average fill price
+	ShortfallBps       float64 // Implementation shortfall vs the arrival price, in basis points
+	ShortfallCost      float64 // Implementation shortfall in currency: (AvgFillPrice - ArrivalPrice) * FilledQuantity * multiplier, signed by side
+	QuotedSpreadBps    float64 // Arrival bid-ask spread, in basis points of the arrival price
+	SpreadPaid         float64 // Fraction of the arrival half-spread paid: 1 when crossing the spread, 0 at mid, negative for price improvement
+	Commission         float64 // Commissions and fees
+	CommissionPerShare float64
+	FillRates          []FillRatePoint
+}
+
+// tradeSide returns the side of a trade: +1 for buys and -1 for sells. It returns 0 if unknown.
+func tradeSide(order *Order, fills []*Fill) float64 {
+	if order != nil {
+		switch order.Action {
+		case "BUY":
+			return 1
+		case "SELL", "SSHORT":
+			return -1
+		}
+	}
+	for _, f := range fills {
+		if f.Execution == nil {
+			continue
+		}
+		if f.Execution.Side == "BOT" {
+			return 1
+		}
+		if f.Execution.Side == "SLD" {
+			return -1
+		}
+	}
+	return 0
+}
+
+// Cost returns the transaction cost analysis of the trade, based on its arrival quote, fills and logs.
+//
+// The arrival quote is recorded by PlaceOrder if a ticker with a bid and an ask is subscribed for the contract.
+func (t *Trade) Cost() TradeCost {
+	t.mu.RLock()
+	fills := make([]*Fill, len(t.fills))
+	copy(fills, t.fills)
+	tc := TradeCost{
+		OrderID:    t.OrderStatus.OrderID,
+		PermID:     t.OrderStatus.PermID,
+		ArrivalBid: t.arrivalBid,
+		ArrivalAsk: t.arrivalAsk,
+	}
+	if len(t.logs) > 0 {
+		tc.PlacedTime = t.logs[0].Time
+		for _, l := range t.logs {
+			if l.Time.Before(tc.PlacedTime) {
+				tc.PlacedTime = l.Time
+			}
+		}
+	}
+	order := t.Order
+	contract := t.Contract
+	t.mu.RUnlock()
+
+	side := tradeSide(order, fills)
+	switch side {
+	case 1:
+		tc.Side = "BUY"
+	case -1:
+		tc.Side = "SELL"
+	}
+	if order != nil {
+		tc.OrderRef = order.OrderRef
+		tc.OrderedQuantity = decimalToFloat(order.TotalQuantity)
+		if tc.PermID == 0 {
+			tc.PermID = order.PermID
+		}
+	}
+	multiplier := 1.0
+	if contract != nil {
+		tc.Symbol = contract.Symbol
+		tc.ConID = contract.ConID
+		multiplier = contractMultiplier(contract)
+	}
+
+	sortFills(fills)
+	var notional float64
+	for _, f := range fills {
+		if f.Execution == nil {
+			continue
+		}
+		shares := decimalToFloat(f.Execution.Shares)
+		tc.FilledQuantity += shares
+		notional += shares * f.Execution.Price
+		tc.Commission += fillCommission(f)
+		if tc.FirstFillTime.IsZero() {
+			tc.FirstFillTime = f.Time
+		}
+		tc.LastFillTime = f.Time
+		point := FillRatePoint{Time: f.Time, Filled: tc.FilledQuantity, FillRate: math.NaN()}
+		if !tc.PlacedTime.IsZero() {
+			point.Elapsed = f.Time.Sub(tc.PlacedTime)
+		}
+		if tc.OrderedQuantity > 0 {
+			point.FillRate = tc.FilledQuantity / tc.OrderedQuantity
+		}
+		tc.FillRates = append(tc.FillRates, point)
+	}
+
+	tc.AvgFillPrice = math.NaN()
+	tc.CommissionPerShare = math.NaN()
+	if tc.FilledQuantity > 0 {
+		tc.AvgFillPrice = notional / tc.FilledQuantity
+		tc.CommissionPerShare = tc.Commission / tc.FilledQuantity
+		tc.FilledNotional = notional * multiplier
+	}
+
+	tc.ArrivalPrice = math.NaN()
+	tc.QuotedSpreadBps = math.NaN()
+	tc.ShortfallBps = math.NaN()
+	tc.ShortfallCost = math.NaN()
+	tc.SpreadPaid = math.NaN()
+	if tc.ArrivalBid > 0 && tc.ArrivalAsk > 0 {
+		tc.ArrivalPrice = (tc.ArrivalBid + tc.ArrivalAsk) * 0.5
+		tc.QuotedSpreadBps = (tc.ArrivalAsk - tc.ArrivalBid) / tc.ArrivalPrice * 1e4
+		if tc.FilledQuantity > 0 && side != 0 {
+			slippage := side * (tc.AvgFillPrice - tc.ArrivalPrice)
+			tc.ShortfallBps = slippage / tc.ArrivalPrice * 1e4
+			tc.ShortfallCost = slippage * tc.FilledQuantity * multiplier
+			if halfSpread := (tc.ArrivalAsk - tc.ArrivalBid) * 0.5; halfSpread > 0 {
+				tc.SpreadPaid = slippage / halfSpread
+			}
+		}
+	}
+	return tc
+}
+
+// TradeCosts returns the transaction cost analysis of the given trades.
+// If no trade is provided it will analyse all the trades of the session with at least one fill.
+func (ib *IB) TradeCosts(trades ...*Trade) []TradeCost {
+	if len(trades) == 0 {
+		for _, t := range ib.Trades() {
+			if len(t.Fills()) > 0 {
+				trades = append(trades, t)
+			}
+		}
+	}
+	tcs := make([]TradeCost, 0, len(trades))
+	for _, t := range trades {
+		tcs = append(tcs, t.Cost())
+	}
+	return tcs
+}
+
+// CostSummary aggregates the transaction costs of several trades.
+//
+// Averages in basis points are weighted by the filled notional of the trades with an arrival price.
+type CostSummary struct {
+	Trades             int     // Number of trades
+	TradesWithArrival  int     // Number of filled trades with an arrival price
+	FilledQuantity     float64 // Total filled quantity
+	FilledNotional     float64 // Total filled notional, multiplier included
+	ShortfallBps       float64 // Notional weighted implementation shortfall
+	ShortfallCost      float64 // Total implementation shortfall in currency
+	QuotedSpreadBps    float64 // Notional weighted arrival spread
+	SpreadPaid         float64 // Quantity weighted fraction of the arrival half-spread paid
+	Commission         float64 // Total commissions and fees
+	CommissionPerShare float64
+	CommissionBps      float64 // Commissions in basis points of the filled notional
+}
+
+// SummarizeCosts aggregates the transaction costs of several trades.
+// Trades in different currencies should not be aggregated together.
+func SummarizeCosts(tcs []TradeCost) CostSummary {
+	cs := CostSummary{Trades: len(tcs)}
+	var arrivalNotional, spreadQuantity float64
+	var shortfall, spread, spreadPaid float64
+	for _, tc := range tcs {
+		cs.Commission += tc.Commission
+		if tc.FilledQuantity <= 0 {
+			continue
+		}
+		notional := tc.FilledNotional
+		cs.FilledQuantity += tc.FilledQuantity
+		cs.FilledNotional += notional
+		if math.IsNaN(tc.ShortfallBps) {
+			continue
+		}
+		cs.TradesWithArrival++
+		arrivalNotional += notional
+		shortfall += tc.ShortfallBps * notional
+		spread += tc.QuotedSpreadBps * notional
+		cs.ShortfallCost += tc.ShortfallCost
+		if !math.IsNaN(tc.SpreadPaid) {
+			spreadQuantity += tc.FilledQuantity
+			spreadPaid += tc.SpreadPaid * tc.FilledQuantity
+		}
+	}
+
+	cs.ShortfallBps, cs.QuotedSpreadBps, cs.SpreadPaid = math.NaN(), math.NaN(), math.NaN()
+	cs.CommissionPerShare, cs.CommissionBps = math.NaN(), math.NaN()
+	if arrivalNotional > 0 {
+		cs.ShortfallBps = shortfall / arrivalNotional
+		cs.QuotedSpreadBps = spread / arrivalNotional
+	}
+	if spreadQuantity > 0 {
+		cs.SpreadPaid = spreadPaid / spreadQuantity
+	}
+	if cs.FilledQuantity > 0 {
+		cs.CommissionPerShare = cs.Commission / cs.FilledQuantity
+	}
+	if cs.FilledNotional > 0 {
+		cs.CommissionBps = cs.Commission / cs.FilledNotional * 1e4
+	}
+	return cs
+}
+
+// tradeCostHeader is the CSV header written by WriteTradeCostsCSV.
+var tradeCostHeader = []string{
+	"OrderID", "PermID", "OrderRef", "Symbol", "ConID", "Side", "OrderedQuantity", "FilledQuantity", "FilledNotional",
+	"PlacedTime", "FirstFillTime", "LastFillTime", "ArrivalBid", "ArrivalAsk", "ArrivalPrice", "AvgFillPrice",
+	"ShortfallBps", "ShortfallCost", "QuotedSpreadBps", "SpreadPaid", "Commission", "CommissionPerShare",
+}
+
+// formatCSVFloat formats a float for CSV export. NaN are written as empty fields.
+func formatCSVFloat(f float64) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// formatCSVTime formats a time for CSV export. Zero times are written as empty fields.
+func formatCSVTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// WriteTradeCostsCSV writes the transaction costs as CSV, one line per trade, with a header.
+// The fill rates are not exported. Unknown values are written as empty fields.
+func WriteTradeCostsCSV(w io.Writer, tcs []TradeCost) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(tradeCostHeader); err != nil {
+		return err
+	}
+	for _, tc := range tcs {
+		record := []string{
+			strconv.FormatInt(tc.OrderID, 10),
+			strconv.FormatInt(tc.PermID, 10),
+			tc.OrderRef,
+			tc.Symbol,
+			strconv.FormatInt(tc.ConID, 10),
+			tc.Side,
+			formatCSVFloat(tc.OrderedQuantity),
+			formatCSVFloat(tc.FilledQuantity),
+			formatCSVFloat(tc.FilledNotional),
+			formatCSVTime(tc.PlacedTime),
+			formatCSVTime(tc.FirstFillTime),
+			formatCSVTime(tc.LastFillTime),
+			formatCSVFloat(tc.ArrivalBid),
+			formatCSVFloat(tc.ArrivalAsk),
+			formatCSVFloat(tc.ArrivalPrice),
+			formatCSVFloat(tc.AvgFillPrice),
+			formatCSVFloat(tc.ShortfallBps),
+			formatCSVFloat(tc.ShortfallCost),
+			formatCSVFloat(tc.QuotedSpreadBps),
+			formatCSVFloat(tc.SpreadPaid),
+			formatCSVFloat(tc.Commission),
+			formatCSVFloat(tc.CommissionPerShare),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// jsonFloat is a float64 marshalled as null when NaN or infinite, which encoding/json rejects.
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(float64(f))
+}
+
+type jsonFillRatePoint struct {
+	Time     time.Time `json:"time"`
+	Elapsed  string    `json:"elapsed"`
+	Filled   jsonFloat `json:"filled"`
+	FillRate jsonFloat `json:"fillRate"`
+}
+
+type jsonTradeCost struct {
+	OrderID            int64               `json:"orderId"`
+	PermID             int64               `json:"permId"`
+	OrderRef           string              `json:"orderRef,omitempty"`
+	Symbol             string              `json:"symbol"`
+	ConID              int64               `json:"conId"`
+	Side               string              `json:"side"`
+	OrderedQuantity    jsonFloat           `json:"orderedQuantity"`
+	FilledQuantity     jsonFloat           `json:"filledQuantity"`
+	FilledNotional     jsonFloat           `json:"filledNotional"`
+	PlacedTime         *time.Time          `json:"placedTime,omitempty"`
+	FirstFillTime      *time.Time          `json:"firstFillTime,omitempty"`
+	LastFillTime       *time.Time          `json:"lastFillTime,omitempty"`
+	ArrivalBid         jsonFloat           `json:"arrivalBid"`
+	ArrivalAsk         jsonFloat           `json:"arrivalAsk"`
+	ArrivalPrice       jsonFloat           `json:"arrivalPrice"`
+	AvgFillPrice       jsonFloat           `json:"avgFillPrice"`
+	ShortfallBps       jsonFloat           `json:"shortfallBps"`
+	ShortfallCost      jsonFloat           `json:"shortfallCost"`
+	QuotedSpreadBps    jsonFloat           `json:"quotedSpreadBps"`
+	SpreadPaid         jsonFloat           `json:"spreadPaid"`
+	Commission         jsonFloat           `json:"commission"`
+	CommissionPerShare jsonFloat           `json:"commissionPerShare"`
+	FillRates          []jsonFillRatePoint `json:"fillRates"`
+}
+
+// jsonTime returns nil for zero times so that they are omitted.
+func jsonTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// WriteTradeCostsJSON writes the transaction costs as an indented JSON array, fill rates included.
+// Unknown values are written as null.
+func WriteTradeCostsJSON(w io.Writer, tcs []TradeCost) error {
+	out := make([]jsonTradeCost, 0, len(tcs))
+	for _, tc := range tcs {
+		jtc := jsonTradeCost{
+			OrderID:            tc.OrderID,
+			PermID:             tc.PermID,
+			OrderRef:           tc.OrderRef,
+			Symbol:             tc.Symbol,
+			ConID:              tc.ConID,
+			Side:               tc.Side,
+			OrderedQuantity:    jsonFloat(tc.OrderedQuantity),
+			FilledQuantity:     jsonFloat(tc.FilledQuantity),
+			FilledNotional:     jsonFloat(tc.FilledNotional),
+			PlacedTime:         jsonTime(tc.PlacedTime),
+			FirstFillTime:      jsonTime(tc.FirstFillTime),
+			LastFillTime:       jsonTime(tc.LastFillTime),
+			ArrivalBid:         jsonFloat(tc.ArrivalBid),
+			ArrivalAsk:         jsonFloat(tc.ArrivalAsk),
+			ArrivalPrice:       jsonFloat(tc.ArrivalPrice),
+			AvgFillPrice:       jsonFloat(tc.AvgFillPrice),
+			ShortfallBps:       jsonFloat(tc.ShortfallBps),
+			ShortfallCost:      jsonFloat(tc.ShortfallCost),
+			QuotedSpreadBps:    jsonFloat(tc.QuotedSpreadBps),
+			SpreadPaid:         jsonFloat(tc.SpreadPaid),
+			Commission:         jsonFloat(tc.Commission),
+			CommissionPerShare: jsonFloat(tc.CommissionPerShare),
+			FillRates:          make([]jsonFillRatePoint, 0, len(tc.FillRates)),
+		}
+		for _, p := range tc.FillRates {
+			jtc.FillRates = append(jtc.FillRates, jsonFillRatePoint{
+				Time:     p.Time,
+				Elapsed:  p.Elapsed.String(),
+				Filled:   jsonFloat(p.Filled),
+				FillRate: jsonFloat(p.FillRate),
+			})
+		}
+		out = append(out, jtc)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,126 @@
+package ibsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func newTestTrade(contract *Contract, action string, quantity float64, fills ...*Fill) *Trade {
+	order := NewOrder()
+	order.OrderID = 1
+	order.Action = action
+	order.TotalQuantity = StringToDecimal(FloatMaxString(quantity))
+	order.OrderRef = "tca"
+	trade := NewTrade(contract, order)
+	trade.logs[0].Time = testFillTime
+	for _, f := range fills {
+		trade.addFill(f)
+	}
+	return trade
+}
+
+func TestTradeCost(t *testing.T) {
+	aapl := &Contract{ConID: 265598, Symbol: "AAPL", SecType: "STK"}
+
+	buy := newTestTrade(aapl, "BUY", 300,
+		newTestFill("1", "tca", aapl, "BOT", 100, 100.02, 2),
+		newTestFill("2", "tca", aapl, "BOT", 100, 100.06, 10),
+	)
+	buy.setArrivalQuote(99.98, 100.02, testFillTime)
+
+	tc := buy.Cost()
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"ArrivalPrice", tc.ArrivalPrice, 100},
+		{"AvgFillPrice", tc.AvgFillPrice, 100.04},
+		{"ShortfallBps", tc.ShortfallBps, 4},
+		{"ShortfallCost", tc.ShortfallCost, 8},
+		{"QuotedSpreadBps", tc.QuotedSpreadBps, 4},
+		{"SpreadPaid", tc.SpreadPaid, 2},
+		{"CommissionPerShare", tc.CommissionPerShare, 0.01},
+		{"FilledNotional", tc.FilledNotional, 20008},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !almostEqual(tt.got, tt.want) {
+				t.Errorf("%v = %v, want %v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+
+	if len(tc.FillRates) != 2 {
+		t.Fatalf("FillRates len = %v, want %v", len(tc.FillRates), 2)
+	}
+	last := tc.FillRates[1]
+	if last.Elapsed.Seconds() != 10 || !almostEqual(last.FillRate, 2.0/3) {
+		t.Errorf("FillRates[1] = %v, %v, want 10s, %v", last.Elapsed, last.FillRate, 2.0/3)
+	}
+
+	// A sell filled above the arrival mid has a negative shortfall.
+	sell := newTestTrade(aapl, "SELL", 100, newTestFill("3", "tca", aapl, "SLD", 100, 100.01, 1))
+	sell.setArrivalQuote(99.98, 100.02, testFillTime)
+	if tc := sell.Cost(); !almostEqual(tc.ShortfallBps, -1) || !almostEqual(tc.SpreadPaid, -0.5) {
+		t.Errorf("sell Cost() = %v bps, %v spread paid, want %v, %v", tc.ShortfallBps, tc.SpreadPaid, -1, -0.5)
+	}
+
+	// Without arrival quote, the arrival based costs are unknown.
+	noQuote := newTestTrade(aapl, "BUY", 100, newTestFill("4", "tca", aapl, "BOT", 100, 100, 1))
+	if !noQuote.ArrivalTime().IsZero() {
+		t.Errorf("noQuote ArrivalTime() = %v, want zero", noQuote.ArrivalTime())
+	}
+	if tc := noQuote.Cost(); !math.IsNaN(tc.ArrivalPrice) || !math.IsNaN(tc.ShortfallBps) {
+		t.Errorf("noQuote Cost() = %v, %v, want NaN", tc.ArrivalPrice, tc.ShortfallBps)
+	}
+}
+
+func TestSummarizeCosts(t *testing.T) {
+	tcs := []TradeCost{
+		{FilledQuantity: 100, FilledNotional: 10000, ShortfallBps: 4, ShortfallCost: 4, QuotedSpreadBps: 2, SpreadPaid: 1, Commission: 1},
+		{FilledQuantity: 300, FilledNotional: 30000, ShortfallBps: 0, ShortfallCost: 0, QuotedSpreadBps: 2, SpreadPaid: 0, Commission: 3},
+		{FilledQuantity: 100, FilledNotional: 10000, ShortfallBps: math.NaN(), ShortfallCost: math.NaN(), QuotedSpreadBps: math.NaN(), SpreadPaid: math.NaN(), Commission: 1},
+		{Commission: 0},
+	}
+	cs := SummarizeCosts(tcs)
+	if cs.Trades != 4 || cs.TradesWithArrival != 2 {
+		t.Errorf("SummarizeCosts() trades = %v, %v, want %v, %v", cs.Trades, cs.TradesWithArrival, 4, 2)
+	}
+	if !almostEqual(cs.ShortfallBps, 1) || !almostEqual(cs.ShortfallCost, 4) || !almostEqual(cs.SpreadPaid, 0.25) {
+		t.Errorf("SummarizeCosts() = %v bps, %v, %v spread paid, want %v, %v, %v", cs.ShortfallBps, cs.ShortfallCost, cs.SpreadPaid, 1, 4, 0.25)
+	}
+	if !almostEqual(cs.CommissionPerShare, 0.01) || !almostEqual(cs.CommissionBps, 1) {
+		t.Errorf("SummarizeCosts() commission = %v per share, %v bps, want %v, %v", cs.CommissionPerShare, cs.CommissionBps, 0.01, 1)
+	}
+}
+
+func TestWriteTradeCosts(t *testing.T) {
+	aapl := &Contract{ConID: 265598, Symbol: "AAPL", SecType: "STK"}
+	trade := newTestTrade(aapl, "BUY", 100, newTestFill("1", "tca", aapl, "BOT", 100, 100, 1))
+	tcs := []TradeCost{trade.Cost()}
+
+	var buf bytes.Buffer
+	if err := WriteTradeCostsCSV(&buf, tcs); err != nil {
+		t.Fatalf("WriteTradeCostsCSV() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "OrderID,") || !strings.HasPrefix(lines[1], "1,0,tca,AAPL,265598,BUY,100,100,10000,") {
+		t.Errorf("WriteTradeCostsCSV() = %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteTradeCostsJSON(&buf, tcs); err != nil {
+		t.Fatalf("WriteTradeCostsJSON() error = %v", err)
+	}
+	var out []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("WriteTradeCostsJSON() invalid json: %v", err)
+	}
+	if len(out) != 1 || out[0]["arrivalPrice"] != nil || out[0]["avgFillPrice"] != 100.0 {
+		t.Errorf("WriteTradeCostsJSON() = %s", buf.String())
+	}
+}
@@ -22,7 +22,7 @@ import (
 // Market Data Types:
 // - Level 1 streaming ticks stored in 'ticks'
 // - Level 2 market depth ticks stored in 'domTicks'
-// - Order book (DOM) available in 'domBids' and 'domAsks'
+// - Order book (DOM) available in 'domBids' and 'domAsks', and as an OrderBook
 // - Tick-by-tick data stored in 'tickByTicks'
 //
 // Options Greeks:
@@ -92,6 +92,7 @@ type Ticker struct {
 	domBids             map[int64]DOMLevel
 	domAsks             map[int64]DOMLevel
 	domTicks            []MktDepthData
+	orderBook           *OrderBook
 	bidGreeks           TickOptionComputation
 	askGreeks           TickOptionComputation
 	lastGreeks          TickOptionComputation
@@ -107,9 +108,10 @@ type Ticker struct {
 // NewTicker creates a new Ticker instance for the given contract.
 func NewTicker(contract *Contract) *Ticker {
 	return &Ticker{
-		contract: contract,
-		domBids:  make(map[int64]DOMLevel),
-		domAsks:  make(map[int64]DOMLevel),
+		contract:  contract,
+		domBids:   make(map[int64]DOMLevel),
+		domAsks:   make(map[int64]DOMLevel),
+		orderBook: NewOrderBook(),
 	}
 }
 
@@ -126,16 +128,33 @@ func (t *Ticker) Time() time.Time {
 	return t.time
 }
 
+// MarketDataType returns the type of the data received, e.g. MarketDataTypeLive or MarketDataTypeDelayed. It is 0 until known.
+//
+// It is reported by TWS and also updated when delayed ticks or the delayed market data warning are received.
 func (t *Ticker) MarketDataType() int64 {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	return t.marketDataType
 }
 
-func (t *Ticker) setMarketDataType(marketDataType int64) {
+// setMarketDataType sets the market data type and returns the previous one.
+func (t *Ticker) setMarketDataType(marketDataType int64) int64 {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	previous := t.marketDataType
 	t.marketDataType = marketDataType
+	return previous
+}
+
+// setDelayed switches the market data type to its delayed counterpart and returns the previous and new types.
+func (t *Ticker) setDelayed() (previous, current int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	previous = t.marketDataType
+	if !IsDelayedMarketDataType(previous) {
+		t.marketDataType = delayedMarketDataType(previous)
+	}
+	return previous, t.marketDataType
 }
 
 func (t *Ticker) MinTick() float64 {
@@ -501,6 +520,31 @@ func (t *Ticker) DomTicks() []MktDepthData {
 	return t.domTicks
 }
 
+// OrderBook returns the order book built from the market depth updates.
+func (t *Ticker) OrderBook() *OrderBook {
+	return t.orderBook
+}
+
+// setMktDepth applies a market depth update to the order book and the DOM.
+func (t *Ticker) setMktDepth(tick MktDepthData) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.orderBook.apply(tick); err != nil {
+		return err
+	}
+	snapshot := t.orderBook.Snapshot()
+	clear(t.domBids)
+	for i, row := range snapshot.Bids {
+		t.domBids[int64(i)] = row
+	}
+	clear(t.domAsks)
+	for i, row := range snapshot.Asks {
+		t.domAsks[int64(i)] = row
+	}
+	t.domTicks = append(t.domTicks, tick)
+	return nil
+}
+
 func (t *Ticker) BidGreeks() TickOptionComputation {
 	t.mu.Lock()
 	defer t.mu.Unlock()
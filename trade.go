@@ -2,6 +2,7 @@ package ibsync
 
 import (
 	"fmt"
+	"math"
 	"sort"
 	"sync"
 	"time"
@@ -34,11 +35,36 @@ func (os OrderStatusData) IsDone() bool {
 
 // Fill represents a single execution fill of an order, including contract details,
 // execution information, and commission data.
+//
+// The commission report is received after the execution and set on the fills shared by the trades.
+// Read it with CommissionReport while connected.
 type Fill struct {
 	Contract                *Contract               // Contract details for the filled order
 	Execution               *Execution              // Execution details of the fill
 	CommissionAndFeesReport CommissionAndFeesReport // Commission and fees information for the fill
 	Time                    time.Time               // Timestamp of the fill
+	commissionMu            *sync.RWMutex           // Lock of the commission report, shared by the fills of an IB
+}
+
+// CommissionReport returns the commission and fees report of the fill.
+func (f *Fill) CommissionReport() CommissionAndFeesReport {
+	if f.commissionMu == nil {
+		return f.CommissionAndFeesReport
+	}
+	f.commissionMu.RLock()
+	defer f.commissionMu.RUnlock()
+	return f.CommissionAndFeesReport
+}
+
+// setCommissionReport sets the commission and fees report of the fill.
+func (f *Fill) setCommissionReport(report CommissionAndFeesReport) {
+	if f.commissionMu == nil {
+		f.CommissionAndFeesReport = report
+		return
+	}
+	f.commissionMu.Lock()
+	defer f.commissionMu.Unlock()
+	f.CommissionAndFeesReport = report
 }
 
 // PassesExecutionFilter checks if the fill matches the specified execution filter criteria.
@@ -77,9 +103,18 @@ func (f *Fill) Matches(filter *ExecutionFilter) bool {
 	return true
 }
 
+// signedShares returns the executed quantity of the fill, positive for buys and negative for sells.
+func (f *Fill) signedShares() float64 {
+	shares := decimalToFloat(f.Execution.Shares)
+	if f.Execution.Side == "SLD" {
+		return -shares
+	}
+	return shares
+}
+
 func (f *Fill) String() string {
 	return fmt.Sprintf("Fill{Contract: %v, Execution: %v, CommissionAndFeesReport: %v, Time: %v}",
-		f.Contract, f.Execution, f.CommissionAndFeesReport, f.Time)
+		f.Contract, f.Execution, f.CommissionReport(), f.Time)
 }
 
 // TradeLogEntry represents a single entry in the trade's log, recording status changes
@@ -102,6 +137,9 @@ type Trade struct {
 	logs        []TradeLogEntry
 	done        chan struct{}
 	ack         chan struct{}
+	arrivalBid  float64   // Bid when the order was placed, 0 if unknown
+	arrivalAsk  float64   // Ask when the order was placed, 0 if unknown
+	arrivalTime time.Time // Time of the arrival quote
 }
 
 /* func (t* Trade) Equal(other Trade) bool{
@@ -201,6 +239,38 @@ func (t *Trade) resetAck() {
 	t.ack = make(chan struct{})
 }
 
+// ArrivalQuote returns the bid and ask of the contract ticker when the order was placed.
+// ok is false if no quote was available, e.g. no market data was subscribed for the contract.
+func (t *Trade) ArrivalQuote() (bid, ask float64, ok bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.arrivalBid, t.arrivalAsk, t.arrivalBid > 0 && t.arrivalAsk > 0
+}
+
+// ArrivalTime returns the time of the arrival quote. It is zero if no quote was recorded: the order was not placed
+// by this session, or no ticker with a bid and an ask was subscribed for the contract when it was placed.
+func (t *Trade) ArrivalTime() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.arrivalTime
+}
+
+// ArrivalPrice returns the mid price when the order was placed, NaN if unknown.
+func (t *Trade) ArrivalPrice() float64 {
+	bid, ask, ok := t.ArrivalQuote()
+	if !ok {
+		return math.NaN()
+	}
+	return (bid + ask) * 0.5
+}
+
+// setArrivalQuote records the quote at the time the order is placed.
+func (t *Trade) setArrivalQuote(bid, ask float64, time time.Time) {
+	t.arrivalBid = bid
+	t.arrivalAsk = ask
+	t.arrivalTime = time
+}
+
 // Fills returns a copy of all fills for this trade
 func (t *Trade) Fills() []*Fill {
 	t.mu.RLock()
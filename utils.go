@@ -3,6 +3,7 @@ package ibsync
 import (
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -346,3 +347,33 @@ func stringifyMap(v reflect.Value) string {
 
 	return fmt.Sprintf("map[%s]", strings.Join(elements, ", "))
 }
+
+// decimalToFloat converts a Decimal to a float64. Unset decimals are returned as 0.
+func decimalToFloat(d Decimal) float64 {
+	if d == UNSET_DECIMAL {
+		return 0
+	}
+	f := d.Float()
+	if math.IsNaN(f) {
+		return 0
+	}
+	return f
+}
+
+// floatToDecimal converts a float64 to a Decimal.
+func floatToDecimal(f float64) Decimal {
+	return StringToDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// contractMultiplier returns the multiplier of the contract as a float64.
+// It returns 1 if the multiplier is not set or cannot be parsed.
+func contractMultiplier(c *Contract) float64 {
+	if c == nil || c.Multiplier == "" {
+		return 1
+	}
+	m, err := strconv.ParseFloat(c.Multiplier, 64)
+	if err != nil || m == 0 {
+		return 1
+	}
+	return m
+}
@@ -0,0 +1,457 @@
+package ibsync
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// VolPoint is an implied volatility quote of an option.
+type VolPoint struct {
+	Expiry  string  // Last trade date, "YYYYMMDD"
+	T       float64 // Time to expiration in years
+	Strike  float64
+	Right   string
+	Forward float64 // Underlying price reported with the greeks
+	IV      float64
+}
+
+// SVIParams are the parameters of a raw SVI smile, w(k) = a + b * (rho * (k - m) + sqrt((k - m)^2 + sigma^2)),
+// where w is the total implied variance, vol^2 * T, and k the log-moneyness, ln(strike / forward).
+type SVIParams struct {
+	A, B, Rho, M, Sigma float64
+}
+
+// TotalVariance returns the total implied variance at log-moneyness k.
+func (p SVIParams) TotalVariance(k float64) float64 {
+	d := k - p.M
+	return p.A + p.B*(p.Rho*d+math.Sqrt(d*d+p.Sigma*p.Sigma))
+}
+
+// derivatives returns the first and second derivatives of the total variance at log-moneyness k.
+func (p SVIParams) derivatives(k float64) (float64, float64) {
+	d := k - p.M
+	r := math.Sqrt(d*d + p.Sigma*p.Sigma)
+	return p.B * (p.Rho + d/r), p.B * p.Sigma * p.Sigma / (r * r * r)
+}
+
+// Smile is the SVI fit of the implied volatilities of an expiration.
+type Smile struct {
+	Expiry  string
+	T       float64
+	Forward float64
+	Params  SVIParams
+	RMSE    float64 // Root mean square error of the fit, in volatility
+	Points  []VolPoint
+}
+
+// Vol returns the implied volatility of a strike.
+func (s Smile) Vol(strike float64) float64 {
+	w := s.Params.TotalVariance(math.Log(strike / s.Forward))
+	return math.Sqrt(max(w, 0) / s.T)
+}
+
+// ArbitrageViolation is a static arbitrage found in a volatility surface.
+type ArbitrageViolation struct {
+	Kind   string  // "butterfly" for a negative density in a smile, "calendar" for a total variance decreasing with the expiration
+	Expiry string  // Expiration of the smile, the later one for a calendar arbitrage
+	K      float64 // Log-moneyness of the violation
+}
+
+// VolSurface is an implied volatility surface made of SVI smiles.
+type VolSurface struct {
+	Time      time.Time
+	Smiles    []Smile // Sorted by expiration
+	Arbitrage []ArbitrageViolation
+}
+
+var errNoVolPoints = errors.New("vol surface: not enough implied volatilities")
+
+// Minimum number of strikes to fit the smile of an expiration.
+const minSmilePoints = 5
+
+// VolPointsFromTickers collects the implied volatilities of option tickers, from their mid greeks, or model greeks.
+// Out of the money options are preferred: for a strike quoted by both a call and a put, the call is kept above the forward.
+func VolPointsFromTickers(tickers []*Ticker, now time.Time) []VolPoint {
+	type strikeKey struct {
+		expiry string
+		strike float64
+	}
+	points := make(map[strikeKey]VolPoint)
+	for _, ticker := range tickers {
+		contract := ticker.Contract()
+		if contract == nil || !isOption(contract) {
+			continue
+		}
+		greeks := ticker.MidGreeks()
+		if !validVolGreeks(greeks) {
+			greeks = ticker.ModelGreeks()
+		}
+		if !validVolGreeks(greeks) {
+			continue
+		}
+		t := YearsToExpiry(contract.LastTradeDateOrContractMonth, now)
+		if t <= 0 {
+			continue
+		}
+		p := VolPoint{
+			Expiry:  contract.LastTradeDateOrContractMonth,
+			T:       t,
+			Strike:  contract.Strike,
+			Right:   contract.Right,
+			Forward: greeks.UndPrice,
+			IV:      greeks.ImpliedVol,
+		}
+		key := strikeKey{p.Expiry, p.Strike}
+		if q, ok := points[key]; ok && isOTM(q) && !isOTM(p) {
+			continue
+		}
+		points[key] = p
+	}
+	var out []VolPoint
+	for _, p := range points {
+		out = append(out, p)
+	}
+	slices.SortFunc(out, func(a, b VolPoint) int {
+		return compareOptionKeys(optionKey{a.Expiry, a.Strike, a.Right}, optionKey{b.Expiry, b.Strike, b.Right})
+	})
+	return out
+}
+
+func validVolGreeks(g TickOptionComputation) bool {
+	return g.ImpliedVol > 0 && g.ImpliedVol < maxImpliedVol && g.UndPrice > 0 && g.UndPrice != UNSET_FLOAT
+}
+
+func isOTM(p VolPoint) bool {
+	if p.Right == "C" {
+		return p.Strike >= p.Forward
+	}
+	return p.Strike < p.Forward
+}
+
+// FitVolSurface fits an SVI smile to the implied volatilities of each expiration and checks the surface for static arbitrage.
+// The expirations with less than 5 strikes are skipped.
+func FitVolSurface(points []VolPoint) (*VolSurface, error) {
+	byExpiry := make(map[string][]VolPoint)
+	for _, p := range points {
+		if p.T > 0 && p.IV > 0 && p.Strike > 0 && p.Forward > 0 {
+			byExpiry[p.Expiry] = append(byExpiry[p.Expiry], p)
+		}
+	}
+	surface := &VolSurface{Time: time.Now()}
+	for expiry, pts := range byExpiry {
+		if len(pts) < minSmilePoints {
+			continue
+		}
+		surface.Smiles = append(surface.Smiles, fitSmile(expiry, pts))
+	}
+	if len(surface.Smiles) == 0 {
+		return nil, errNoVolPoints
+	}
+	slices.SortFunc(surface.Smiles, func(a, b Smile) int { return cmp.Compare(a.T, b.T) })
+	surface.Arbitrage = surface.checkArbitrage()
+	return surface, nil
+}
+
+// fitSmile fits a raw SVI smile to the points of an expiration.
+//
+// For given m and sigma, the total variance is linear in a, b * rho and b: they are solved by least squares
+// while m and sigma are searched on a grid refined around the best fit.
+func fitSmile(expiry string, points []VolPoint) Smile {
+	var t, forward float64
+	for _, p := range points {
+		t += p.T
+		forward += p.Forward
+	}
+	t /= float64(len(points))
+	forward /= float64(len(points))
+
+	ks := make([]float64, len(points))
+	ws := make([]float64, len(points))
+	for i, p := range points {
+		ks[i] = math.Log(p.Strike / forward)
+		ws[i] = p.IV * p.IV * t
+	}
+	kMin, kMax := slices.Min(ks), slices.Max(ks)
+
+	best, bestErr := SVIParams{}, math.Inf(1)
+	mLo, mHi := kMin-0.5*(kMax-kMin), kMax+0.5*(kMax-kMin)
+	sLo, sHi := math.Log(1e-3), math.Log(2.0)
+	for range 4 {
+		const steps = 20
+		var bm, bs float64
+		for i := range steps + 1 {
+			m := mLo + (mHi-mLo)*float64(i)/steps
+			for j := range steps + 1 {
+				sigma := math.Exp(sLo + (sHi-sLo)*float64(j)/steps)
+				p := solveSVILinear(ks, ws, m, sigma)
+				if e := sviError(p, ks, ws); e < bestErr {
+					best, bestErr, bm, bs = p, e, m, math.Log(sigma)
+				}
+			}
+		}
+		dm, ds := (mHi-mLo)/steps*2, (sHi-sLo)/steps*2
+		mLo, mHi, sLo, sHi = bm-dm, bm+dm, bs-ds, bs+ds
+	}
+
+	var se float64
+	for i, k := range ks {
+		d := math.Sqrt(max(best.TotalVariance(k), 0)/t) - points[i].IV
+		se += d * d
+	}
+	return Smile{
+		Expiry:  expiry,
+		T:       t,
+		Forward: forward,
+		Params:  best,
+		RMSE:    math.Sqrt(se / float64(len(points))),
+		Points:  points,
+	}
+}
+
+// solveSVILinear solves a, b * rho and b by least squares for given m and sigma, within the SVI constraints:
+// b >= 0, |rho| < 1 and a non negative minimum variance.
+func solveSVILinear(ks, ws []float64, m, sigma float64) SVIParams {
+	// Normal equations of w = a + c * x + d * y, x = k - m, y = sqrt(x^2 + sigma^2).
+	var n, sx, sy, sxx, sxy, syy, sw, sxw, syw float64
+	for i, k := range ks {
+		x := k - m
+		y := math.Sqrt(x*x + sigma*sigma)
+		w := ws[i]
+		n++
+		sx, sy, sxx, sxy, syy = sx+x, sy+y, sxx+x*x, sxy+x*y, syy+y*y
+		sw, sxw, syw = sw+w, sxw+x*w, syw+y*w
+	}
+	a, c, d, ok := solve3([3][3]float64{{n, sx, sy}, {sx, sxx, sxy}, {sy, sxy, syy}}, [3]float64{sw, sxw, syw})
+	if !ok {
+		return SVIParams{A: sw / n, M: m, Sigma: sigma}
+	}
+	d = max(d, 0)
+	c = max(min(c, 0.999*d), -0.999*d)
+	var rho float64
+	if d > 0 {
+		rho = c / d
+	}
+	// Refit a with the constrained slopes.
+	var sr float64
+	for i, k := range ks {
+		x := k - m
+		sr += ws[i] - c*x - d*math.Sqrt(x*x+sigma*sigma)
+	}
+	a = sr / n
+	a = max(a, -d*sigma*math.Sqrt(1-rho*rho))
+	return SVIParams{A: a, B: d, Rho: rho, M: m, Sigma: sigma}
+}
+
+// solve3 solves a 3x3 linear system with Cramer's rule.
+func solve3(m [3][3]float64, v [3]float64) (x, y, z float64, ok bool) {
+	det := func(m [3][3]float64) float64 {
+		return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+			m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+			m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	}
+	d := det(m)
+	if math.Abs(d) < 1e-14 {
+		return 0, 0, 0, false
+	}
+	var sol [3]float64
+	for col := range 3 {
+		mc := m
+		for row := range 3 {
+			mc[row][col] = v[row]
+		}
+		sol[col] = det(mc) / d
+	}
+	return sol[0], sol[1], sol[2], true
+}
+
+func sviError(p SVIParams, ks, ws []float64) float64 {
+	var e float64
+	for i, k := range ks {
+		d := p.TotalVariance(k) - ws[i]
+		e += d * d
+	}
+	return e
+}
+
+// Log-moneyness grid of the arbitrage checks.
+const (
+	arbitrageKMin  = -1.0
+	arbitrageKMax  = 1.0
+	arbitrageKStep = 0.01
+)
+
+// checkArbitrage checks the smiles for butterfly arbitrage, a negative risk neutral density,
+// and consecutive smiles for calendar arbitrage, a total variance decreasing with the expiration.
+func (s *VolSurface) checkArbitrage() []ArbitrageViolation {
+	var violations []ArbitrageViolation
+	for i, smile := range s.Smiles {
+		for k := arbitrageKMin; k <= arbitrageKMax; k += arbitrageKStep {
+			if sviDensity(smile.Params, k) < -1e-9 {
+				violations = append(violations, ArbitrageViolation{Kind: "butterfly", Expiry: smile.Expiry, K: k})
+				break
+			}
+		}
+		if i == 0 {
+			continue
+		}
+		prev := s.Smiles[i-1]
+		for k := arbitrageKMin; k <= arbitrageKMax; k += arbitrageKStep {
+			if smile.Params.TotalVariance(k) < prev.Params.TotalVariance(k)-1e-9 {
+				violations = append(violations, ArbitrageViolation{Kind: "calendar", Expiry: smile.Expiry, K: k})
+				break
+			}
+		}
+	}
+	return violations
+}
+
+// sviDensity returns Gatheral's g(k), proportional to the risk neutral density, negative on a butterfly arbitrage.
+func sviDensity(p SVIParams, k float64) float64 {
+	w := p.TotalVariance(k)
+	if w <= 0 {
+		return -1
+	}
+	w1, w2 := p.derivatives(k)
+	a := 1 - k*w1/(2*w)
+	return a*a - w1*w1/4*(1/w+0.25) + w2/2
+}
+
+// totalVariance returns the total variance at log-moneyness k and time t, interpolated linearly in t between smiles
+// and extrapolated at a constant volatility before the first and after the last smile.
+func (s *VolSurface) totalVariance(k, t float64) float64 {
+	first, last := s.Smiles[0], s.Smiles[len(s.Smiles)-1]
+	switch {
+	case t <= first.T:
+		return first.Params.TotalVariance(k) * t / first.T
+	case t >= last.T:
+		return last.Params.TotalVariance(k) * t / last.T
+	}
+	i, _ := slices.BinarySearchFunc(s.Smiles, t, compareSmileT)
+	lo, hi := s.Smiles[i-1], s.Smiles[i]
+	x := (t - lo.T) / (hi.T - lo.T)
+	return lo.Params.TotalVariance(k)*(1-x) + hi.Params.TotalVariance(k)*x
+}
+
+// compareSmileT compares the time to expiration of a smile to t.
+func compareSmileT(smile Smile, t float64) int {
+	return cmp.Compare(smile.T, t)
+}
+
+// Forward returns the forward at time t, interpolated linearly between smiles.
+func (s *VolSurface) Forward(t float64) float64 {
+	first, last := s.Smiles[0], s.Smiles[len(s.Smiles)-1]
+	switch {
+	case t <= first.T:
+		return first.Forward
+	case t >= last.T:
+		return last.Forward
+	}
+	i, _ := slices.BinarySearchFunc(s.Smiles, t, compareSmileT)
+	lo, hi := s.Smiles[i-1], s.Smiles[i]
+	x := (t - lo.T) / (hi.T - lo.T)
+	return lo.Forward*(1-x) + hi.Forward*x
+}
+
+// Vol returns the implied volatility of a strike at time t in years.
+func (s *VolSurface) Vol(strike, t float64) float64 {
+	if t <= 0 || strike <= 0 {
+		return math.NaN()
+	}
+	w := s.totalVariance(math.Log(strike/s.Forward(t)), t)
+	return math.Sqrt(max(w, 0) / t)
+}
+
+// VolAtDelta returns the implied volatility and the strike of the option of forward delta at time t in years.
+// A positive delta is a call delta, a negative delta a put delta, e.g. -0.25 for the 25 delta put.
+func (s *VolSurface) VolAtDelta(delta, t float64) (vol, strike float64) {
+	if t <= 0 || delta == 0 || math.Abs(delta) >= 1 {
+		return math.NaN(), math.NaN()
+	}
+	callDelta := delta
+	if delta < 0 {
+		callDelta = 1 + delta
+	}
+	// The call delta N(d1) decreases with k, bisect on k.
+	lo, hi := -5.0, 5.0
+	for range 100 {
+		k := (lo + hi) / 2
+		w := max(s.totalVariance(k, t), 1e-12)
+		d1 := (-k + w/2) / math.Sqrt(w)
+		if normCDF(d1) > callDelta {
+			lo = k
+		} else {
+			hi = k
+		}
+	}
+	k := (lo + hi) / 2
+	return math.Sqrt(max(s.totalVariance(k, t), 0) / t), s.Forward(t) * math.Exp(k)
+}
+
+// VolSurfaceView is a volatility surface refitted from live option tickers.
+type VolSurfaceView struct {
+	tickers []*Ticker
+	mu      sync.Mutex
+	surface *VolSurface
+	err     error
+	updates chan *VolSurface
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewVolSurfaceView creates a view fitting the volatility surface of the tickers every interval, 5 seconds if interval <= 0.
+// A new surface is sent on Updates when the implied volatilities changed. Close stops the view, not the tickers.
+func NewVolSurfaceView(tickers []*Ticker, interval time.Duration) *VolSurfaceView {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	v := &VolSurfaceView{
+		tickers: tickers,
+		updates: make(chan *VolSurface, 1),
+		done:    make(chan struct{}),
+	}
+	go v.run(interval)
+	return v
+}
+
+func (v *VolSurfaceView) run(interval time.Duration) {
+	var last []VolPoint
+	pollLatest(v.updates, interval, v.done, nil, func() (*VolSurface, bool) {
+		points := VolPointsFromTickers(v.tickers, time.Now())
+		if slices.EqualFunc(points, last, func(a, b VolPoint) bool {
+			return a.Expiry == b.Expiry && a.Strike == b.Strike && a.Right == b.Right && a.IV == b.IV && a.Forward == b.Forward
+		}) {
+			return nil, false
+		}
+		last = points
+		surface, err := FitVolSurface(points)
+		v.mu.Lock()
+		v.err = err
+		if err == nil {
+			v.surface = surface
+		}
+		v.mu.Unlock()
+		return surface, err == nil
+	})
+}
+
+// Surface returns the last fitted surface, nil before the first fit, and the error of the last fit.
+func (v *VolSurfaceView) Surface() (*VolSurface, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.surface, v.err
+}
+
+// Updates returns the channel of the fitted surfaces. An unread surface is replaced by the newer one.
+// It is closed by Close.
+func (v *VolSurfaceView) Updates() <-chan *VolSurface {
+	return v.updates
+}
+
+// Close stops the view.
+func (v *VolSurfaceView) Close() {
+	v.once.Do(func() { close(v.done) })
+}
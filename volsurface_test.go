@@ -0,0 +1,110 @@
+package ibsync
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func sviPoints(expiry string, t, forward float64, p SVIParams) []VolPoint {
+	var points []VolPoint
+	for strike := 70.0; strike <= 130; strike += 5 {
+		w := p.TotalVariance(math.Log(strike / forward))
+		points = append(points, VolPoint{Expiry: expiry, T: t, Strike: strike, Right: "C", Forward: forward, IV: math.Sqrt(w / t)})
+	}
+	return points
+}
+
+func TestFitVolSurface(t *testing.T) {
+	near := SVIParams{A: 0.005, B: 0.05, Rho: -0.4, M: 0.02, Sigma: 0.15}
+	far := SVIParams{A: 0.02, B: 0.08, Rho: -0.3, M: 0.03, Sigma: 0.2}
+	points := append(sviPoints("20240419", 0.25, 100, near), sviPoints("20240920", 0.75, 102, far)...)
+	points = append(points, VolPoint{Expiry: "20240315", T: 0.1, Strike: 100, Forward: 100, IV: 0.2}) // Too few strikes
+
+	s, err := FitVolSurface(points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Smiles) != 2 || s.Smiles[0].Expiry != "20240419" {
+		t.Fatalf("smiles = %+v", s.Smiles)
+	}
+	for _, smile := range s.Smiles {
+		if smile.RMSE > 1e-3 {
+			t.Errorf("%v RMSE = %v", smile.Expiry, smile.RMSE)
+		}
+		for _, p := range smile.Points {
+			if got := smile.Vol(p.Strike); math.Abs(got-p.IV) > 2e-3 {
+				t.Errorf("%v Vol(%v) = %v, want %v", smile.Expiry, p.Strike, got, p.IV)
+			}
+		}
+	}
+	if len(s.Arbitrage) != 0 {
+		t.Errorf("Arbitrage = %+v", s.Arbitrage)
+	}
+
+	// On a smile, the surface is the smile.
+	if got, want := s.Vol(90, 0.25), s.Smiles[0].Vol(90); math.Abs(got-want) > 1e-12 {
+		t.Errorf("Vol(90, 0.25) = %v, want %v", got, want)
+	}
+	// Between smiles, the total variance is interpolated.
+	mid := s.Vol(100, 0.5)
+	w1 := math.Pow(s.Vol(100, 0.25), 2) * 0.25
+	w2 := math.Pow(s.Vol(100, 0.75), 2) * 0.75
+	if w := mid * mid * 0.5; w < min(w1, w2) || w > max(w1, w2) {
+		t.Errorf("Vol(100, 0.5) = %v out of the smiles total variance", mid)
+	}
+
+	// At 50 delta, the strike is close to the forward: d1 = 0 gives k = w / 2.
+	vol, strike := s.VolAtDelta(0.5, 0.25)
+	if want := 100 * math.Exp(vol*vol*0.25/2); math.Abs(strike-want) > 1e-6 {
+		t.Errorf("VolAtDelta(0.5) strike = %v, want %v", strike, want)
+	}
+	// The 25 delta put is below the 25 delta call.
+	_, put := s.VolAtDelta(-0.25, 0.25)
+	_, call := s.VolAtDelta(0.25, 0.25)
+	if put >= 100 || call <= 100 {
+		t.Errorf("25 delta put, call strikes = %v, %v", put, call)
+	}
+}
+
+func TestVolSurfaceArbitrage(t *testing.T) {
+	s := &VolSurface{Smiles: []Smile{
+		{Expiry: "20240419", T: 0.25, Forward: 100, Params: SVIParams{A: 0.04, B: 0.05, Rho: -0.4, M: 0, Sigma: 0.1}},
+		{Expiry: "20240920", T: 0.75, Forward: 100, Params: SVIParams{A: 0.01, B: 0.05, Rho: -0.4, M: 0, Sigma: 0.1}},
+		{Expiry: "20241220", T: 1, Forward: 100, Params: SVIParams{A: 0.05, B: 2, Rho: 0.9, M: 0, Sigma: 0.01}},
+	}}
+	violations := s.checkArbitrage()
+	var calendar, butterfly bool
+	for _, v := range violations {
+		calendar = calendar || (v.Kind == "calendar" && v.Expiry == "20240920")
+		butterfly = butterfly || (v.Kind == "butterfly" && v.Expiry == "20241220")
+	}
+	if !calendar || !butterfly {
+		t.Errorf("checkArbitrage() = %+v", violations)
+	}
+}
+
+func TestVolPointsFromTickers(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ticker := func(strike float64, right string, iv float64) *Ticker {
+		tk := NewTicker(NewOption("SPY", "20240419", strike, right, "SMART", "100", "USD"))
+		tk.SetTickOptionComputation(TickOptionComputation{TickType: MODEL_OPTION, ImpliedVol: iv, Delta: 0.5, UndPrice: 100})
+		return tk
+	}
+	tickers := []*Ticker{
+		ticker(95, "C", 0.30), ticker(95, "P", 0.25), // The put is out of the money
+		ticker(105, "P", 0.30), ticker(105, "C", 0.20), // The call is out of the money
+		ticker(110, "C", 0), // No implied volatility
+		NewTicker(NewStock("SPY", "SMART", "USD")),
+	}
+	points := VolPointsFromTickers(tickers, now)
+	if len(points) != 2 {
+		t.Fatalf("points = %+v", points)
+	}
+	if points[0].Right != "P" || points[0].IV != 0.25 || points[1].Right != "C" || points[1].IV != 0.20 {
+		t.Errorf("points = %+v", points)
+	}
+	if points[0].T <= 0 || points[0].Forward != 100 {
+		t.Errorf("point = %+v", points[0])
+	}
+}
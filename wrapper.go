@@ -1,7 +1,6 @@
 package ibsync
 
 import (
-	"errors"
 	"fmt"
 	"slices"
 	"strconv"
@@ -34,6 +33,9 @@ func (w *WrapperSync) TickPrice(reqID int64, tickType TickType, price float64, a
 	ticker := w.state.reqID2Ticker[reqID]
 	w.state.mu.Unlock()
 
+	if isDelayedTickType(tickType) {
+		w.tickerDelayed(reqID, ticker)
+	}
 	ticker.SetTickPrice(tickPrice)
 	//w.pubSub.Publish(reqID, Join("price", Encode(tickPrice)))
 }
@@ -46,6 +48,9 @@ func (w *WrapperSync) TickSize(reqID int64, tickType TickType, size Decimal) {
 	ticker := w.state.reqID2Ticker[reqID]
 	w.state.mu.Unlock()
 
+	if isDelayedTickType(tickType) {
+		w.tickerDelayed(reqID, ticker)
+	}
 	ticker.SetTickSize(tickSize)
 	//w.pubSub.Publish(reqID, Join("size", Encode(tickSize)))
 }
@@ -59,6 +64,9 @@ func (w *WrapperSync) TickOptionComputation(reqID int64, tickType TickType, tick
 	w.state.mu.Unlock()
 
 	if ok {
+		if isDelayedTickType(tickType) {
+			w.tickerDelayed(reqID, ticker)
+		}
 		ticker.SetTickOptionComputation(tickOptionComputation)
 		return
 	}
@@ -74,6 +82,9 @@ func (w *WrapperSync) TickGeneric(reqID int64, tickType TickType, value float64)
 	ticker := w.state.reqID2Ticker[reqID]
 	w.state.mu.Unlock()
 
+	if isDelayedTickType(tickType) {
+		w.tickerDelayed(reqID, ticker)
+	}
 	ticker.SetTickGeneric(tickGeneric)
 	//w.pubSub.Publish(reqID, Join("generic", Encode(tickGeneric)))
 }
@@ -86,6 +97,9 @@ func (w *WrapperSync) TickString(reqID int64, tickType TickType, value string) {
 	ticker := w.state.reqID2Ticker[reqID]
 	w.state.mu.Unlock()
 
+	if isDelayedTickType(tickType) {
+		w.tickerDelayed(reqID, ticker)
+	}
 	ticker.SetTickString(tickString)
 	//w.pubSub.Publish(reqID, Join("string", Encode(tickString)))
 }
@@ -278,6 +292,7 @@ func (w *WrapperSync) ExecDetails(reqID int64, contract *Contract, execution *Ex
 		Execution:               execution,
 		CommissionAndFeesReport: NewCommissionAndFeesReport(),
 		Time:                    executionTime.UTC(),
+		commissionMu:            &w.state.commissionMu,
 	}
 	_, ok = w.state.fills[execution.ExecID]
 	if !ok {
@@ -290,9 +305,10 @@ func (w *WrapperSync) ExecDetails(reqID int64, contract *Contract, execution *Ex
 		}
 		trade.addLog(logEntry)
 	}
+	msg := Encode(fill)
 	w.state.mu.Unlock()
 
-	w.pubSub.Publish(reqID, (Encode(fill)))
+	w.pubSub.Publish(reqID, msg)
 }
 
 func (w *WrapperSync) ExecDetailsEnd(reqID int64) {
@@ -312,6 +328,13 @@ func (w *WrapperSync) Error(reqID int64, errorTime int64, errCode int64, errStri
 	}
 	logger.Msg("<Error>")
 
+	if errCode == WarnDelayedMarketData.Code {
+		w.state.mu.Lock()
+		ticker := w.state.reqID2Ticker[reqID]
+		w.state.mu.Unlock()
+		w.tickerDelayed(reqID, ticker)
+	}
+
 	w.pubSub.Publish(reqID, Join("error", Encode(ibapi.CodeMsgPair{Code: errCode, Msg: errString})))
 }
 
@@ -326,35 +349,18 @@ func (w *WrapperSync) UpdateMktDepthL2(reqID int64, position int64, marketMaker
 }
 func (w *WrapperSync) updateMktDepth(reqID int64, position int64, marketMaker string, operation int64, side int64, price float64, size Decimal, isSmartDepth bool) {
 	w.state.mu.Lock()
-	ticker := w.state.reqID2Ticker[reqID]
+	ticker, ok := w.state.reqID2Ticker[reqID]
 	w.state.mu.Unlock()
-
-	// side: 0 = ask, 1 = bid
-	var dom map[int64]DOMLevel
-	switch side {
-	case 0:
-		dom = ticker.domAsks
-	case 1:
-		dom = ticker.domBids
-	default:
-		log.Error().Err(errors.New("unknown DOM side")).Msg("updateMktDepth")
-		return
-	}
-	// operation: 0 = insert, 1 = update, 2 = delete
-	switch operation {
-	case 0, 1:
-		dom[position] = DOMLevel{Price: price, Size: size, MarketMaker: marketMaker}
-	case 2:
-		delete(dom, position)
-	default:
-		log.Error().Err(errors.New("unknown DOM operation")).Msg("updateMktDepth>")
+	if !ok {
+		log.Error().Err(errUnknowReqID).Int64("reqID", reqID).Msg("<updateMktDepth>")
 		return
 	}
 
 	tick := MktDepthData{Time: time.Now(), Position: position, MarketMaker: marketMaker, Operation: operation, Side: side, Price: price, Size: size, IsSmartDepth: isSmartDepth}
-	w.state.mu.Lock()
-	ticker.domTicks = append(ticker.domTicks, tick)
-	w.state.mu.Unlock()
+	if err := ticker.setMktDepth(tick); err != nil {
+		log.Error().Err(err).Int64("reqID", reqID).Msg("<updateMktDepth>")
+		return
+	}
 	w.pubSub.Publish(reqID, "ok")
 }
 
@@ -432,13 +438,37 @@ func (w *WrapperSync) TickSnapshotEnd(reqID int64) {
 func (w *WrapperSync) MarketDataType(reqID int64, marketDataType int64) {
 	log.Debug().Int64("reqID", reqID).Int64("marketDataType", marketDataType).Msg("<MarketDataType>")
 	w.state.mu.Lock()
-	defer w.state.mu.Unlock()
 	ticker, ok := w.state.reqID2Ticker[reqID]
+	var previous int64
 	if ok {
-		ticker.setMarketDataType(marketDataType)
+		previous = ticker.setMarketDataType(marketDataType)
+	}
+	w.state.mu.Unlock()
+
+	// Publish without the state lock: Publish blocks on slow subscribers.
+	if ok {
+		w.publishMarketDataType(reqID, ticker, previous, marketDataType)
 	}
 }
 
+// tickerDelayed switches a ticker to delayed data when a delayed tick or the delayed data warning is received.
+func (w *WrapperSync) tickerDelayed(reqID int64, ticker *Ticker) {
+	if ticker == nil {
+		return
+	}
+	previous, current := ticker.setDelayed()
+	w.publishMarketDataType(reqID, ticker, previous, current)
+}
+
+// publishMarketDataType publishes a MarketDataTypeEvent if the market data type of a ticker changed.
+func (w *WrapperSync) publishMarketDataType(reqID int64, ticker *Ticker, previous, current int64) {
+	if previous == current {
+		return
+	}
+	log.Info().Int64("reqID", reqID).Str("previous", MarketDataTypeName(previous)).Str("current", MarketDataTypeName(current)).Msg("<MarketDataType> market data type changed")
+	w.pubSub.Publish("MarketDataType", Encode(MarketDataTypeEvent{ReqID: reqID, Contract: *ticker.Contract(), Previous: previous, Current: current}))
+}
+
 func (w *WrapperSync) CommissionAndFeesReport(commissionAndFeesReport CommissionAndFeesReport) {
 	if commissionAndFeesReport.Yield == UNSET_FLOAT {
 		commissionAndFeesReport.Yield = 0.0
@@ -455,10 +485,8 @@ func (w *WrapperSync) CommissionAndFeesReport(commissionAndFeesReport Commission
 		log.Error().Err(errUnknowExecution).Stringer("commissionReportAndFees", commissionAndFeesReport).Msg("<CommissionReportAndFeesœ		>")
 		return
 	}
+	fill.setCommissionReport(commissionAndFeesReport)
 	w.state.mu.Unlock()
-
-	fill.CommissionAndFeesReport = commissionAndFeesReport
-
 }
 
 func (w *WrapperSync) Position(account string, contract *Contract, position Decimal, avgCost float64) {
@@ -594,7 +622,7 @@ func (w *WrapperSync) TickNews(tickerID int64, timeStamp int64, providerCode str
 	newsTick := NewsTick{TimeStamp: timeStamp, ProviderCode: providerCode, ArticleId: articleID, Headline: headline, ExtraData: extraData}
 
 	w.state.mu.Lock()
-	w.state.newsTicks = append(w.state.newsTicks, newsTick)
+	w.state.newsTicks = appendNewsTick(w.state.newsTicks, newsTick)
 	w.state.mu.Unlock()
 
 	w.pubSub.Publish(tickerID, Encode(newsTick))
@@ -616,7 +644,9 @@ func (w *WrapperSync) TickReqParams(tickerID int64, minTick float64, bboExchange
 
 	ticker, ok := w.state.reqID2Ticker[tickerID]
 	if !ok {
-		log.Error().Err(errUnknowReqID).Msg("<TickReqParams>")
+		if _, news := w.state.newsReqIDs[tickerID]; !news {
+			log.Error().Err(errUnknowReqID).Msg("<TickReqParams>")
+		}
 		return
 	}
 